@@ -0,0 +1,251 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/parser"
+)
+
+var addCmd = &cobra.Command{
+	Use:   "add <provider> <package>...",
+	Short: "Add packages to the configuration",
+	Long: "Add packages to the package section of a provider (pacman, aur or flatpak) in the configuration file, keeping the rest of the file unchanged.\n" +
+		"For example, `declarch add pacman neovim --tag bare` adds `package = neovim, +bare` to the `packages/pacman` section.",
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := configPaths(cmd)[0]
+
+		providerName, pkgs := args[0], args[1:]
+		if !checkProvider(providerName) {
+			return
+		}
+
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		tagsString, err := formatTags(tags)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Invalid tag: ")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		userInstallation, _ := cmd.Flags().GetBool("user")
+		installation, _ := cmd.Flags().GetString("installation")
+		remote, _ := cmd.Flags().GetString("remote")
+		flatpakSection := userInstallation || installation != "" || remote != ""
+		if flatpakSection && providerName != "flatpak" {
+			color.Set(color.FgRed)
+			fmt.Println("The --user, --installation and --remote flags can only be used with Flatpak packages.")
+			color.Unset()
+			exitCode = 1
+			return
+		}
+
+		doc, section, ok := loadConfigDocument(configPath)
+		if !ok {
+			return
+		}
+
+		declared := declaredPackages(section, providerName)
+		pkgs = slices.DeleteFunc(uniquePackages(pkgs), func(pkg string) bool {
+			if slices.Contains(declared, pkg) {
+				color.Set(color.FgYellow)
+				fmt.Printf("Package %s is already declared in packages/%s.\n", pkg, providerName)
+				color.Unset()
+				return true
+			}
+			return false
+		})
+		if len(pkgs) == 0 {
+			return
+		}
+
+		value := strings.Join(pkgs, " ") + tagsString
+		if flatpakSection {
+			err = addFlatpakPackageSection(doc, value, userInstallation, installation, remote)
+		} else {
+			err = doc.Append("packages/"+providerName+"/package", value)
+		}
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error adding packages: ")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		if !saveConfigDocument(cmd, doc, configPath) {
+			return
+		}
+
+		color.Set(color.FgGreen)
+		fmt.Printf("Added %s to packages/%s.\n", strings.Join(pkgs, ", "), providerName)
+		color.Unset()
+
+		if apply, _ := cmd.Flags().GetBool("apply"); apply {
+			runApply(cmd, false)
+		}
+	},
+}
+
+// checkProvider reports whether a package provider with the given name is registered, and prints an error if not.
+func checkProvider(name string) bool {
+	names := []string{}
+	for _, provider := range modules.Providers() {
+		if provider.Name() == name {
+			return true
+		}
+		names = append(names, provider.Name())
+	}
+
+	color.Set(color.FgRed)
+	fmt.Print("Unknown package provider: ")
+	color.Set(color.Bold)
+	fmt.Print(name)
+	color.Set(color.ResetBold)
+	fmt.Printf(" (expected one of: %s).\n", strings.Join(names, ", "))
+	color.Unset()
+	exitCode = 1
+	return false
+}
+
+// uniquePackages returns the packages given on the command line without duplicates, in the order they were first given.
+func uniquePackages(pkgs []string) []string {
+	seen := map[string]bool{}
+	unique := []string{}
+	for _, pkg := range pkgs {
+		if !seen[pkg] {
+			seen[pkg] = true
+			unique = append(unique, pkg)
+		}
+	}
+	return unique
+}
+
+// formatTags returns the tag part of a package value for the given tags, e.g. ", +bare +desktop".
+// Tags can be given with or without the leading '+'.
+func formatTags(tags []string) (string, error) {
+	if len(tags) == 0 {
+		return "", nil
+	}
+
+	formatted := make([]string, len(tags))
+	for i, tag := range tags {
+		if !strings.HasPrefix(tag, "+") {
+			tag = "+" + tag
+		}
+		if v := modules.VerifyTag(tag); v != "" {
+			return "", fmt.Errorf("%s", v)
+		}
+		formatted[i] = tag
+	}
+	return ", " + strings.Join(formatted, " "), nil
+}
+
+// declaredPackages returns the packages declared in the package section of a provider, regardless of tags.
+func declaredPackages(section *parser.Section, providerName string) []string {
+	sectionPath := "packages/" + providerName
+
+	declared := []string{}
+	for _, path := range []string{sectionPath + "/package", sectionPath + "/package/name"} {
+		for _, value := range section.GetAll(path) {
+			declared = append(declared, strings.Fields(strings.SplitN(value, ",", 2)[0])...)
+		}
+	}
+	return declared
+}
+
+// addFlatpakPackageSection adds a `package` section to the Flatpak section, for packages that need more than a name.
+func addFlatpakPackageSection(doc *parser.Document, name string, userInstallation bool, installation, remote string) error {
+	sectionPath := fmt.Sprintf("packages/flatpak/package[%d]", len(doc.Sections("packages/flatpak/package")))
+
+	if err := doc.Set(sectionPath+"/name", name); err != nil {
+		return err
+	}
+	if remote != "" {
+		if err := doc.Set(sectionPath+"/remote", remote); err != nil {
+			return err
+		}
+	}
+	if userInstallation {
+		if err := doc.Set(sectionPath+"/user_installation", "true"); err != nil {
+			return err
+		}
+	}
+	if installation != "" {
+		return doc.Set(sectionPath+"/installation", installation)
+	}
+	return nil
+}
+
+// loadConfigDocument reads the configuration file both as a Document to edit and as a parsed Section,
+// printing an error if it can't be read.
+func loadConfigDocument(configPath string) (*parser.Document, *parser.Section, bool) {
+	doc, err := parser.ReadDocument(configPath)
+	if err != nil {
+		color.Set(color.FgRed)
+		fmt.Print("Error reading configuration file: ")
+		color.Set(color.Bold)
+		fmt.Print(configPath)
+		color.Set(color.ResetBold)
+		fmt.Println(":")
+		color.Unset()
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+		return nil, nil, false
+	}
+
+	section, err := doc.Parse()
+	if err != nil {
+		printDiagnostics(parseDiagnostics(err, configPath))
+		exitCode = 1
+		return nil, nil, false
+	}
+
+	return doc, section, true
+}
+
+// saveConfigDocument writes an edited configuration file, or only prints the changes with --dry-run.
+func saveConfigDocument(cmd *cobra.Command, doc *parser.Document, configPath string) bool {
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		original, _ := os.ReadFile(configPath)
+		printPlannedPatch(configPath, original, []byte(doc.String()))
+		return false
+	}
+
+	if err := doc.WriteFile(); err != nil {
+		color.Set(color.FgRed)
+		fmt.Print("Error writing configuration file: ")
+		color.Set(color.Bold)
+		fmt.Print(configPath)
+		color.Set(color.ResetBold)
+		fmt.Println(":")
+		color.Unset()
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+		return false
+	}
+	return true
+}
+
+func init() {
+	addCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file to add packages to, followed by the files layered over it for --apply")
+	addCmd.PersistentFlags().StringSlice("tag", []string{}, "Tags to add to the packages, e.g. 'bare'")
+	addCmd.PersistentFlags().Bool("user", false, "Install the Flatpak packages to the user installation")
+	addCmd.PersistentFlags().String("installation", "", "Install the Flatpak packages to this system-wide installation")
+	addCmd.PersistentFlags().String("remote", "", "Install the Flatpak packages from this remote")
+	addCmd.PersistentFlags().Bool("apply", false, "Apply the configuration after adding the packages")
+	addCmd.PersistentFlags().Bool("dry-run", false, "Print the changes to the configuration file without writing it")
+
+	rootCmd.AddCommand(addCmd)
+}
\ No newline at end of file
@@ -0,0 +1,71 @@
+package cmds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+func TestAdd_ExistingSection(t *testing.T) {
+	doc, err := parser.ParseDocument("packages {\n  pacman {\n    package = neovim # editor\n  }\n}\n", "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, doc.Append("packages/pacman/package", "htop"))
+	assert.Equal(t, "packages {\n  pacman {\n    package = neovim # editor\n    package = htop\n  }\n}\n", doc.String())
+}
+
+func TestAdd_MissingSection(t *testing.T) {
+	doc, err := parser.ParseDocument("users {\n  primary_user = alice\n}\n", "")
+	assert.NoError(t, err)
+
+	assert.NoError(t, doc.Append("packages/aur/package", "yay-bin"))
+	assert.Equal(t, "users {\n  primary_user = alice\n}\n\npackages {\n  aur {\n    package = yay-bin\n  }\n}\n", doc.String())
+}
+
+func TestAdd_Tags(t *testing.T) {
+	tags, err := formatTags([]string{"bare", "+desktop"})
+	assert.NoError(t, err)
+	assert.Equal(t, ", +bare +desktop", tags)
+
+	tags, err = formatTags(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "", tags)
+
+	_, err = formatTags([]string{"not a tag"})
+	assert.Error(t, err)
+
+	doc, _ := parser.ParseDocument("packages {\n  pacman {\n  }\n}\n", "")
+	assert.NoError(t, doc.Append("packages/pacman/package", "neovim git"+", +bare +desktop"))
+	assert.Equal(t, "packages {\n  pacman {\n    package = neovim git, +bare +desktop\n  }\n}\n", doc.String())
+}
+
+// add and remove skip packages given more than once, even if they are not adjacent.
+func TestUniquePackages(t *testing.T) {
+	assert.Equal(t, []string{"a", "b"}, uniquePackages([]string{"a", "b", "a"}))
+	assert.Equal(t, []string{"neovim", "git", "htop"}, uniquePackages([]string{"neovim", "git", "neovim", "htop", "git"}))
+	assert.Empty(t, uniquePackages(nil))
+}
+
+func TestAdd_FlatpakSection(t *testing.T) {
+	doc, _ := parser.ParseDocument("packages {\n  flatpak {\n    package = org.gnome.Boxes\n  }\n}\n", "")
+
+	assert.NoError(t, addFlatpakPackageSection(doc, "com.spotify.Client", true, "", "flathub"))
+	section, err := doc.Parse()
+	assert.NoError(t, err)
+	assert.Equal(t, "com.spotify.Client", section.GetFirst("packages/flatpak/package/name", ""))
+	assert.Equal(t, "flathub", section.GetFirst("packages/flatpak/package/remote", ""))
+	assert.Equal(t, "true", section.GetFirst("packages/flatpak/package/user_installation", ""))
+}
+
+// Packages that are already declared are skipped by add, and not reported as missing by remove.
+func TestDeclaredPackages(t *testing.T) {
+	section, err := parser.Parse("packages {\n  pacman {\n    package = neovim git, +desktop\n    package = htop\n  }\n" +
+		"  flatpak {\n    package = org.gnome.Boxes\n    package {\n      name = com.spotify.Client\n    }\n  }\n}\n")
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"neovim", "git", "htop"}, declaredPackages(section, "pacman"))
+	assert.Equal(t, []string{"org.gnome.Boxes", "com.spotify.Client"}, declaredPackages(section, "flatpak"))
+	assert.Empty(t, declaredPackages(section, "aur"))
+}
\ No newline at end of file
@@ -8,8 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"slices"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -17,186 +17,211 @@ import (
 	"github.com/DevReaper0/declarch/modules"
 	"github.com/DevReaper0/declarch/modules/config/ini"
 	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
+	"github.com/DevReaper0/declarch/state"
 	"github.com/DevReaper0/declarch/utils"
 )
 
 // The default tag includes everything without the exclamation mark.
 var tagSet *modules.TagSet
 
+// The repositories of the default pacman.conf, which use the mirrorlist unless another include or server is given.
+var builtinRepositories = []string{
+	"core-testing",
+	"core",
+	"extra-testing",
+	"extra",
+	"multilib-testing",
+	"multilib",
+}
+
 var applyCmd = &cobra.Command{
 	Use:   "apply",
 	Short: "Apply configuration",
 	Run: func(cmd *cobra.Command, args []string) {
-		if !CheckRoot() {
-			return
-		}
-
-		configPath, _ := cmd.Flags().GetString("config")
-		configPath, _ = filepath.Abs(configPath)
-
-		tagSet = modules.NewTagSet("+default")
-
-		if tags, _ := cmd.PersistentFlags().GetStringSlice("tags"); len(tags) > 0 {
-			tagSet.AddTags(tags)
-		}
+		dryRun, _ := cmd.PersistentFlags().GetBool("dry-run")
+		runApply(cmd, dryRun)
+	},
+}
 
-		if bare, _ := cmd.PersistentFlags().GetBool("bare"); bare {
-			tagSet.AddTags([]string{"-default", "+bare"})
-		}
+// runApply applies the configuration, or only prints what would be done if dryRun is set.
+func runApply(cmd *cobra.Command, dryRun bool) {
+	utils.DryRun = dryRun
 
-		if _, err := os.Stat(configPath); errors.Is(err, fs.ErrNotExist) {
-			if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
-				color.Set(color.FgRed)
-				fmt.Print("Error creating configuration directory: ")
-				color.Set(color.Bold)
-				fmt.Print(filepath.Dir(configPath))
-				color.Set(color.ResetBold)
-				fmt.Println(":")
-				color.Unset()
-				fmt.Fprintln(os.Stderr, err)
-				return
-			}
+	if !dryRun && !CheckRoot() {
+		return
+	}
 
-			if err := os.WriteFile(configPath, []byte(defaultConfig), 0o644); err != nil {
-				color.Set(color.FgRed)
-				fmt.Print("Error creating configuration file: ")
-				color.Set(color.Bold)
-				fmt.Print(configPath)
-				color.Set(color.ResetBold)
-				fmt.Println(":")
-				color.Unset()
-				fmt.Fprintln(os.Stderr, err)
-				return
-			}
-		}
+	paths := configPaths(cmd)
+	configPath := paths[0]
 
-		section, err := parser.ParseFile(configPath)
-		if err != nil {
+	if _, err := os.Stat(configPath); errors.Is(err, fs.ErrNotExist) {
+		if dryRun {
 			color.Set(color.FgRed)
-			fmt.Print("Error parsing configuration file: ")
+			fmt.Print("Configuration file not found: ")
 			color.Set(color.Bold)
 			fmt.Print(configPath)
 			color.Set(color.ResetBold)
-			fmt.Println(":")
-			color.Unset()
-			fmt.Fprintln(os.Stderr, err)
-			return
-		}
-
-		if Verify(section) == "" {
-			color.Set(color.FgGreen, color.Bold)
-			fmt.Println("Configuration is valid.")
-			color.Unset()
-		} else {
-			color.Set(color.FgRed, color.Bold)
-			fmt.Println("Configuration is invalid.")
+			fmt.Println(".")
 			color.Unset()
+			exitCode = 1
 			return
 		}
 
-		previousSection, _ := parser.Parse("")
-
-		if _, err := os.Stat(configPath + ".prev"); !errors.Is(err, fs.ErrNotExist) {
-			if err != nil {
-				color.Set(color.FgRed)
-				fmt.Print("Error checking for previous configuration file: ")
-				color.Set(color.Bold)
-				fmt.Print(configPath + ".prev")
-				color.Set(color.ResetBold)
-				fmt.Println(":")
-				color.Unset()
-				fmt.Fprintln(os.Stderr, err)
-				return
-			}
-			previousSection, err = parser.ParseFile(configPath + ".prev")
-			if err != nil {
-				color.Set(color.FgRed)
-				fmt.Print("Error parsing previous configuration file: ")
-				color.Set(color.Bold)
-				fmt.Print(configPath + ".prev")
-				color.Set(color.ResetBold)
-				fmt.Println(":")
-				color.Unset()
-				fmt.Fprintln(os.Stderr, err)
-				return
-			}
-		}
-
-		if up, _ := cmd.PersistentFlags().GetBool("upgrade"); up {
-			if err := Upgrade(section); err != nil {
-				color.Set(color.FgRed)
-				fmt.Print("Error upgrading system: ")
-				color.Set(color.Bold)
-				fmt.Print(configPath)
-				color.Set(color.ResetBold)
-				fmt.Println(":")
-				color.Unset()
-				fmt.Fprintln(os.Stderr, err)
-				return
-			}
-			color.Set(color.FgGreen, color.Bold)
-			fmt.Println("System upgraded successfully.")
+		if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error creating configuration directory: ")
+			color.Set(color.Bold)
+			fmt.Print(filepath.Dir(configPath))
+			color.Set(color.ResetBold)
+			fmt.Println(":")
 			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
 			return
 		}
 
-		if err := Apply(section, previousSection); err != nil {
+		if err := os.WriteFile(configPath, []byte(defaultConfig), 0o644); err != nil {
 			color.Set(color.FgRed)
-			fmt.Print("Error applying configuration: ")
+			fmt.Print("Error creating configuration file: ")
 			color.Set(color.Bold)
 			fmt.Print(configPath)
 			color.Set(color.ResetBold)
 			fmt.Println(":")
 			color.Unset()
 			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
 			return
 		}
+	}
 
-		if _, err := os.Stat(configPath + ".prev"); !errors.Is(err, fs.ErrNotExist) {
-			if err != nil {
-				color.Set(color.FgRed)
-				fmt.Print("Error checking for previous configuration file: ")
-				color.Set(color.Bold)
-				fmt.Print(configPath + ".prev")
-				color.Set(color.ResetBold)
-				fmt.Println(":")
-				color.Unset()
-				fmt.Fprintln(os.Stderr, err)
-				return
-			}
-			if err := os.Remove(configPath + ".prev"); err != nil {
-				color.Set(color.FgRed)
-				fmt.Print("Error removing previous configuration file: ")
-				color.Set(color.Bold)
-				fmt.Print(configPath + ".prev")
-				color.Set(color.ResetBold)
-				fmt.Println(":")
-				color.Unset()
-				fmt.Fprintln(os.Stderr, err)
-				return
-			}
-		}
-		content := section.Marshal(0)
-		if err := os.WriteFile(configPath+".prev", []byte(content), 0o644); err != nil {
+	section, _, err := parseConfigWithTags(cmd, paths)
+	if err != nil {
+		color.Set(color.FgRed)
+		fmt.Print("Error parsing configuration file: ")
+		color.Set(color.Bold)
+		fmt.Print(configPath)
+		color.Set(color.ResetBold)
+		fmt.Println(":")
+		color.Unset()
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+		return
+	}
+
+	ds := Verify(section)
+	printDiagnostics(ds)
+	if !ds.HasErrors() {
+		color.Set(color.FgGreen, color.Bold)
+		fmt.Println("Configuration is valid.")
+		color.Unset()
+	} else {
+		color.Set(color.FgRed, color.Bold)
+		fmt.Println("Configuration is invalid.")
+		color.Unset()
+		exitCode = 1
+		return
+	}
+
+	st, err := loadState(configPath)
+	if err != nil {
+		color.Set(color.FgRed)
+		fmt.Print("Error loading state file: ")
+		color.Set(color.Bold)
+		fmt.Print(state.DefaultPath)
+		color.Set(color.ResetBold)
+		fmt.Println(":")
+		color.Unset()
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+		return
+	}
+	if dryRun {
+		// Keep the state in memory so that planning never writes it
+		st.Path = ""
+	}
+
+	if up, _ := cmd.Flags().GetBool("upgrade"); up {
+		if err := Upgrade(section); err != nil {
 			color.Set(color.FgRed)
-			fmt.Print("Error creating previous configuration file: ")
+			fmt.Print("Error upgrading system: ")
 			color.Set(color.Bold)
-			fmt.Print(configPath + ".prev")
+			fmt.Print(configPath)
 			color.Set(color.ResetBold)
 			fmt.Println(":")
 			color.Unset()
 			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
 			return
 		}
+		color.Set(color.FgGreen, color.Bold)
+		fmt.Println("System upgraded successfully.")
+		color.Unset()
+		return
+	}
+
+	if dryRun {
+		color.Set(color.FgCyan, color.Bold)
+		fmt.Println("\nPlanned actions:")
+		color.Unset()
+	}
+
+	if err := Apply(section, st); err != nil {
+		color.Set(color.FgRed)
+		fmt.Print("Error applying configuration: ")
+		color.Set(color.Bold)
+		fmt.Print(configPath)
+		color.Set(color.ResetBold)
+		fmt.Println(":")
+		color.Unset()
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+		return
+	}
 
+	if dryRun {
 		color.Set(color.FgGreen, color.Bold)
-		fmt.Println("\nConfiguration applied successfully.")
+		fmt.Println("\nDry run complete. No changes were made.")
 		color.Unset()
-	},
+		return
+	}
+
+	if err := saveGeneration(configPath, section, st); err != nil {
+		color.Set(color.FgRed)
+		fmt.Print("Error saving generation: ")
+		color.Set(color.Bold)
+		fmt.Print(state.DefaultGenerationsDir)
+		color.Set(color.ResetBold)
+		fmt.Println(":")
+		color.Unset()
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+		return
+	}
+
+	// The previous configuration file is replaced by the state file
+	if err := os.Remove(configPath + ".prev"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		color.Set(color.FgRed)
+		fmt.Print("Error removing previous configuration file: ")
+		color.Set(color.Bold)
+		fmt.Print(configPath + ".prev")
+		color.Set(color.ResetBold)
+		fmt.Println(":")
+		color.Unset()
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+		return
+	}
+
+	color.Set(color.FgGreen, color.Bold)
+	fmt.Println("\nConfiguration applied successfully.")
+	color.Unset()
 }
 
-func Apply(section *parser.Section, previousSection *parser.Section) error {
-	modules.PrivilegeEscalationCommand = section.GetFirst("essentials/privilige_escalation", "sudo")
+// Apply applies the configuration to the system.
+// The state is used as the previous side of every diff, and is updated as each resource is applied.
+func Apply(section *parser.Section, st *state.State) error {
+	modules.PrivilegeEscalationCommand = section.GetFirst("essentials/privilege_escalation", schema.Default("essentials/privilege_escalation"))
 	if modules.PrivilegeEscalationCommand == "su" {
 		modules.PrivilegeEscalationCommand = "su -c"
 	}
@@ -209,44 +234,50 @@ func Apply(section *parser.Section, previousSection *parser.Section) error {
 		return err
 	}
 
-	if err := applyUsers(section, previousSection); err != nil {
+	if err := applyUsers(section, st); err != nil {
 		return fmt.Errorf("error applying user configuration: %w", err)
 	}
 
-	modules.PrimaryUser = section.GetFirst("users/primary_user", "nobody")
+	modules.PrimaryUser = section.GetFirst("users/primary_user", schema.Default("users/primary_user"))
+	configureProviders(section)
 
-	if err := applyKernels(section, previousSection); err != nil {
+	if err := applyKernels(section, st); err != nil {
 		return fmt.Errorf("error applying kernel configuration: %w", err)
 	}
 
-	if err := applyBootloader(section, previousSection); err != nil {
+	if err := applyBootloader(section, st); err != nil {
 		return fmt.Errorf("error applying bootloader configuration: %w", err)
 	}
 
-	if err := applyPacman(section, previousSection); err != nil {
+	if err := applyPacman(section, st); err != nil {
 		return fmt.Errorf("error applying pacman configuration: %w", err)
 	}
 
-	if err := applyAUR(section, previousSection); err != nil {
+	if err := applyAUR(section, st); err != nil {
 		return fmt.Errorf("error applying AUR configuration: %w", err)
 	}
 
-	if err := applyFlatpak(section, previousSection); err != nil {
+	if err := applyFlatpak(section, st); err != nil {
 		return fmt.Errorf("error applying Flatpak configuration: %w", err)
 	}
 
-	if err := applyNetworkHandler(section, previousSection); err != nil {
+	if err := applyNetworkHandler(section, st); err != nil {
 		return fmt.Errorf("error applying network handler configuration: %w", err)
 	}
 
 	return nil
 }
 
-func applyKernels(section *parser.Section, previousSection *parser.Section) error {
-	hookSections := getAllSections(section, "packages/pacman/hook")
+func applyKernels(section *parser.Section, st *state.State) error {
+	kernels, err := newPackageSet("pacman", "kernel", tagSet.GetAll(section, "essentials/kernel"), st.GetPackages("kernel"), getAllSections(section, "packages/pacman/hook"))
+	if err != nil {
+		return err
+	}
 
-	kernelList := modules.NewPackageList(modules.PacmanInstall, modules.PacmanRemove)
-	addedKernels, removedKernels := utils.GetDifferences(tagSet.GetAll(section, "essentials/kernel"), tagSet.GetAll(previousSection, "essentials/kernel"))
+	addedKernels, removedKernels, err := kernels.differences()
+	if err != nil {
+		return err
+	}
 
 	// Installing a kernel before removing any just to be safe
 	if len(addedKernels) > 0 {
@@ -254,181 +285,61 @@ func applyKernels(section *parser.Section, previousSection *parser.Section) erro
 		pkgNamesString := kernelPackageNames[len(kernelPackageNames)-1]
 		pkgNamesString = strings.SplitN(pkgNamesString, ",", 2)[0]
 		pkgNames := strings.Fields(pkgNamesString)
-		for _, pkgName := range pkgNames {
-			pkg := modules.NewPackage(pkgName)
-			for _, hookSection := range hookSections {
-				if hookSection.GetFirst("package", "") == pkgName {
-					if err := pkg.AddHook(hookSection); err != nil {
-						return fmt.Errorf("error adding hook for package %s: %w", pkgName, err)
-					}
-				}
-			}
-			kernelList.Add(pkg)
+		if err := kernels.install(pkgNames); err != nil {
+			return err
 		}
 
 		addedKernels = slices.DeleteFunc(addedKernels, func(s string) bool {
 			return slices.Contains(pkgNames, s)
 		})
-
-		if err := kernelList.Install(); err != nil {
-			return err
-		}
-		kernelList.Clear()
-	}
-
-	for _, pkgName := range removedKernels {
-		pkg := modules.NewPackage(pkgName)
-		for _, hookSection := range hookSections {
-			if hookSection.GetFirst("package", "") == pkgName {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", pkgName, err)
-				}
-			}
-		}
-		kernelList.Add(pkg)
-	}
-	if err := kernelList.Remove(); err != nil {
-		return err
 	}
-	kernelList.Clear()
 
-	for _, pkgName := range addedKernels {
-		pkg := modules.NewPackage(pkgName)
-		for _, hookSection := range hookSections {
-			if hookSection.GetFirst("package", "") == pkgName {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", pkgName, err)
-				}
-			}
-		}
-		kernelList.Add(pkg)
-	}
-	if err := kernelList.Install(); err != nil {
+	if err := kernels.remove(removedKernels); err != nil {
 		return err
 	}
-
-	return nil
-}
-
-func applyBootloader(section *parser.Section, previousSection *parser.Section) error {
-	hookSections := getAllSections(section, "packages/pacman/hook")
-
-	bootloaderList := modules.NewPackageList(modules.PacmanInstall, modules.PacmanRemove)
-	addedBootloader, removedBootloader := utils.GetDifferences(strings.Fields(section.GetFirst("essentials/bootloader", "grub efibootmgr")), strings.Fields(previousSection.GetFirst("essentials/bootloader", "")))
-
-	for _, pkgName := range removedBootloader {
-		pkg := modules.NewPackage(pkgName)
-		for _, hookSection := range hookSections {
-			if hookSection.GetFirst("package", "") == pkgName {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", pkgName, err)
-				}
-			}
-		}
-		bootloaderList.Add(pkg)
-	}
-	if err := bootloaderList.Remove(); err != nil {
+	if err := st.RemovePackages("kernel", removedKernels); err != nil {
 		return err
 	}
-	bootloaderList.Clear()
 
-	for _, pkgName := range addedBootloader {
-		pkg := modules.NewPackage(pkgName)
-		for _, hookSection := range hookSections {
-			if hookSection.GetFirst("package", "") == pkgName {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", pkgName, err)
-				}
-			}
-		}
-		bootloaderList.Add(pkg)
-	}
-	if err := bootloaderList.Install(); err != nil {
+	// The remaining kernels are installed in reverse order, so that the top kernel is installed last and is the default
+	slices.Reverse(addedKernels)
+	if err := kernels.install(addedKernels); err != nil {
 		return err
 	}
 
-	return nil
+	return st.SetPackages("kernel", kernels.current)
 }
 
-func applyNetworkHandler(section *parser.Section, previousSection *parser.Section) error {
-	hookSections := getAllSections(section, "packages/pacman/hook")
-
-	networkHandlerList := modules.NewPackageList(modules.PacmanInstall, modules.PacmanRemove)
-	addedNetworkHandler, removedNetworkHandler := utils.GetDifferences(strings.Fields(section.GetFirst("essentials/network_handler", "networkmanager")), strings.Fields(previousSection.GetFirst("essentials/network_handler", "")))
-
-	for _, pkgName := range removedNetworkHandler {
-		pkg := modules.NewPackage(pkgName)
-		for _, hookSection := range hookSections {
-			if hookSection.GetFirst("package", "") == pkgName {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", pkgName, err)
-				}
-			}
-		}
-		networkHandlerList.Add(pkg)
-	}
-	if err := networkHandlerList.Remove(); err != nil {
+func applyBootloader(section *parser.Section, st *state.State) error {
+	bootloader, err := newPackageSet("pacman", "bootloader", section.GetList("essentials/bootloader", schema.Default("essentials/bootloader")), st.GetPackages("bootloader"), getAllSections(section, "packages/pacman/hook"))
+	if err != nil {
 		return err
 	}
-	networkHandlerList.Clear()
+	return bootloader.apply(st)
+}
 
-	for _, pkgName := range addedNetworkHandler {
-		pkg := modules.NewPackage(pkgName)
-		for _, hookSection := range hookSections {
-			if hookSection.GetFirst("package", "") == pkgName {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", pkgName, err)
-				}
-			}
-		}
-		networkHandlerList.Add(pkg)
-	}
-	if err := networkHandlerList.Install(); err != nil {
+func applyNetworkHandler(section *parser.Section, st *state.State) error {
+	networkHandler, err := newPackageSet("pacman", "network_handler", section.GetList("essentials/network_handler", schema.Default("essentials/network_handler")), st.GetPackages("network_handler"), getAllSections(section, "packages/pacman/hook"))
+	if err != nil {
 		return err
 	}
-
-	return nil
+	return networkHandler.apply(st)
 }
 
-func applyUsers(section *parser.Section, previousSection *parser.Section) error {
+func applyUsers(section *parser.Section, st *state.State) error {
 	hookSections := getAllSections(section, "users/hook")
 
-	currentUserSections := getAllSections(section, "users/user")
-	previousUserSections := getAllSections(previousSection, "users/user")
-
-	currentUsers := make(map[string]modules.User)
-	previousUsers := make(map[string]modules.User)
-
-	for _, userSection := range currentUserSections {
-		user, err := modules.UserFrom(userSection)
-		if err != nil {
-			return fmt.Errorf("error parsing user configuration: %w", err)
-		}
-		currentUsers[user.Username] = user
-	}
-
-	for _, userSection := range previousUserSections {
-		user, err := modules.UserFrom(userSection)
-		if err != nil {
-			return fmt.Errorf("error parsing previous user configuration: %w", err)
-		}
-		previousUsers[user.Username] = user
-	}
-
-	currentUsernames := make([]string, 0, len(currentUsers))
-	previousUsernames := make([]string, 0, len(previousUsers))
-
-	for username := range currentUsers {
-		currentUsernames = append(currentUsernames, username)
+	currentUsers, err := getUsers(section)
+	if err != nil {
+		return fmt.Errorf("error parsing user configuration: %w", err)
 	}
-	for username := range previousUsers {
-		previousUsernames = append(previousUsernames, username)
+	addedUsernames, removedUsernames, modifiedUsernames, err := userDifferences(currentUsers, st.Usernames())
+	if err != nil {
+		return err
 	}
 
-	addedUsernames, removedUsernames := utils.GetDifferences(currentUsernames, previousUsernames)
-
 	for _, username := range removedUsernames {
-		user := previousUsers[username]
+		user := st.Users[username]
 
 		for _, hookSection := range hookSections {
 			hookUser := hookSection.GetFirst("user", "")
@@ -448,6 +359,9 @@ func applyUsers(section *parser.Section, previousSection *parser.Section) error
 		if err := modules.DeleteUser(username, user.CreateHome); err != nil {
 			return fmt.Errorf("error deleting user %s: %w", username, err)
 		}
+		if err := st.RemoveUser(username); err != nil {
+			return err
+		}
 
 		for _, hookSection := range hookSections {
 			hookUser := hookSection.GetFirst("user", "")
@@ -486,6 +400,9 @@ func applyUsers(section *parser.Section, previousSection *parser.Section) error
 		if err := modules.CreateUser(user); err != nil {
 			return fmt.Errorf("error creating user %s: %w", username, err)
 		}
+		if err := st.SetUser(user); err != nil {
+			return err
+		}
 
 		for _, hookSection := range hookSections {
 			hookUser := hookSection.GetFirst("user", "")
@@ -503,156 +420,83 @@ func applyUsers(section *parser.Section, previousSection *parser.Section) error
 		}
 	}
 
-	for username, currentUser := range currentUsers {
-		if previousUser, exists := previousUsers[username]; exists {
-			needsModification := currentUser.FullName != previousUser.FullName ||
-				currentUser.Shell != previousUser.Shell ||
-				currentUser.CreateHome != previousUser.CreateHome ||
-				currentUser.HomeDir != previousUser.HomeDir
+	for _, username := range modifiedUsernames {
+		currentUser := currentUsers[username]
 
-			// Check if groups are different
-			if !needsModification {
-				addedGroups, removedGroups := utils.GetDifferences(currentUser.Groups, previousUser.Groups)
-				needsModification = len(addedGroups) > 0 || len(removedGroups) > 0
-			}
-
-			if needsModification {
-				if err := modules.ModifyUser(previousUser, currentUser); err != nil {
-					return fmt.Errorf("error modifying user %s: %w", username, err)
-				}
-			}
+		actualUser, _, err := modules.LookupUser(username)
+		if err != nil {
+			return fmt.Errorf("error looking up user %s: %w", username, err)
 		}
-	}
-
-	return nil
-}
-
-func applyPacman(section *parser.Section, previousSection *parser.Section) error {
-	hookSections := getAllSections(section, "packages/pacman/hook")
 
-	pacmanList := modules.NewPackageList(modules.PacmanInstall, modules.PacmanRemove)
-	addedPacmanPackages, removedPacmanPackages := utils.GetDifferences(tagSet.GetAll(section, "packages/pacman/package"), tagSet.GetAll(previousSection, "packages/pacman/package"))
-
-	for _, pkgName := range removedPacmanPackages {
-		pkg := modules.NewPackage(pkgName)
-		for _, hookSection := range hookSections {
-			if hookSection.GetFirst("package", "") == pkgName {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", pkgName, err)
-				}
-			}
+		if err := modules.ModifyUser(managedUser(currentUser, actualUser), currentUser); err != nil {
+			return fmt.Errorf("error modifying user %s: %w", username, err)
 		}
-		pacmanList.Add(pkg)
-	}
-	if err := pacmanList.Remove(); err != nil {
-		return err
 	}
-	pacmanList.Clear()
 
-	for _, pkgName := range addedPacmanPackages {
-		pkg := modules.NewPackage(pkgName)
-		for _, hookSection := range hookSections {
-			if hookSection.GetFirst("package", "") == pkgName {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", pkgName, err)
-				}
-			}
+	// Record users that already matched their configuration
+	for _, user := range currentUsers {
+		if err := st.SetUser(user); err != nil {
+			return err
 		}
-		pacmanList.Add(pkg)
-	}
-	if err := pacmanList.Install(); err != nil {
-		return err
 	}
 
 	return nil
 }
 
-func applyAUR(section *parser.Section, previousSection *parser.Section) error {
-	hookSections := getAllSections(section, "packages/aur/hook")
-
-	aurHelper := section.GetFirst("packages/aur/helper", "makepkg")
-	aurInstall := func(pkgs interface{}) error { return modules.AURInstall(aurHelper, pkgs) }
-	aurList := modules.NewPackageList(aurInstall, modules.PacmanRemove)
-	addedAurPackages, removedAurPackages := utils.GetDifferences(tagSet.GetAll(section, "packages/aur/package"), tagSet.GetAll(previousSection, "packages/aur/package"))
-
-	for _, pkgName := range removedAurPackages {
-		pkg := modules.NewPackage(pkgName)
-		for _, hookSection := range hookSections {
-			if hookSection.GetFirst("package", "") == pkgName {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", pkgName, err)
-				}
-			}
-		}
-		aurList.Add(pkg)
+func applyPacman(section *parser.Section, st *state.State) error {
+	pacmanPackages, err := newPackageSet("pacman", "pacman", tagSet.GetAll(section, "packages/pacman/package"), st.GetPackages("pacman"), getAllSections(section, "packages/pacman/hook"))
+	if err != nil {
+		return err
 	}
-	if err := aurList.Remove(); err != nil {
+	if err := pacmanPackages.apply(st); err != nil {
 		return err
 	}
-	aurList.Clear()
 
-	for _, pkgName := range addedAurPackages {
-		pkg := modules.NewPackage(pkgName)
-		for _, hookSection := range hookSections {
-			if hookSection.GetFirst("package", "") == pkgName {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", pkgName, err)
-				}
-			}
-		}
-		aurList.Add(pkg)
+	return applyStrictPackages(section, "packages/pacman", false, pacmanPackages)
+}
+
+func applyAUR(section *parser.Section, st *state.State) error {
+	aurPackages, err := newPackageSet("aur", "aur", tagSet.GetAll(section, "packages/aur/package"), st.GetPackages("aur"), getAllSections(section, "packages/aur/hook"))
+	if err != nil {
+		return err
 	}
-	if err := aurList.Install(); err != nil {
+	if err := aurPackages.apply(st); err != nil {
 		return err
 	}
 
-	return nil
+	return applyStrictPackages(section, "packages/aur", true, aurPackages)
 }
 
-func applyFlatpak(section *parser.Section, previousSection *parser.Section) error {
-	hookSections := getAllSections(section, "packages/flatpak/hook")
-
-	autoInstallString := section.GetFirst("packages/flatpak/auto_install", "true")
-	autoInstall, err := strconv.ParseBool(autoInstallString)
+func applyFlatpak(section *parser.Section, st *state.State) error {
+	autoInstall, err := section.GetBool("packages/flatpak/auto_install", schema.Default("packages/flatpak/auto_install"))
 	if err != nil {
-		return fmt.Errorf("invalid value for 'auto_install' field in Flatpak section: %s", autoInstallString)
+		return err
 	}
 	if autoInstall {
 		flatpakPackages := getFlatpakPackages(section)
 		if len(flatpakPackages) > 0 {
-			if err := modules.PacmanInstall("flatpak"); err != nil {
+			if err := modules.PacmanInstall([]string{"flatpak"}); err != nil {
 				return err
 			}
 		}
 	}
 
 	currentRemotes := getAllSections(section, "packages/flatpak/remote")
-	previousRemotes := getAllSections(previousSection, "packages/flatpak/remote")
-
-	addedRemotes, removedRemotes := utils.GetDifferences(getFlatpakRemoteIdentifiers(currentRemotes), getFlatpakRemoteIdentifiers(previousRemotes))
-
-	previousRemoteMap := make(map[string]modules.FlatpakRemote)
-	for _, remote := range previousRemotes {
-		name := remote.GetFirst("name", "")
-		if name == "" {
-			continue
-		}
-
-		remoteObj, err := modules.FlatpakRemoteFrom(remote)
-		if err != nil {
-			return fmt.Errorf("error processing previous remote %s: %w", name, err)
-		}
 
-		identifier := createFlatpakIdentifier(remoteObj.Name, remoteObj.Installation, remoteObj.UserInstallation)
-		previousRemoteMap[identifier] = remoteObj
+	addedRemotes, removedRemotes, err := flatpakRemoteDifferences(currentRemotes, st.FlatpakRemoteIdentifiers())
+	if err != nil {
+		return err
 	}
 
 	// Remove remotes that are no longer configured
 	for _, identifier := range removedRemotes {
-		prevRemote := previousRemoteMap[identifier]
+		prevRemote := st.FlatpakRemotes[identifier]
 		if err := modules.FlatpakRemoveRemote(prevRemote); err != nil {
 			return err
 		}
+		if err := st.RemoveFlatpakRemote(identifier); err != nil {
+			return err
+		}
 	}
 
 	for _, remote := range currentRemotes {
@@ -661,116 +505,56 @@ func applyFlatpak(section *parser.Section, previousSection *parser.Section) erro
 			return err
 		}
 
-		identifier := createFlatpakIdentifier(remoteObj.Name, remoteObj.Installation, remoteObj.UserInstallation)
+		identifier := modules.FlatpakIdentifier(remoteObj.Name, remoteObj.Installation, remoteObj.UserInstallation)
 
 		if slices.Contains(addedRemotes, identifier) {
 			if err := modules.FlatpakAddRemote(remoteObj); err != nil {
 				return err
 			}
-		} else if remoteObj != previousRemoteMap[identifier] {
+		} else if remoteObj != st.FlatpakRemotes[identifier] {
 			if err := modules.FlatpakModifyRemote(remoteObj); err != nil {
 				return fmt.Errorf("error updating remote %s: %w", remoteObj.Name, err)
 			}
 		}
-	}
-
-	flatpakList := modules.NewPackageList(modules.FlatpakInstall, modules.FlatpakRemove)
-	currentFlatpakPackages := getFlatpakPackages(section)
-	previousFlatpakPackages := getFlatpakPackages(previousSection)
-	addedFlatpakPackages, removedFlatpakPackages := utils.GetDifferences(
-		getFlatpakPackageIdentifiers(currentFlatpakPackages),
-		getFlatpakPackageIdentifiers(previousFlatpakPackages),
-	)
-
-	addedPackageObjs := make(map[string]modules.FlatpakPackage)
-	for _, pkgIdentifier := range addedFlatpakPackages {
-		for _, pkg := range currentFlatpakPackages {
-			identifier := createFlatpakIdentifier(pkg.Name, pkg.Installation, pkg.UserInstallation)
-			if identifier == pkgIdentifier {
-				addedPackageObjs[pkgIdentifier] = pkg
-				break
-			}
-		}
-	}
-
-	removedPackageObjs := make(map[string]modules.FlatpakPackage)
-	for _, pkgIdentifier := range removedFlatpakPackages {
-		for _, pkg := range previousFlatpakPackages {
-			identifier := createFlatpakIdentifier(pkg.Name, pkg.Installation, pkg.UserInstallation)
-			if identifier == pkgIdentifier {
-				removedPackageObjs[pkgIdentifier] = pkg
-				break
-			}
+		if err := st.SetFlatpakRemote(identifier, remoteObj); err != nil {
+			return err
 		}
 	}
 
-	for _, pkgIdentifier := range removedFlatpakPackages {
-		flatpakPackage := removedPackageObjs[pkgIdentifier]
-		pkg := modules.NewPackage(flatpakPackage)
-
-		for _, hookSection := range hookSections {
-			name := hookSection.GetFirst("package", "")
-			if name == flatpakPackage.Name || name == pkgIdentifier {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", flatpakPackage.Name, err)
-				}
-			}
-		}
-		flatpakList.Add(pkg)
-	}
-	if err := flatpakList.Remove(); err != nil {
+	currentFlatpakPackages := getFlatpakPackages(section)
+	if err := st.SetFlatpakPackageValues(currentFlatpakPackages); err != nil {
 		return err
 	}
-	flatpakList.Clear()
 
-	for _, pkgIdentifier := range addedFlatpakPackages {
-		flatpakPackage := addedPackageObjs[pkgIdentifier]
-		pkg := modules.NewPackage(flatpakPackage)
-
-		for _, hookSection := range hookSections {
-			name := hookSection.GetFirst("package", "")
-			if name == flatpakPackage.Name || name == pkgIdentifier {
-				if err := pkg.AddHook(hookSection); err != nil {
-					return fmt.Errorf("error adding hook for package %s: %w", flatpakPackage.Name, err)
-				}
-			}
-		}
-		flatpakList.Add(pkg)
+	flatpakPackages, err := newPackageSet("flatpak", "flatpak", currentFlatpakPackages, st.FlatpakPackageList(), getAllSections(section, "packages/flatpak/hook"))
+	if err != nil {
+		return err
 	}
-	if err := flatpakList.Install(); err != nil {
+	if err := flatpakPackages.apply(st); err != nil {
 		return err
 	}
 
-	return nil
+	return applyStrictFlatpakPackages(section, flatpakPackages)
 }
 
 func Upgrade(section *parser.Section) error {
-	modules.PrivilegeEscalationCommand = section.GetFirst("essentials/privilige_escalation", "sudo")
+	modules.PrivilegeEscalationCommand = section.GetFirst("essentials/privilege_escalation", schema.Default("essentials/privilege_escalation"))
 	if modules.PrivilegeEscalationCommand == "su" {
 		modules.PrivilegeEscalationCommand = "su -c"
 	}
 
-	modules.PrimaryUser = section.GetFirst("users/primary_user", "nobody")
-
-	pacmanPackageCount := len(section.GetAll("packages/pacman/package"))
-	pacmanConfigured := pacmanPackageCount > 0
-
-	aurHelper := section.GetFirst("packages/aur/helper", "")
-	aurPackageCount := len(section.GetAll("packages/aur/package"))
-	aurConfigured := aurHelper != "" && aurPackageCount > 0
-
-	flatpakPackageCount := len(section.GetAll("packages/flatpak/package")) + len(section.GetAll("packages/flatpak/package/name"))
-	flatpakConfigured := flatpakPackageCount > 0
+	modules.PrimaryUser = section.GetFirst("users/primary_user", schema.Default("users/primary_user"))
+	configureProviders(section)
 
 	availableUpgrades := []string{}
-	if pacmanConfigured {
-		availableUpgrades = append(availableUpgrades, "pacman:system packages via Pacman")
-	}
-	if aurConfigured {
-		availableUpgrades = append(availableUpgrades, "aur:AUR packages via "+aurHelper)
-	}
-	if flatpakConfigured {
-		availableUpgrades = append(availableUpgrades, "flatpak:Flatpak packages")
+	for _, provider := range modules.Providers() {
+		if !providerConfigured(section, provider) {
+			continue
+		}
+		if checker, ok := provider.(modules.UpgradeChecker); ok && !checker.CanUpgrade() {
+			continue
+		}
+		availableUpgrades = append(availableUpgrades, provider.Name()+":"+provider.Description())
 	}
 
 	if len(availableUpgrades) == 0 {
@@ -782,37 +566,32 @@ func Upgrade(section *parser.Section) error {
 
 	toUpgrade := confirmUpgradeAll(availableUpgrades)
 
-	if slices.Contains(toUpgrade, "pacman") {
-		color.Set(color.FgCyan)
-		fmt.Println("Upgrading system packages via Pacman...")
-		color.Unset()
-
-		if err := modules.PacmanSystemUpgrade(); err != nil {
-			return err
+	for _, provider := range modules.Providers() {
+		if !slices.Contains(toUpgrade, provider.Name()) {
+			continue
 		}
-	}
 
-	if slices.Contains(toUpgrade, "aur") {
 		color.Set(color.FgCyan)
-		fmt.Println("Upgrading AUR packages via " + aurHelper + "...")
+		fmt.Println("Upgrading " + provider.Description() + "...")
 		color.Unset()
 
-		if err := modules.PacmanWrapperSystemUpgrade(aurHelper); err != nil {
+		if err := provider.Upgrade(); err != nil {
 			return err
 		}
 	}
 
-	if slices.Contains(toUpgrade, "flatpak") {
-		color.Set(color.FgCyan)
-		fmt.Println("Upgrading Flatpak packages...")
-		color.Unset()
+	return nil
+}
 
-		if err := modules.FlatpakSystemUpgrade(); err != nil {
-			return err
-		}
-	}
+// configureProviders updates the registered providers with their settings from the configuration.
+func configureProviders(section *parser.Section) {
+	modules.RegisterProvider(modules.AURProvider{Helper: section.GetFirst("packages/aur/helper", schema.Default("packages/aur/helper"))})
+}
 
-	return nil
+// providerConfigured reports whether any packages are declared for a provider.
+func providerConfigured(section *parser.Section, provider modules.RegisteredProvider) bool {
+	sectionPath := "packages/" + provider.Name()
+	return len(section.GetAll(sectionPath+"/package")) > 0 || len(section.GetAll(sectionPath+"/package/name")) > 0
 }
 
 // confirmUpgrade asks the user whether they want to upgrade a specific package manager
@@ -879,7 +658,7 @@ func confirmUpgradeAll(availableUpgrades []string) []string {
 
 // transformBooleanOption converts a boolean string ("true"/"false") to its pacman boolean representation.
 func transformBooleanOption(value string) string {
-	if val, err := strconv.ParseBool(value); err == nil && val {
+	if val, err := parser.ParseBool(value); err == nil && val {
 		return "~BOOL"
 	}
 	return ""
@@ -890,10 +669,9 @@ func configurePacman(section *parser.Section) error {
 	pacmanParser := ini.NewPacmanParser()
 	pacmanPatcher := &ini.Patcher{}
 
-	replaceCommentsString := section.GetFirst("config_parser/replace_comments", "true")
-	replaceComments, err := strconv.ParseBool(replaceCommentsString)
+	replaceComments, err := section.GetBool("config_parser/replace_comments", schema.Default("config_parser/replace_comments"))
 	if err != nil {
-		return fmt.Errorf("invalid value for 'replace_comments' field in config_parser section: %s", replaceCommentsString)
+		return err
 	}
 	pacmanPatcher.ReplaceComments = replaceComments
 
@@ -907,25 +685,18 @@ func configurePacman(section *parser.Section) error {
 		}
 	}
 
-	addPacmanOption("Color", transformBooleanOption(section.GetFirst("packages/pacman/color", "false")))
+	addPacmanOption("Color", transformBooleanOption(section.GetFirst("packages/pacman/color", schema.Default("packages/pacman/color"))))
 	addPacmanOption("ParallelDownloads", section.GetFirst("packages/pacman/parallel_downloads", ""))
-	addPacmanOption("VerbosePkgLists", transformBooleanOption(section.GetFirst("packages/pacman/verbose_pkg_lists", "false")))
-	addPacmanOption("ILoveCandy", transformBooleanOption(section.GetFirst("packages/pacman/i_love_candy", "false")))
-
-	builtinRepositories := []string{
-		"core-testing",
-		"core",
-		"extra-testing",
-		"extra",
-		"multilib-testing",
-		"multilib",
-	}
+	addPacmanOption("VerbosePkgLists", transformBooleanOption(section.GetFirst("packages/pacman/verbose_pkg_lists", schema.Default("packages/pacman/verbose_pkg_lists"))))
+	addPacmanOption("ILoveCandy", transformBooleanOption(section.GetFirst("packages/pacman/i_love_candy", schema.Default("packages/pacman/i_love_candy"))))
 
-	// Add pacman repositories
+	// Add pacman repositories, keeping their declared order since it sets their priority
 	repositories := getAllSections(section, "packages/pacman/repository")
+	repoOrder := []string{}
 	for _, repo := range repositories {
 		repoName := repo.GetFirst("name", "")
 		if repoName != "" {
+			repoOrder = append(repoOrder, repoName)
 			repoModifications := map[string]interface{}{
 				"Include": repo.GetFirst("include", ""),
 				"Server":  repo.GetFirst("server", ""),
@@ -944,7 +715,24 @@ func configurePacman(section *parser.Section) error {
 		}
 	}
 
+	if len(repoOrder) > 0 {
+		pacmanModifications["~ORDER"] = repoOrder
+	}
+
 	if len(pacmanModifications) > 0 {
+		if utils.DryRun {
+			original, err := os.ReadFile(pacmanConfigPath)
+			if err != nil {
+				return err
+			}
+			patched, err := pacmanPatcher.Preview(pacmanParser, pacmanConfigPath, pacmanModifications)
+			if err != nil {
+				return err
+			}
+			printPlannedPatch(pacmanConfigPath, original, patched)
+			return nil
+		}
+
 		if err := pacmanPatcher.Patch(pacmanParser, pacmanConfigPath, pacmanModifications); err != nil {
 			return err
 		}
@@ -953,44 +741,98 @@ func configurePacman(section *parser.Section) error {
 	return nil
 }
 
+// getAllSections returns the sections at a path, which can be a query, that match the current tag set.
+// Sections whose `tags` key doesn't match are skipped, along with their sub-sections.
 func getAllSections(section *parser.Section, key string) []*parser.Section {
-	parts := strings.Split(key, "/")
-	if len(parts) == 0 {
-		return []*parser.Section{}
+	sections, _ := section.QuerySections(key, func(subSection *parser.Section) bool {
+		return tagSet == nil || tagSet.IncludesSection(subSection)
+	})
+	return sections
+}
+
+// loadState loads the applied state.
+// If there is no state file yet, the state is created from the previous configuration file written by older versions.
+func loadState(configPath string) (*state.State, error) {
+	st, exists, err := state.Load(state.DefaultPath)
+	if err != nil || exists {
+		return st, err
 	}
 
-	if len(parts) == 1 {
-		if sections, ok := section.Sections[parts[0]]; ok {
-			return sections
+	previousSection, _ := parser.Parse("")
+	if _, err := os.Stat(configPath + ".prev"); !errors.Is(err, fs.ErrNotExist) {
+		if err != nil {
+			return nil, err
+		}
+		previousSection, err = parser.ParseFile(configPath + ".prev")
+		if err != nil {
+			return nil, fmt.Errorf("error parsing previous configuration file: %w", err)
 		}
-		return []*parser.Section{}
 	}
 
-	subSectionName := parts[0]
-	subSectionPath := strings.TrimPrefix(key, subSectionName+"/")
+	return stateFromSection(previousSection, state.DefaultPath)
+}
 
-	sections := []*parser.Section{}
-	if subSections, ok := section.Sections[subSectionName]; ok {
-		for _, subSection := range subSections {
-			sections = append(sections, getAllSections(subSection, subSectionPath)...)
-		}
+// saveGeneration stores the applied configuration and its resolved resources as a new generation,
+// and marks it as the current generation in the state.
+func saveGeneration(configPath string, section *parser.Section, st *state.State) error {
+	gen := &state.Generation{
+		Time:       time.Now(),
+		ConfigPath: configPath,
+		Config:     section.Marshal(0),
+		Tags:       tagSet.Tags(),
+		Resources:  st.Clone(),
+	}
+	if err := state.SaveGeneration(state.DefaultGenerationsDir, gen); err != nil {
+		return err
 	}
 
-	return sections
+	st.Generation = gen.Number
+	return st.Save()
 }
 
-// createFlatpakIdentifier creates a standardized identifier for Flatpak resources
-// Format: "[installation]:[name]" if specific system-wide installation was specified
-// Format: ":[name]" if specified to use per-user installation
-// Format: "default:[name]" if no installation was specified (default system-wide installation)
-func createFlatpakIdentifier(name, installation string, userInstallation bool) string {
-	if installation != "" {
-		return installation + ":" + name
+// stateFromSection creates the state a configuration would have after being fully applied.
+func stateFromSection(section *parser.Section, path string) (*state.State, error) {
+	st := state.New(path)
+
+	st.Packages["kernel"] = tagSet.GetAll(section, "essentials/kernel")
+	st.Packages["bootloader"] = section.GetList("essentials/bootloader", schema.Default("essentials/bootloader"))
+	st.Packages["network_handler"] = section.GetList("essentials/network_handler", schema.Default("essentials/network_handler"))
+	st.Packages["pacman"] = tagSet.GetAll(section, "packages/pacman/package")
+	st.Packages["aur"] = tagSet.GetAll(section, "packages/aur/package")
+
+	users, err := getUsers(section)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing user configuration: %w", err)
+	}
+	st.Users = users
+
+	for _, remote := range getAllSections(section, "packages/flatpak/remote") {
+		remoteObj, err := modules.FlatpakRemoteFrom(remote)
+		if err != nil {
+			return nil, err
+		}
+		st.FlatpakRemotes[modules.FlatpakIdentifier(remoteObj.Name, remoteObj.Installation, remoteObj.UserInstallation)] = remoteObj
 	}
-	if userInstallation {
-		return ":" + name
+
+	for _, pkg := range getFlatpakPackages(section) {
+		st.Packages["flatpak"] = append(st.Packages["flatpak"], pkg.Identifier())
+		st.FlatpakPackages[pkg.Identifier()] = pkg
 	}
-	return "default:" + name
+
+	return st, nil
+}
+
+// getUsers returns the users declared in the configuration, keyed by username.
+func getUsers(section *parser.Section) (map[string]modules.User, error) {
+	users := make(map[string]modules.User)
+	for _, userSection := range getAllSections(section, "users/user") {
+		user, err := modules.UserFrom(userSection)
+		if err != nil {
+			return nil, err
+		}
+		users[user.Username] = user
+	}
+	return users, nil
 }
 
 func getFlatpakRemoteIdentifiers(remotes []*parser.Section) []string {
@@ -998,11 +840,10 @@ func getFlatpakRemoteIdentifiers(remotes []*parser.Section) []string {
 	for _, remote := range remotes {
 		name := remote.GetFirst("name", "")
 
-		userInstallString := remote.GetFirst("user_installation", "false")
-		userInstall, err := strconv.ParseBool(userInstallString)
+		userInstall, err := remote.GetBool("user_installation", schema.Default("packages/flatpak/remote/user_installation"))
 		if err != nil {
 			color.Set(color.FgRed)
-			fmt.Printf("Error parsing 'user_installation' field for remote '%s': %v\n", name, err)
+			fmt.Printf("Error parsing remote '%s': %v\n", name, err)
 			color.Unset()
 			continue
 		}
@@ -1010,7 +851,7 @@ func getFlatpakRemoteIdentifiers(remotes []*parser.Section) []string {
 		installation := remote.GetFirst("installation", "")
 
 		if name != "" {
-			identifiers = append(identifiers, createFlatpakIdentifier(name, installation, userInstall))
+			identifiers = append(identifiers, modules.FlatpakIdentifier(name, installation, userInstall))
 		}
 	}
 	return identifiers
@@ -1056,21 +897,14 @@ func getFlatpakPackages(section *parser.Section) []modules.FlatpakPackage {
 	return packages
 }
 
-func getFlatpakPackageIdentifiers(packages []modules.FlatpakPackage) []string {
-	names := make([]string, len(packages))
-	for i, pkg := range packages {
-		names[i] = createFlatpakIdentifier(pkg.Name, pkg.Installation, pkg.UserInstallation)
-	}
-	return names
-}
-
 func init() {
-	applyCmd.PersistentFlags().StringP("config", "c", "/etc/declarch/declarch.conf", "Configuration file")
+	applyCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file, repeated to layer files over the first one")
 	applyCmd.PersistentFlags().BoolP("bare", "b", false, "Install only essential packages with the +bare tag (equivalent to --tags=\"-default +bare\")")
 
-	applyCmd.PersistentFlags().StringSlice("tags", []string{}, "List of tags to include/exclude, e.g. '-default +bare'")
+	applyCmd.PersistentFlags().StringSlice("tags", []string{}, "List of tags or presets to include/exclude, e.g. '-default +bare'")
 
 	applyCmd.PersistentFlags().BoolP("upgrade", "u", false, "Perform a system upgrade")
+	applyCmd.PersistentFlags().Bool("dry-run", false, "Print the actions that would be taken without making any changes")
 
 	rootCmd.AddCommand(applyCmd)
 }
\ No newline at end of file
@@ -0,0 +1,106 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/fatih/color"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found in a configuration.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Path is the config path of the offending value or section, e.g. `packages/flatpak/remote[2]/url`.
+	// Sections that can be repeated are indexed from 0 in the order they appear.
+	Path    string          `json:"path,omitempty"`
+	Message string          `json:"message"`
+	Pos     parser.Position `json:"position"`
+}
+
+func (d Diagnostic) String() string {
+	s := ""
+	if d.Pos != (parser.Position{}) {
+		s += d.Pos.String() + ": "
+	}
+	s += string(d.Severity) + ": "
+	if d.Path != "" {
+		s += d.Path + ": "
+	}
+	return s + d.Message
+}
+
+// Diagnostics is a list of diagnostics in the order they were found.
+type Diagnostics []Diagnostic
+
+func (ds *Diagnostics) Error(pos parser.Position, path string, format string, args ...any) {
+	*ds = append(*ds, Diagnostic{Severity: SeverityError, Path: path, Message: fmt.Sprintf(format, args...), Pos: pos})
+}
+
+func (ds *Diagnostics) Warning(pos parser.Position, path string, format string, args ...any) {
+	*ds = append(*ds, Diagnostic{Severity: SeverityWarning, Path: path, Message: fmt.Sprintf(format, args...), Pos: pos})
+}
+
+// HasErrors reports whether any of the diagnostics is an error.
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort orders the diagnostics by their locations, with diagnostics without a location last.
+func (ds Diagnostics) Sort() {
+	sort.SliceStable(ds, func(i, j int) bool {
+		a, b := ds[i].Pos, ds[j].Pos
+		if a.IsValid() != b.IsValid() {
+			return a.IsValid()
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// parseDiagnostics converts an error returned by the parser into diagnostics.
+func parseDiagnostics(err error, configPath string) Diagnostics {
+	ds := Diagnostics{}
+
+	var errs parser.ErrorList
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			ds.Error(e.Pos, "", "%s", e.Message)
+		}
+		return ds
+	}
+
+	ds.Error(parser.Position{File: configPath}, "", "%v", err)
+	return ds
+}
+
+func printDiagnostics(ds Diagnostics) {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			color.Set(color.FgRed)
+		} else {
+			color.Set(color.FgYellow)
+		}
+		fmt.Println(d.String())
+		color.Unset()
+	}
+}
\ No newline at end of file
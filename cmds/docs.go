@@ -0,0 +1,44 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/schema"
+)
+
+var docsCmd = &cobra.Command{
+	Use:   "docs",
+	Short: "Generate the configuration reference",
+	Long:  "Generate the configuration reference from the built-in key documentation, as Markdown or as a declarch.conf(5) man page.",
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+
+		var err error
+		switch format {
+		case "markdown":
+			err = schema.WriteMarkdown(os.Stdout, schema.Root)
+		case "man":
+			err = schema.WriteMan(os.Stdout, schema.Root)
+		default:
+			err = fmt.Errorf("unknown format '%s', expected markdown or man", format)
+		}
+
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error generating documentation: ")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+		}
+	},
+}
+
+func init() {
+	docsCmd.PersistentFlags().StringP("format", "f", "markdown", "Output format (markdown or man)")
+
+	rootCmd.AddCommand(docsCmd)
+}
\ No newline at end of file
@@ -0,0 +1,114 @@
+package cmds
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/schema"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain [path]",
+	Short: "Show the documentation of a configuration key or section",
+	Long:  "Show the documentation of a configuration key or section, e.g. `declarch explain packages/pacman`.\nWithout a path, the top-level sections are listed.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		keyPath := ""
+		if len(args) > 0 {
+			keyPath = strings.Trim(args[0], "/")
+		}
+
+		key := schema.Root
+		if keyPath != "" {
+			key = schema.Lookup(keyPath)
+		}
+		if key == nil {
+			color.Set(color.FgRed)
+			fmt.Print("Unknown configuration path: ")
+			color.Set(color.Bold)
+			fmt.Print(keyPath)
+			color.Unset()
+			fmt.Println()
+
+			parentPath, name := path.Split(keyPath)
+			parent := schema.Root
+			if parentPath != "" {
+				parent = schema.Lookup(strings.TrimSuffix(parentPath, "/"))
+			}
+			if parent != nil {
+				names := []string{}
+				for _, child := range parent.Keys {
+					names = append(names, child.Name)
+				}
+				if suggested := schema.Suggest(name, names); suggested != "" {
+					fmt.Printf("Did you mean '%s%s'?\n", parentPath, suggested)
+				}
+			}
+			exitCode = 1
+			return
+		}
+
+		if keyPath != "" {
+			printKey(keyPath, key)
+		}
+
+		if len(key.Keys) > 0 {
+			if keyPath != "" {
+				fmt.Println()
+			}
+			color.Set(color.Bold)
+			fmt.Println("Keys:")
+			color.Unset()
+
+			width := 0
+			for _, child := range key.Keys {
+				width = max(width, len(child.Name))
+			}
+			for _, child := range key.Keys {
+				fmt.Printf("  %-*s  ", width, child.Name)
+				color.Set(color.FgCyan)
+				fmt.Printf("%-8s", child.Type)
+				color.Unset()
+				fmt.Println("  " + child.Description)
+			}
+		}
+	},
+}
+
+// printKey prints the description, type, constraints and example of a key.
+func printKey(keyPath string, key *schema.Key) {
+	color.Set(color.Bold)
+	fmt.Println(keyPath)
+	color.Unset()
+	fmt.Println("  " + key.Description)
+	fmt.Println()
+
+	for _, attribute := range key.Attributes() {
+		name, value, found := strings.Cut(attribute, ": ")
+		color.Set(color.FgCyan)
+		fmt.Print("  " + name)
+		color.Unset()
+		if found {
+			fmt.Print(": " + value)
+		}
+		fmt.Println()
+	}
+
+	if key.Example != "" {
+		fmt.Println()
+		color.Set(color.FgCyan)
+		fmt.Println("  example:")
+		color.Unset()
+		for _, line := range strings.Split(strings.TrimSuffix(key.ExampleSnippet(keyPath), "\n"), "\n") {
+			fmt.Println("    " + line)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+}
\ No newline at end of file
@@ -0,0 +1,187 @@
+package cmds
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the resolved configuration as JSON, YAML or TOML",
+	Long: "Export the configuration after sourcing, variable substitution and tag filtering, for other tools to read.\n" +
+		"The `config` member holds the configuration, with booleans and numbers typed and the tags of values and sections removed,\n" +
+		"and the `packages` member holds the packages each backend would install.\n" +
+		"Exported files can be given to the other commands with -c, e.g. `declarch plan -c declarch.json`.",
+	Run: func(cmd *cobra.Command, args []string) {
+		paths := configPaths(cmd)
+		configPath := paths[0]
+		format, _ := cmd.Flags().GetString("format")
+		if format != "json" && format != "yaml" && format != "toml" {
+			color.Set(color.FgRed)
+			fmt.Println("Invalid format '" + format + "', expected 'json', 'yaml' or 'toml'.")
+			color.Unset()
+			exitCode = 1
+			return
+		}
+
+		section, _, err := parseConfigWithTags(cmd, paths)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error parsing configuration file: ")
+			color.Set(color.Bold)
+			fmt.Print(configPath)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		if ds := Verify(section); ds.HasErrors() {
+			printDiagnostics(ds)
+			color.Set(color.FgRed, color.Bold)
+			fmt.Println("Configuration is invalid.")
+			color.Unset()
+			exitCode = 1
+			return
+		}
+
+		st, err := stateFromSection(section, configPath)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Println("Error resolving packages:")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		packages := map[string][]string{}
+		for backend, pkgs := range st.Packages {
+			packages[backend] = append([]string{}, pkgs...)
+		}
+		document := map[string]any{
+			"config":   exportSection(section, schema.Root),
+			"packages": packages,
+		}
+
+		switch format {
+		case "json":
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			err = encoder.Encode(document)
+		case "yaml":
+			encoder := yaml.NewEncoder(os.Stdout)
+			encoder.SetIndent(2)
+			err = encoder.Encode(document)
+		case "toml":
+			err = toml.NewEncoder(os.Stdout).Encode(document)
+		}
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Println("Error exporting configuration:")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+		}
+	},
+}
+
+// exportSection converts a section to a tree of typed values.
+func exportSection(section *parser.Section, key *schema.Key) map[string]any {
+	tree := map[string]any{}
+	exportInto(tree, section, key)
+	return tree
+}
+
+// exportInto adds the values and sub-sections of a section, for a key of the schema, to a tree.
+// Values and sections that don't match the tag set are left out, and the tags of the others are removed.
+// Sections that aren't repeated are merged if they are written several times, like GetFirst and GetAll read them.
+func exportInto(tree map[string]any, section *parser.Section, key *schema.Key) {
+	for _, name := range slices.Sorted(maps.Keys(section.Values)) {
+		child := key.Child(name)
+		if child == nil || (name == "tags" && child.Type == schema.Tags) {
+			continue
+		}
+
+		for _, value := range section.Values[name] {
+			if child.Tagged {
+				valuePart, tags, _ := strings.Cut(value, ",")
+				if included, err := tagSet.Includes(tags); err != nil || !included {
+					continue
+				}
+				value = strings.TrimSpace(valuePart)
+			}
+
+			if child.Repeated {
+				items, _ := tree[name].([]any)
+				tree[name] = append(items, exportValue(value, child))
+			} else if _, ok := tree[name]; !ok {
+				tree[name] = exportValue(value, child)
+			}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(section.Sections)) {
+		child := key.Child(name)
+		if child == nil {
+			continue
+		}
+
+		for _, subSection := range section.Sections[name] {
+			if !tagSet.IncludesSection(subSection) {
+				continue
+			}
+
+			if child.Repeated {
+				items, _ := tree[name].([]any)
+				tree[name] = append(items, exportSection(subSection, child))
+				continue
+			}
+
+			subTree, ok := tree[name].(map[string]any)
+			if !ok {
+				subTree = map[string]any{}
+				tree[name] = subTree
+			}
+			exportInto(subTree, subSection, child)
+		}
+	}
+}
+
+// exportValue returns a value with the type of its key, as a bool, an int or a string.
+func exportValue(value string, key *schema.Key) any {
+	switch key.Type {
+	case schema.Bool:
+		if b, err := parser.ParseBool(value); err == nil {
+			return b
+		}
+	case schema.Int:
+		if i, err := parser.ParseInt(value); err == nil {
+			return i
+		}
+	}
+	return value
+}
+
+func init() {
+	exportCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file, repeated to layer files over the first one")
+	exportCmd.PersistentFlags().StringP("format", "f", "json", "Output format: json, yaml or toml")
+	exportCmd.PersistentFlags().BoolP("bare", "b", false, "Export with the tags selected by --bare (equivalent to --tags=\"-default +bare\")")
+	exportCmd.PersistentFlags().StringSlice("tags", []string{}, "List of tags or presets to include/exclude, e.g. '-default +bare'")
+
+	rootCmd.AddCommand(exportCmd)
+}
\ No newline at end of file
@@ -0,0 +1,110 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
+)
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [file]...",
+	Short: "Format configuration files",
+	Long: "Rewrite configuration files in the canonical style: two spaces of indentation, aligned `=`, single blank lines and normalized tags, keeping comments.\n" +
+		"Without arguments, the configuration files given with --config are formatted. Sourced files must be given separately.",
+	Run: func(cmd *cobra.Command, args []string) {
+		check, _ := cmd.Flags().GetBool("check")
+		sortValues, _ := cmd.Flags().GetBool("sort")
+
+		paths := args
+		if len(paths) == 0 {
+			paths = configPaths(cmd)
+		}
+
+		opts := parser.FormatOptions{
+			Tagged: func(path string) bool {
+				key := schema.Lookup(path)
+				return key != nil && key.Tagged
+			},
+		}
+		if sortValues {
+			opts.Sortable = func(path string) bool {
+				key := schema.Lookup(path)
+				return key != nil && key.Repeated && key.Type == schema.List && !key.Ordered
+			}
+		}
+
+		for _, path := range paths {
+			path, _ = filepath.Abs(path)
+			formatFile(path, opts, check)
+		}
+	},
+}
+
+// formatFile formats a configuration file, or only reports whether it is formatted if check is set.
+func formatFile(path string, opts parser.FormatOptions, check bool) {
+	original, err := os.ReadFile(path)
+	if err != nil {
+		color.Set(color.FgRed)
+		fmt.Print("Error reading configuration file: ")
+		color.Set(color.Bold)
+		fmt.Print(path)
+		color.Set(color.ResetBold)
+		fmt.Println(":")
+		color.Unset()
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+		return
+	}
+
+	doc, err := parser.ParseDocument(string(original), path)
+	if err != nil {
+		printDiagnostics(parseDiagnostics(err, path))
+		exitCode = 1
+		return
+	}
+
+	doc.Format(opts)
+	formatted := doc.String()
+	if formatted == string(original) {
+		return
+	}
+
+	if check {
+		printPlannedPatch(path, original, []byte(formatted))
+		exitCode = 1
+		return
+	}
+
+	if err := doc.WriteFile(); err != nil {
+		color.Set(color.FgRed)
+		fmt.Print("Error writing configuration file: ")
+		color.Set(color.Bold)
+		fmt.Print(path)
+		color.Set(color.ResetBold)
+		fmt.Println(":")
+		color.Unset()
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
+		return
+	}
+
+	color.Set(color.FgGreen)
+	fmt.Print("Formatted: ")
+	color.Set(color.Bold)
+	fmt.Println(path)
+	color.Unset()
+}
+
+func init() {
+	fmtCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file, repeated to format several files")
+	fmtCmd.PersistentFlags().Bool("check", false, "Only report files that are not formatted, exiting with status 1 if there are any")
+	fmtCmd.PersistentFlags().Bool("sort", false, "Sort package lists and other values whose order doesn't matter")
+
+	rootCmd.AddCommand(fmtCmd)
+}
\ No newline at end of file
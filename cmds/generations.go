@@ -0,0 +1,71 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/state"
+)
+
+var generationsCmd = &cobra.Command{
+	Use:   "generations",
+	Short: "List applied generations",
+	Run: func(cmd *cobra.Command, args []string) {
+		st, _, err := state.Load(state.DefaultPath)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error loading state file: ")
+			color.Set(color.Bold)
+			fmt.Print(state.DefaultPath)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		generations, err := state.ListGenerations(state.DefaultGenerationsDir)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error listing generations: ")
+			color.Set(color.Bold)
+			fmt.Print(state.DefaultGenerationsDir)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		if len(generations) == 0 {
+			color.Set(color.FgYellow)
+			fmt.Println("No generations have been applied yet.")
+			color.Unset()
+			return
+		}
+
+		var previous *state.Generation
+		for _, gen := range generations {
+			if gen.Number == st.Generation {
+				color.Set(color.FgGreen, color.Bold)
+			}
+			fmt.Printf("%4d  %s  %s", gen.Number, gen.Time.Local().Format("2006-01-02 15:04:05"), gen.Summary(previous))
+			if gen.Number == st.Generation {
+				fmt.Print("  (current)")
+			}
+			fmt.Println()
+			color.Unset()
+
+			previous = gen
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(generationsCmd)
+}
\ No newline at end of file
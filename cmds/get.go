@@ -0,0 +1,112 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get <query>",
+	Short: "Print the values of a configuration key",
+	Long: "Print the values a query selects, one per line, after sourcing, variable substitution and tag filtering, e.g.\n" +
+		"`declarch get packages/pacman/repository[name=core]/server` or `declarch get users/user[*]/username`.\n" +
+		"Steps can be filtered with a zero-based index `[N]`, `[*]`, `[key=value]` or `[key!=value]`.\n" +
+		"If the query selects sections instead of values, they are printed in configuration syntax.\n" +
+		"Exits with status 1 if nothing matches.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		paths := configPaths(cmd)
+		configPath := paths[0]
+		positions, _ := cmd.Flags().GetBool("positions")
+
+		query, err := parser.ParseQuery(strings.Trim(args[0], "/"))
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Invalid query ")
+			color.Set(color.Bold)
+			fmt.Print(args[0])
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		section, _, err := parseConfigWithTags(cmd, paths)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error parsing configuration file: ")
+			color.Set(color.Bold)
+			fmt.Print(configPath)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		include := func(subSection *parser.Section) bool {
+			return tagSet.IncludesSection(subSection)
+		}
+
+		// Filters like `[name=core]` only apply to sections, so the query is tried on sections if it can't select values
+		matches, valuesErr := query.Values(section, include)
+
+		found := false
+		key := schema.Lookup(query.Path())
+		for _, match := range matches {
+			value := match.Value
+			if key != nil && key.Tagged {
+				valuePart, tags, _ := strings.Cut(value, ",")
+				if included, err := tagSet.Includes(tags); err != nil || !included {
+					continue
+				}
+				value = strings.TrimSpace(valuePart)
+			}
+
+			if positions {
+				fmt.Print(match.Pos.String() + ": ")
+			}
+			fmt.Println(value)
+			found = true
+		}
+
+		if len(matches) == 0 {
+			name := query.Path()[strings.LastIndex(query.Path(), "/")+1:]
+			for _, subSection := range query.Sections(section, include) {
+				if positions {
+					fmt.Print("# " + subSection.Pos.String() + "\n")
+				}
+				fmt.Print(name + " {\n" + subSection.Marshal(1) + "}\n")
+				found = true
+			}
+		}
+
+		if !found {
+			if valuesErr != nil {
+				fmt.Fprintln(os.Stderr, valuesErr)
+			} else {
+				fmt.Fprintf(os.Stderr, "Nothing matches '%s'.\n", args[0])
+			}
+			exitCode = 1
+		}
+	},
+}
+
+func init() {
+	getCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file, repeated to layer files over the first one")
+	getCmd.PersistentFlags().BoolP("bare", "b", false, "Filter with the tags selected by --bare (equivalent to --tags=\"-default +bare\")")
+	getCmd.PersistentFlags().StringSlice("tags", []string{}, "List of tags or presets to include/exclude, e.g. '-default +bare'")
+	getCmd.PersistentFlags().BoolP("positions", "p", false, "Print the location of each value")
+
+	rootCmd.AddCommand(getCmd)
+}
\ No newline at end of file
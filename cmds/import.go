@@ -0,0 +1,381 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/modules/config/ini"
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/state"
+)
+
+// Packages that are imported into the essentials section instead of the package list.
+var (
+	knownKernels         = []string{"linux", "linux-lts", "linux-zen", "linux-hardened", "linux-rt", "linux-rt-lts"}
+	knownBootloaders     = []string{"grub", "efibootmgr", "refind", "limine", "syslinux"}
+	knownNetworkHandlers = []string{"networkmanager", "iwd", "connman", "dhcpcd", "netctl"}
+	knownAURHelpers      = []string{"yay", "paru", "pikaur", "trizen", "aurman"}
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Create a configuration file from the current system",
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		if !dryRun && !CheckRoot() {
+			return
+		}
+
+		configPath := configPaths(cmd)[0]
+		force, _ := cmd.Flags().GetBool("force")
+
+		if !dryRun && !force {
+			for _, path := range []string{configPath, state.DefaultPath} {
+				if _, err := os.Stat(path); !errors.Is(err, fs.ErrNotExist) {
+					color.Set(color.FgRed)
+					fmt.Print("File already exists: ")
+					color.Set(color.Bold)
+					fmt.Print(path)
+					color.Set(color.ResetBold)
+					fmt.Println(".")
+					fmt.Println("Use --force to overwrite it.")
+					color.Unset()
+					exitCode = 1
+					return
+				}
+			}
+		}
+
+		content, warnings, err := ImportSystem("/etc/pacman.conf")
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Println("Error importing system configuration:")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		if dryRun {
+			fmt.Print(content)
+		}
+
+		color.Set(color.FgYellow)
+		for _, warning := range warnings {
+			fmt.Fprintln(os.Stderr, "Warning: "+warning)
+		}
+		color.Unset()
+
+		if dryRun {
+			return
+		}
+
+		if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error creating configuration directory: ")
+			color.Set(color.Bold)
+			fmt.Print(filepath.Dir(configPath))
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		if err := os.WriteFile(configPath, []byte(content), 0o644); err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error creating configuration file: ")
+			color.Set(color.Bold)
+			fmt.Print(configPath)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		// Record the imported resources as applied, so that the first apply does not change anything
+		tagSet = modules.NewTagSet("+default")
+		section, err := parser.Parse(content)
+		if err == nil {
+			var st *state.State
+			st, err = stateFromSection(section, state.DefaultPath)
+			if err == nil {
+				err = st.Save()
+			}
+		}
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error creating state file: ")
+			color.Set(color.Bold)
+			fmt.Print(state.DefaultPath)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		color.Set(color.FgGreen)
+		fmt.Print("Configuration imported successfully: ")
+		color.Set(color.Bold)
+		fmt.Print(configPath)
+		color.Set(color.ResetBold)
+		fmt.Println(".")
+		color.Unset()
+	},
+}
+
+// ImportSystem generates a configuration describing the packages, Flatpaks, users and repositories of the current system.
+// It also returns warnings for parts of the system that could not be described exactly.
+func ImportSystem(pacmanConfigPath string) (string, []string, error) {
+	warnings := []string{}
+
+	nativePackages, err := modules.PacmanQueryExplicit(false)
+	if err != nil {
+		return "", nil, fmt.Errorf("error querying explicitly installed packages: %w", err)
+	}
+	foreignPackages, err := modules.PacmanQueryExplicit(true)
+	if err != nil {
+		return "", nil, fmt.Errorf("error querying explicitly installed foreign packages: %w", err)
+	}
+
+	usernames, err := modules.ListRegularUsers()
+	if err != nil {
+		return "", nil, fmt.Errorf("error listing users: %w", err)
+	}
+	users := []modules.User{}
+	for _, username := range usernames {
+		user, _, err := modules.LookupUser(username)
+		if err != nil {
+			return "", nil, fmt.Errorf("error looking up user %s: %w", username, err)
+		}
+		users = append(users, user)
+	}
+
+	// Flatpak per-user installations are queried as the primary user
+	if len(users) > 0 {
+		modules.PrimaryUser = users[0].Username
+	}
+
+	flatpakRemotes, err := modules.FlatpakQueryRemotes()
+	if err != nil {
+		return "", nil, fmt.Errorf("error querying Flatpak remotes: %w", err)
+	}
+	flatpakPackages, err := modules.FlatpakQueryApps()
+	if err != nil {
+		return "", nil, fmt.Errorf("error querying installed Flatpak packages: %w", err)
+	}
+
+	pacmanConfig, err := ini.NewPacmanParser().Parse(pacmanConfigPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("error parsing %s: %w", pacmanConfigPath, err)
+	}
+
+	extractPackages := func(known []string) []string {
+		extracted := []string{}
+		for _, pkg := range known {
+			if slices.Contains(nativePackages, pkg) {
+				extracted = append(extracted, pkg)
+			}
+		}
+		nativePackages = slices.DeleteFunc(nativePackages, func(pkg string) bool {
+			return slices.Contains(extracted, pkg)
+		})
+		return extracted
+	}
+	kernels := extractPackages(knownKernels)
+	bootloader := extractPackages(knownBootloaders)
+	networkHandler := extractPackages(knownNetworkHandlers)
+
+	var sb strings.Builder
+
+	sb.WriteString("# Generated by `declarch import`.\n\n")
+
+	sb.WriteString("essentials {\n")
+	if len(kernels) == 0 {
+		warnings = append(warnings, "no known kernel package is installed, so essentials/kernel was not set")
+	}
+	for _, kernel := range kernels {
+		sb.WriteString("  kernel = " + kernel + "\n")
+	}
+	if len(networkHandler) > 0 {
+		sb.WriteString("  network_handler = " + strings.Join(networkHandler, " ") + "\n")
+	} else {
+		warnings = append(warnings, "no known network handler package is installed, so the default will be installed on apply")
+	}
+	if len(bootloader) > 0 {
+		sb.WriteString("  bootloader = " + strings.Join(bootloader, " ") + "\n")
+	} else {
+		warnings = append(warnings, "no known bootloader package is installed, so the default will be installed on apply")
+	}
+	sb.WriteString("}\n\n")
+
+	sb.WriteString("packages {\n")
+
+	sb.WriteString("  pacman {\n")
+	writePacmanOptions(&sb, pacmanConfig)
+	for _, pkg := range nativePackages {
+		sb.WriteString("    package = " + pkg + "\n")
+	}
+	sb.WriteString("  }\n\n")
+
+	sb.WriteString("  aur {\n")
+	helper := "makepkg"
+	for _, pkg := range foreignPackages {
+		name := strings.TrimSuffix(strings.TrimSuffix(pkg, "-bin"), "-git")
+		if slices.Contains(knownAURHelpers, name) {
+			helper = name
+			break
+		}
+	}
+	sb.WriteString("    helper = " + helper + "\n")
+	if len(foreignPackages) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, pkg := range foreignPackages {
+		sb.WriteString("    package = " + pkg + "\n")
+	}
+	sb.WriteString("  }\n\n")
+
+	sb.WriteString("  flatpak {\n")
+	for _, remote := range flatpakRemotes {
+		sb.WriteString("    remote {\n")
+		sb.WriteString("      name = " + remote.Name + "\n")
+		sb.WriteString("      url = " + parser.EscapeValue(remote.URL) + "\n")
+		if remote.UserInstallation {
+			sb.WriteString("      user_installation = true\n")
+		}
+		if remote.Installation != "" {
+			sb.WriteString("      installation = " + remote.Installation + "\n")
+		}
+		if remote.Disable {
+			sb.WriteString("      disable = true\n")
+		}
+		sb.WriteString("    }\n")
+	}
+	if len(flatpakRemotes) > 0 && len(flatpakPackages) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, pkg := range flatpakPackages {
+		if !pkg.UserInstallation && pkg.Installation == "" {
+			sb.WriteString("    package = " + pkg.Name + "\n")
+			continue
+		}
+		sb.WriteString("    package {\n")
+		sb.WriteString("      name = " + pkg.Name + "\n")
+		if pkg.UserInstallation {
+			sb.WriteString("      user_installation = true\n")
+		}
+		if pkg.Installation != "" {
+			sb.WriteString("      installation = " + pkg.Installation + "\n")
+		}
+		sb.WriteString("    }\n")
+	}
+	sb.WriteString("  }\n")
+
+	sb.WriteString("}\n\n")
+
+	sb.WriteString("users {\n")
+	for _, user := range users {
+		sb.WriteString("  user {\n")
+		sb.WriteString("    username = " + user.Username + "\n")
+		if user.FullName != "" {
+			sb.WriteString("    full_name = " + parser.EscapeValue(user.FullName) + "\n")
+		}
+		if user.Shell != "" {
+			sb.WriteString("    shell = " + user.Shell + "\n")
+		}
+		if user.HomeDir != "/home/"+user.Username {
+			sb.WriteString("    home_dir = " + user.HomeDir + "\n")
+		}
+		if len(user.Groups) > 0 {
+			sb.WriteString("\n")
+		}
+		for _, group := range user.Groups {
+			sb.WriteString("    group = " + group + "\n")
+		}
+		sb.WriteString("  }\n\n")
+	}
+	if len(users) > 0 {
+		sb.WriteString("  primary_user = " + users[0].Username + "\n")
+	} else {
+		warnings = append(warnings, "no regular users exist, so users/primary_user was not set")
+	}
+	sb.WriteString("}\n")
+
+	return sb.String(), warnings, nil
+}
+
+// writePacmanOptions writes the pacman options and repositories of a parsed pacman.conf.
+func writePacmanOptions(sb *strings.Builder, pacmanConfig *ini.Node) {
+	for _, section := range pacmanConfig.Children {
+		if section.Type != ini.NodeSection || section.Key != "options" {
+			continue
+		}
+		for _, option := range section.Children {
+			switch {
+			case option.Type == ini.NodeBoolean && option.Key == "Color":
+				sb.WriteString("    color = true\n")
+			case option.Type == ini.NodeKey && option.Key == "ParallelDownloads":
+				sb.WriteString("    parallel_downloads = " + option.Value + "\n")
+			case option.Type == ini.NodeBoolean && option.Key == "VerbosePkgLists":
+				sb.WriteString("    verbose_pkg_lists = true\n")
+			case option.Type == ini.NodeBoolean && option.Key == "ILoveCandy":
+				sb.WriteString("    i_love_candy = true\n")
+			}
+		}
+	}
+	sb.WriteString("\n")
+
+	for _, section := range pacmanConfig.Children {
+		if section.Type != ini.NodeSection || section.Key == "options" {
+			continue
+		}
+
+		include, server := "", ""
+		for _, option := range section.Children {
+			if option.Type != ini.NodeKey {
+				continue
+			}
+			if option.Key == "Include" && include == "" {
+				include = option.Value
+			} else if option.Key == "Server" && server == "" {
+				server = option.Value
+			}
+		}
+
+		sb.WriteString("    repository {\n")
+		sb.WriteString("      name = " + section.Key + "\n")
+		if !(slices.Contains(builtinRepositories, section.Key) && include == "/etc/pacman.d/mirrorlist" && server == "") {
+			if include != "" {
+				sb.WriteString("      include = " + parser.EscapeValue(include) + "\n")
+			}
+			if server != "" {
+				sb.WriteString("      server = " + parser.EscapeValue(server) + "\n")
+			}
+		}
+		sb.WriteString("    }\n")
+	}
+	sb.WriteString("\n")
+}
+
+func init() {
+	importCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file to create")
+	importCmd.PersistentFlags().Bool("force", false, "Overwrite an existing configuration and state file")
+	importCmd.PersistentFlags().Bool("dry-run", false, "Print the generated configuration without writing any files")
+
+	rootCmd.AddCommand(importCmd)
+}
\ No newline at end of file
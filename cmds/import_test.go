@@ -0,0 +1,32 @@
+package cmds
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/modules/config/ini"
+)
+
+// Builtin repositories using the mirrorlist are written by name only, and "$" is escaped in servers.
+func TestWritePacmanOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pacman.conf")
+	os.WriteFile(path, []byte("[options]\nHoldPkg = pacman glibc\nColor\nParallelDownloads = 5\nILoveCandy\n\n"+
+		"[core]\nInclude = /etc/pacman.d/mirrorlist\n\n[extra]\nInclude = /etc/pacman.d/mirrorlist\n\n"+
+		"[multilib]\nInclude = /etc/pacman.d/custom-mirrorlist\n\n"+
+		"[chaotic-aur]\nServer = https://cdn-mirror.chaotic.cx/$repo/$arch\nServer = https://example.com/$repo/$arch\n"), 0o644)
+
+	pacmanConfig, err := ini.NewPacmanParser().Parse(path)
+	assert.NoError(t, err)
+
+	var sb strings.Builder
+	writePacmanOptions(&sb, pacmanConfig)
+	assert.Equal(t, "    color = true\n    parallel_downloads = 5\n    i_love_candy = true\n\n"+
+		"    repository {\n      name = core\n    }\n"+
+		"    repository {\n      name = extra\n    }\n"+
+		"    repository {\n      name = multilib\n      include = /etc/pacman.d/custom-mirrorlist\n    }\n"+
+		"    repository {\n      name = chaotic-aur\n      server = https://cdn-mirror.chaotic.cx/$$repo/$$arch\n    }\n\n", sb.String())
+}
\ No newline at end of file
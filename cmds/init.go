@@ -19,8 +19,7 @@ var initCmd = &cobra.Command{
 			return
 		}
 
-		configPath, _ := cmd.Flags().GetString("config")
-		configPath, _ = filepath.Abs(configPath)
+		configPath := configPaths(cmd)[0]
 
 		// Create file if it doesn't exist
 		if _, err := os.Stat(configPath); errors.Is(err, fs.ErrNotExist) {
@@ -33,6 +32,7 @@ var initCmd = &cobra.Command{
 				fmt.Println(":")
 				color.Unset()
 				fmt.Fprintln(os.Stderr, err)
+				exitCode = 1
 				return
 			}
 
@@ -45,6 +45,7 @@ var initCmd = &cobra.Command{
 				fmt.Println(":")
 				color.Unset()
 				fmt.Fprintln(os.Stderr, err)
+				exitCode = 1
 				return
 			}
 		} else if err != nil {
@@ -55,6 +56,7 @@ var initCmd = &cobra.Command{
 			color.Set(color.ResetBold)
 			fmt.Println(":")
 			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
 			return
 		} else {
 			color.Set(color.FgRed)
@@ -78,7 +80,7 @@ var initCmd = &cobra.Command{
 }
 
 func init() {
-	initCmd.PersistentFlags().StringP("config", "c", "/etc/declarch/declarch.conf", "Configuration file")
+	initCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file to create")
 
 	rootCmd.AddCommand(initCmd)
 }
\ No newline at end of file
@@ -0,0 +1,48 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/lsp"
+	"github.com/DevReaper0/declarch/parser"
+)
+
+var lspCmd = &cobra.Command{
+	Use:   "lsp",
+	Short: "Run the language server",
+	Long: "Run a language server for configuration files over stdin and stdout.\n" +
+		"It reports the same problems as verify while editing, completes keys, values and variables, " +
+		"shows the documentation of keys on hover, and jumps to sourced files and variable definitions.",
+	Run: func(cmd *cobra.Command, args []string) {
+		server := lsp.NewServer(func(section *parser.Section) []lsp.Problem {
+			problems := []lsp.Problem{}
+			for _, d := range Verify(section) {
+				problem := lsp.Problem{Pos: d.Pos, Severity: lsp.SeverityError, Message: d.Message}
+				if d.Severity == SeverityWarning {
+					problem.Severity = lsp.SeverityWarning
+				}
+				if d.Path != "" {
+					problem.Message = d.Path + ": " + d.Message
+				}
+				problems = append(problems, problem)
+			}
+			return problems
+		})
+
+		if err := server.Run(os.Stdin, os.Stdout); err != nil {
+			color.Set(color.FgRed)
+			fmt.Fprint(os.Stderr, "Error running the language server: ")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+}
\ No newline at end of file
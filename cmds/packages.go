@@ -0,0 +1,140 @@
+package cmds
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/state"
+)
+
+// packageSet is a group of packages of one provider that is applied together,
+// such as the kernels or the packages of the `packages/pacman` section.
+type packageSet[P any] struct {
+	provider modules.Provider[P]
+	// kind is the key the packages are recorded under in the state.
+	kind string
+
+	// current are the declared packages, and previous are the packages that were applied last.
+	current  []P
+	previous []P
+
+	hookSections []*parser.Section
+}
+
+// newPackageSet creates a package set for the registered provider with the given name.
+func newPackageSet[P any](providerName, kind string, current, previous []P, hookSections []*parser.Section) (*packageSet[P], error) {
+	provider, err := modules.GetProvider[P](providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &packageSet[P]{
+		provider:     provider,
+		kind:         kind,
+		current:      current,
+		previous:     previous,
+		hookSections: hookSections,
+	}, nil
+}
+
+// apply removes the packages that are no longer declared and installs the missing ones,
+// recording each step in the state.
+func (ps *packageSet[P]) apply(st *state.State) error {
+	added, removed, err := ps.differences()
+	if err != nil {
+		return err
+	}
+
+	if err := ps.remove(removed); err != nil {
+		return err
+	}
+	if err := st.RemovePackages(ps.kind, ps.ids(removed)); err != nil {
+		return err
+	}
+
+	if err := ps.install(added); err != nil {
+		return err
+	}
+	return st.SetPackages(ps.kind, ps.ids(ps.current))
+}
+
+// differences returns the packages that need to be installed and removed,
+// based on the declared packages, the previously applied packages and the installed packages.
+func (ps *packageSet[P]) differences() ([]P, []P, error) {
+	installed, err := ps.provider.Query()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying installed %s: %w", ps.provider.Description(), err)
+	}
+
+	added, removed := reconcileDifferences(ps.ids(ps.current), ps.ids(ps.previous), ps.ids(installed))
+	return ps.lookup(ps.current, added), ps.lookup(ps.previous, removed), nil
+}
+
+func (ps *packageSet[P]) install(pkgs []P) error {
+	// The list is only installed, so it needs no remove function
+	list, err := ps.packageList(pkgs, nil)
+	if err != nil {
+		return err
+	}
+	return list.Install()
+}
+
+func (ps *packageSet[P]) remove(pkgs []P) error {
+	return ps.removeWith(pkgs, ps.provider.Remove)
+}
+
+// removeWith runs the remove hooks of the packages around removeFunc instead of the provider's Remove,
+// e.g. to mark the packages as dependencies.
+func (ps *packageSet[P]) removeWith(pkgs []P, removeFunc func([]P) error) error {
+	list, err := ps.packageList(pkgs, removeFunc)
+	if err != nil {
+		return err
+	}
+	return list.Remove()
+}
+
+// packageList creates a package list with the hooks of each package attached.
+// A hook applies to a package if its `package` field is the ID or the name of the package.
+func (ps *packageSet[P]) packageList(pkgs []P, removeFunc func([]P) error) (*modules.PackageList[P], error) {
+	list := modules.NewPackageList(ps.provider.Install, removeFunc)
+	for _, value := range pkgs {
+		id := ps.provider.ID(value)
+		name := modules.PackageName(ps.provider, value)
+
+		pkg := modules.NewPackage(value)
+		for _, hookSection := range ps.hookSections {
+			hookPackage := hookSection.GetFirst("package", "")
+			if hookPackage == id || hookPackage == name {
+				if err := pkg.AddHook(hookSection); err != nil {
+					return nil, fmt.Errorf("error adding hook for package %s: %w", name, err)
+				}
+			}
+		}
+		list.Add(pkg)
+	}
+	return list, nil
+}
+
+func (ps *packageSet[P]) ids(pkgs []P) []string {
+	ids := make([]string, len(pkgs))
+	for i, pkg := range pkgs {
+		ids[i] = ps.provider.ID(pkg)
+	}
+	return ids
+}
+
+// lookup returns the first package of pkgs for each of the IDs.
+func (ps *packageSet[P]) lookup(pkgs []P, ids []string) []P {
+	found := []P{}
+	for _, id := range ids {
+		index := slices.IndexFunc(pkgs, func(pkg P) bool {
+			return ps.provider.ID(pkg) == id
+		})
+		if index != -1 {
+			found = append(found, pkgs[index])
+		}
+	}
+	return found
+}
\ No newline at end of file
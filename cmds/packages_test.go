@@ -0,0 +1,64 @@
+package cmds
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePackage is a package value of fakeProvider, identified by its name and whether it is installed per user.
+type fakePackage struct {
+	name string
+	user bool
+}
+
+// fakeProvider is a package provider whose installed packages are a fixed list.
+type fakeProvider struct {
+	installed []fakePackage
+	err       error
+}
+
+func (p *fakeProvider) Name() string        { return "fake" }
+func (p *fakeProvider) Description() string { return "fake packages" }
+func (p *fakeProvider) Upgrade() error      { return nil }
+
+func (p *fakeProvider) ID(pkg fakePackage) string {
+	if pkg.user {
+		return "user:" + pkg.name
+	}
+	return pkg.name
+}
+
+func (p *fakeProvider) Query() ([]fakePackage, error)             { return p.installed, p.err }
+func (p *fakeProvider) Install(pkgs []fakePackage) error          { return nil }
+func (p *fakeProvider) Remove(pkgs []fakePackage) error           { return nil }
+func (p *fakeProvider) IsInstalled(pkg fakePackage) (bool, error) { return false, nil }
+
+func TestPackageSet_Differences(t *testing.T) {
+	provider := &fakeProvider{installed: []fakePackage{{name: "vim"}, {name: "htop"}, {name: "git", user: true}}}
+	ps := &packageSet[fakePackage]{
+		provider: provider,
+		kind:     "fake",
+		current:  []fakePackage{{name: "vim"}, {name: "git"}, {name: "neovim", user: true}},
+		previous: []fakePackage{{name: "vim"}, {name: "htop"}, {name: "nano"}, {name: "git", user: true}},
+	}
+
+	added, removed, err := ps.differences()
+	assert.NoError(t, err)
+	assert.Equal(t, []fakePackage{{name: "git"}, {name: "neovim", user: true}}, added)
+	assert.Equal(t, []fakePackage{{name: "htop"}, {name: "git", user: true}}, removed)
+
+	provider.err = errors.New("not available")
+	_, _, err = ps.differences()
+	assert.EqualError(t, err, "error querying installed fake packages: not available")
+}
+
+func TestPackageSet_Lookup(t *testing.T) {
+	ps := &packageSet[fakePackage]{provider: &fakeProvider{}}
+	pkgs := []fakePackage{{name: "vim"}, {name: "vim", user: true}, {name: "vim"}}
+
+	assert.Equal(t, []fakePackage{{name: "vim", user: true}, {name: "vim"}}, ps.lookup(pkgs, []string{"user:vim", "missing", "vim"}))
+	assert.Equal(t, "vim user:vim vim", strings.Join(ps.ids(pkgs), " "))
+}
\ No newline at end of file
@@ -0,0 +1,55 @@
+package cmds
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/utils"
+)
+
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Show the actions apply would take without making any changes",
+	Run: func(cmd *cobra.Command, args []string) {
+		runApply(cmd, true)
+	},
+}
+
+// printPlannedPatch prints the lines that would be removed from and added to a file when patching it.
+func printPlannedPatch(path string, original, patched []byte) {
+	diff := utils.DiffLines(strings.Split(string(patched), "\n"), strings.Split(string(original), "\n"))
+	if !slices.ContainsFunc(diff, func(line utils.DiffLine) bool { return line.Op != ' ' }) {
+		return
+	}
+
+	color.Set(color.FgYellow)
+	fmt.Print("Would patch file: ")
+	color.Set(color.Bold)
+	fmt.Println(path)
+	color.Unset()
+
+	for _, line := range diff {
+		switch line.Op {
+		case '-':
+			color.Set(color.FgRed)
+			fmt.Println("  - " + line.Text)
+		case '+':
+			color.Set(color.FgGreen)
+			fmt.Println("  + " + line.Text)
+		}
+	}
+	color.Unset()
+}
+
+func init() {
+	planCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file, repeated to layer files over the first one")
+	planCmd.PersistentFlags().BoolP("bare", "b", false, "Plan only essential packages with the +bare tag (equivalent to --tags=\"-default +bare\")")
+
+	planCmd.PersistentFlags().StringSlice("tags", []string{}, "List of tags or presets to include/exclude, e.g. '-default +bare'")
+
+	rootCmd.AddCommand(planCmd)
+}
\ No newline at end of file
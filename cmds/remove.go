@@ -0,0 +1,126 @@
+package cmds
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+var removeCmd = &cobra.Command{
+	Use:   "remove <provider> <package>...",
+	Short: "Remove packages from the configuration",
+	Long: "Remove packages from the package section of a provider (pacman, aur or flatpak) in the configuration file, keeping the rest of the file unchanged.\n" +
+		"Packages declared together with others, e.g. `package = man-db man-pages`, are removed from the line without changing the others.",
+	Args: cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		configPath := configPaths(cmd)[0]
+
+		providerName, pkgs := args[0], args[1:]
+		if !checkProvider(providerName) {
+			return
+		}
+
+		doc, section, ok := loadConfigDocument(configPath)
+		if !ok {
+			return
+		}
+
+		removed := []string{}
+		for _, pkg := range uniquePackages(pkgs) {
+			count := removePackageValues(doc, "packages/"+providerName+"/package", pkg)
+			if providerName == "flatpak" {
+				count += removeFlatpakPackageSections(doc, pkg)
+			}
+
+			if count > 0 {
+				removed = append(removed, pkg)
+			} else if slices.Contains(declaredPackages(section, providerName), pkg) {
+				color.Set(color.FgYellow)
+				fmt.Printf("Package %s is declared in a sourced file, which must be edited directly.\n", pkg)
+				color.Unset()
+			} else {
+				color.Set(color.FgYellow)
+				fmt.Printf("Package %s is not declared in packages/%s.\n", pkg, providerName)
+				color.Unset()
+			}
+		}
+		if len(removed) == 0 {
+			return
+		}
+
+		if !saveConfigDocument(cmd, doc, configPath) {
+			return
+		}
+
+		color.Set(color.FgGreen)
+		fmt.Printf("Removed %s from packages/%s.\n", strings.Join(removed, ", "), providerName)
+		color.Unset()
+
+		if apply, _ := cmd.Flags().GetBool("apply"); apply {
+			runApply(cmd, false)
+		}
+	},
+}
+
+// removePackageValues removes a package from the values at a path, and returns how many values declared it.
+// Values that declare other packages too are kept without the package.
+func removePackageValues(doc *parser.Document, path string, pkg string) int {
+	count := 0
+	for _, node := range doc.Values(path) {
+		names, tags, hasTags := strings.Cut(node.Value, ",")
+		fields := strings.Fields(names)
+		if !slices.Contains(fields, pkg) {
+			continue
+		}
+		count++
+
+		fields = slices.DeleteFunc(fields, func(field string) bool { return field == pkg })
+		if len(fields) > 0 {
+			value := strings.Join(fields, " ")
+			if hasTags {
+				value += "," + tags
+			}
+			node.SetValue(value)
+		}
+	}
+
+	doc.RemoveFunc(path, func(node *parser.Node) bool {
+		names, _, _ := strings.Cut(node.Value, ",")
+		return node.Type == parser.NodeValue && strings.TrimSpace(names) == pkg
+	})
+	return count
+}
+
+// removeFlatpakPackageSections removes a package from the Flatpak `package` sections,
+// removing the sections that have no names left, and returns how many sections declared it.
+func removeFlatpakPackageSections(doc *parser.Document, pkg string) int {
+	count := 0
+	sections := doc.Sections("packages/flatpak/package")
+	for i := len(sections) - 1; i >= 0; i-- {
+		sectionPath := fmt.Sprintf("packages/flatpak/package[%d]", i)
+
+		removed := removePackageValues(doc, sectionPath+"/name", pkg)
+		if removed == 0 {
+			continue
+		}
+		count += removed
+
+		if len(doc.Values(sectionPath+"/name")) == 0 {
+			doc.RemoveFunc("packages/flatpak/package", func(node *parser.Node) bool { return node == sections[i] })
+		}
+	}
+	return count
+}
+
+func init() {
+	removeCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file to remove packages from, followed by the files layered over it for --apply")
+	removeCmd.PersistentFlags().Bool("apply", false, "Apply the configuration after removing the packages")
+	removeCmd.PersistentFlags().Bool("dry-run", false, "Print the changes to the configuration file without writing it")
+
+	rootCmd.AddCommand(removeCmd)
+}
\ No newline at end of file
@@ -0,0 +1,66 @@
+package cmds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+func TestRemovePackageValues(t *testing.T) {
+	doc, err := parser.ParseDocument("packages {\n  pacman {\n    package = man-db man-pages, +bare # docs\n    package = htop\n    package = git\n  }\n}\n", "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, removePackageValues(doc, "packages/pacman/package", "man-db"))
+	assert.Equal(t, 1, removePackageValues(doc, "packages/pacman/package", "htop"))
+	assert.Equal(t, "packages {\n  pacman {\n    package = man-pages, +bare # docs\n    package = git\n  }\n}\n", doc.String())
+}
+
+func TestRemovePackageValues_Absent(t *testing.T) {
+	input := "packages {\n  pacman {\n    package = neovim git\n  }\n}\n"
+	doc, _ := parser.ParseDocument(input, "")
+
+	assert.Equal(t, 0, removePackageValues(doc, "packages/pacman/package", "vim"))
+	assert.Equal(t, 0, removePackageValues(doc, "packages/aur/package", "neovim"))
+	assert.Equal(t, input, doc.String())
+}
+
+func TestRemovePackageValues_RepeatedSections(t *testing.T) {
+	doc, err := parser.ParseDocument("packages {\n  pacman {\n    package = git\n  }\n}\n\npackages {\n  pacman {\n    package = htop neovim\n  }\n}\n", "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, removePackageValues(doc, "packages/pacman/package", "htop"))
+	assert.Equal(t, 1, removePackageValues(doc, "packages/pacman/package", "neovim"))
+	assert.Equal(t, "packages {\n  pacman {\n    package = git\n  }\n}\n\npackages {\n  pacman {\n  }\n}\n", doc.String())
+}
+
+func TestRemovePackageValues_Conditional(t *testing.T) {
+	doc, err := parser.ParseDocument("packages {\n  pacman {\n    if tag(gaming) {\n      package = steam\n    } else {\n      package = tlp powertop\n    }\n  }\n}\n", "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, removePackageValues(doc, "packages/pacman/package", "steam"))
+	assert.Equal(t, 1, removePackageValues(doc, "packages/pacman/package", "tlp"))
+	assert.Equal(t, "packages {\n  pacman {\n    if tag(gaming) {\n    } else {\n      package = powertop\n    }\n  }\n}\n", doc.String())
+}
+
+func TestRemoveFlatpakPackageSections(t *testing.T) {
+	doc, err := parser.ParseDocument("packages {\n  flatpak {\n    package {\n      name = com.spotify.Client\n      remote = flathub\n    }\n"+
+		"    package {\n      name = org.gnome.Boxes org.gnome.Maps\n    }\n  }\n}\n", "")
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, removeFlatpakPackageSections(doc, "com.spotify.Client"))
+	assert.Equal(t, 1, removeFlatpakPackageSections(doc, "org.gnome.Maps"))
+	assert.Equal(t, 0, removeFlatpakPackageSections(doc, "org.gnome.Maps"))
+	assert.Equal(t, "packages {\n  flatpak {\n    package {\n      name = org.gnome.Boxes\n    }\n  }\n}\n", doc.String())
+}
+func TestRemove_Duplicates(t *testing.T) {
+	doc, _ := parser.ParseDocument("packages {\n  pacman {\n    package = a b c\n  }\n}\n", "")
+
+	counts := []int{}
+	for _, pkg := range uniquePackages([]string{"a", "b", "a"}) {
+		counts = append(counts, removePackageValues(doc, "packages/pacman/package", pkg))
+	}
+	assert.Equal(t, []int{1, 1}, counts)
+	assert.Equal(t, "packages {\n  pacman {\n    package = c\n  }\n}\n", doc.String())
+}
\ No newline at end of file
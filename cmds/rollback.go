@@ -0,0 +1,172 @@
+package cmds
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/state"
+	"github.com/DevReaper0/declarch/utils"
+)
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback [generation]",
+	Short: "Apply an older generation",
+	Long:  "Apply an older generation. If no generation is given, the generation before the current one is applied.",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		utils.DryRun = dryRun
+
+		if !dryRun && !CheckRoot() {
+			return
+		}
+
+		st, _, err := state.Load(state.DefaultPath)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error loading state file: ")
+			color.Set(color.Bold)
+			fmt.Print(state.DefaultPath)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+		if dryRun {
+			st.Path = ""
+		}
+
+		number := 0
+		if len(args) > 0 {
+			number, err = strconv.Atoi(args[0])
+			if err != nil {
+				color.Set(color.FgRed)
+				fmt.Print("Invalid generation number: ")
+				color.Set(color.Bold)
+				fmt.Print(args[0])
+				color.Set(color.ResetBold)
+				fmt.Println(".")
+				color.Unset()
+				exitCode = 1
+				return
+			}
+		} else {
+			generations, err := state.ListGenerations(state.DefaultGenerationsDir)
+			if err != nil {
+				color.Set(color.FgRed)
+				fmt.Print("Error listing generations: ")
+				color.Set(color.Bold)
+				fmt.Print(state.DefaultGenerationsDir)
+				color.Set(color.ResetBold)
+				fmt.Println(":")
+				color.Unset()
+				fmt.Fprintln(os.Stderr, err)
+				exitCode = 1
+				return
+			}
+			for _, gen := range generations {
+				if gen.Number < st.Generation {
+					number = gen.Number
+				}
+			}
+			if number == 0 {
+				color.Set(color.FgRed)
+				fmt.Println("There is no generation before the current one to roll back to.")
+				color.Unset()
+				exitCode = 1
+				return
+			}
+		}
+
+		gen, err := state.LoadGeneration(state.DefaultGenerationsDir, number)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error loading generation: ")
+			color.Set(color.Bold)
+			fmt.Print(number)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		section, err := parser.Parse(gen.Config)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error parsing configuration of generation: ")
+			color.Set(color.Bold)
+			fmt.Print(number)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		tagSet = modules.NewTagSet(gen.Tags...)
+
+		if dryRun {
+			color.Set(color.FgCyan, color.Bold)
+			fmt.Printf("Planned actions for rolling back to generation %d:\n", number)
+			color.Unset()
+		}
+
+		if err := Apply(section, st); err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error applying generation: ")
+			color.Set(color.Bold)
+			fmt.Print(number)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		if dryRun {
+			color.Set(color.FgGreen, color.Bold)
+			fmt.Println("\nDry run complete. No changes were made.")
+			color.Unset()
+			return
+		}
+
+		st.Generation = number
+		if err := st.Save(); err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error saving state file: ")
+			color.Set(color.Bold)
+			fmt.Print(state.DefaultPath)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		color.Set(color.FgGreen, color.Bold)
+		fmt.Printf("\nRolled back to generation %d.\n", number)
+		color.Unset()
+		color.Set(color.FgYellow)
+		fmt.Println("The configuration file was not changed, so the next apply will apply it again.")
+		color.Unset()
+	},
+}
+
+func init() {
+	rollbackCmd.PersistentFlags().Bool("dry-run", false, "Print the actions that would be taken without making any changes")
+
+	rootCmd.AddCommand(rollbackCmd)
+}
\ No newline at end of file
@@ -11,6 +11,9 @@ import (
 
 var defaultConfig string
 
+// exitCode is the exit status of the process, set by commands that fail.
+var exitCode = 0
+
 var rootCmd = &cobra.Command{
 	Use:   "declarch",
 	Short: "DeclArch is a tool for declaratively managing an Arch Linux system",
@@ -19,11 +22,14 @@ var rootCmd = &cobra.Command{
 
 func Execute(defaultCfg string) {
 	defaultConfig = defaultCfg
-	rootCmd.Execute()
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(exitCode)
 }
 
 // CheckRoot checks if the current user is root and prints an error message if not
-// Returns true if the user is root, false otherwise
+// Returns true if the user is root, false otherwise (and sets a failing exit status)
 func CheckRoot() bool {
 	currentUser, err := user.Current()
 	if err != nil {
@@ -31,6 +37,7 @@ func CheckRoot() bool {
 		fmt.Print("Error getting current user: ")
 		color.Unset()
 		fmt.Fprintln(os.Stderr, err)
+		exitCode = 1
 		return false
 	}
 
@@ -39,6 +46,7 @@ func CheckRoot() bool {
 		fmt.Println("This command requires root privileges.")
 		fmt.Println("Please rerun the command as root.")
 		color.Unset()
+		exitCode = 1
 		return false
 	}
 
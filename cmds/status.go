@@ -0,0 +1,337 @@
+package cmds
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
+	"github.com/DevReaper0/declarch/state"
+	"github.com/DevReaper0/declarch/utils"
+)
+
+// lookupUser finds the system users that userDifferences compares to the declared ones.
+// It is a variable so that tests can replace it.
+var lookupUser = modules.LookupUser
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show differences between the configuration and the installed system",
+	Run: func(cmd *cobra.Command, args []string) {
+		paths := configPaths(cmd)
+		configPath := paths[0]
+
+		section, _, err := parseConfigWithTags(cmd, paths)
+		if err != nil {
+			color.Set(color.FgRed)
+			if errors.Is(err, fs.ErrNotExist) {
+				fmt.Print("Configuration file not found: ")
+				color.Set(color.Bold)
+				fmt.Print(configPath)
+				color.Set(color.ResetBold)
+				fmt.Println(".")
+				color.Unset()
+				exitCode = 1
+				return
+			}
+			fmt.Print("Error parsing configuration file: ")
+			color.Set(color.Bold)
+			fmt.Print(configPath)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		st, err := loadState(configPath)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error loading state file: ")
+			color.Set(color.Bold)
+			fmt.Print(state.DefaultPath)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		modules.PrimaryUser = section.GetFirst("users/primary_user", schema.Default("users/primary_user"))
+
+		drifts, err := Status(section, st)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error checking system state: ")
+			color.Set(color.Bold)
+			fmt.Print(configPath)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		hasDrift := false
+		for _, drift := range drifts {
+			if drift.Empty() {
+				continue
+			}
+			hasDrift = true
+
+			color.Set(color.FgCyan, color.Bold)
+			fmt.Println(drift.Subsystem + ":")
+			color.Unset()
+			printDriftItems("Missing", color.FgRed, drift.Missing)
+			printDriftItems("Not removed", color.FgYellow, drift.Extra)
+			printDriftItems("Modified", color.FgYellow, drift.Modified)
+			printDriftItems("Undeclared", color.FgYellow, drift.Undeclared)
+		}
+
+		if !hasDrift {
+			color.Set(color.FgGreen, color.Bold)
+			fmt.Println("System matches the configuration.")
+			color.Unset()
+		}
+	},
+}
+
+// Drift describes how the installed system differs from the configuration for one subsystem.
+type Drift struct {
+	Subsystem string
+	// Missing contains items that are declared, but not present on the system.
+	Missing []string
+	// Extra contains items that were removed from the configuration, but are still present on the system.
+	Extra []string
+	// Modified contains items that are present on the system, but differ from their declaration.
+	Modified []string
+	// Undeclared contains items that are explicitly installed, but not declared, if strict mode is enabled.
+	Undeclared []string
+}
+
+func (d Drift) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Modified) == 0 && len(d.Undeclared) == 0
+}
+
+// Status compares the configuration and the applied state against the installed system,
+// and reports the drift for each subsystem.
+func Status(section *parser.Section, st *state.State) ([]Drift, error) {
+	drifts := []Drift{}
+
+	currentUsers, err := getUsers(section)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing user configuration: %w", err)
+	}
+	addedUsernames, removedUsernames, modifiedUsernames, err := userDifferences(currentUsers, st.Usernames())
+	if err != nil {
+		return nil, err
+	}
+	drifts = append(drifts, Drift{Subsystem: "Users", Missing: addedUsernames, Extra: removedUsernames, Modified: modifiedUsernames})
+
+	packageSets := []struct {
+		subsystem    string
+		providerName string
+		kind         string
+		current      []string
+	}{
+		{"Kernels", "pacman", "kernel", tagSet.GetAll(section, "essentials/kernel")},
+		{"Bootloader", "pacman", "bootloader", section.GetList("essentials/bootloader", schema.Default("essentials/bootloader"))},
+		{"Network handler", "pacman", "network_handler", section.GetList("essentials/network_handler", schema.Default("essentials/network_handler"))},
+		{"Pacman", "pacman", "pacman", tagSet.GetAll(section, "packages/pacman/package")},
+		{"AUR", "aur", "aur", tagSet.GetAll(section, "packages/aur/package")},
+	}
+	for _, set := range packageSets {
+		packages, err := newPackageSet(set.providerName, set.kind, set.current, st.GetPackages(set.kind), nil)
+		if err != nil {
+			return nil, err
+		}
+		added, removed, err := packages.differences()
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, Drift{Subsystem: set.subsystem, Missing: added, Extra: removed})
+	}
+
+	addedRemotes, removedRemotes, err := flatpakRemoteDifferences(getAllSections(section, "packages/flatpak/remote"), st.FlatpakRemoteIdentifiers())
+	if err != nil {
+		return nil, err
+	}
+	drifts = append(drifts, Drift{Subsystem: "Flatpak remotes", Missing: addedRemotes, Extra: removedRemotes})
+
+	flatpakPackages, err := newPackageSet("flatpak", "flatpak", getFlatpakPackages(section), st.FlatpakPackageList(), nil)
+	if err != nil {
+		return nil, err
+	}
+	addedFlatpakPackages, removedFlatpakPackages, err := flatpakPackages.differences()
+	if err != nil {
+		return nil, err
+	}
+	drifts = append(drifts, Drift{Subsystem: "Flatpak", Missing: flatpakPackages.ids(addedFlatpakPackages), Extra: flatpakPackages.ids(removedFlatpakPackages)})
+
+	// Report undeclared packages of package sections in strict mode
+	for i, drift := range drifts {
+		var undeclared []string
+		switch drift.Subsystem {
+		case "Pacman", "AUR":
+			sectionPath := "packages/" + strings.ToLower(drift.Subsystem)
+			if strict, _, err := strictMode(section, sectionPath); err != nil {
+				return nil, err
+			} else if strict {
+				if undeclared, err = undeclaredPackages(section, sectionPath, drift.Subsystem == "AUR"); err != nil {
+					return nil, err
+				}
+			}
+		case "Flatpak":
+			if strict, _, err := strictMode(section, "packages/flatpak"); err != nil {
+				return nil, err
+			} else if strict {
+				pkgs, err := undeclaredFlatpakPackages(section)
+				if err != nil {
+					return nil, err
+				}
+				for _, pkg := range pkgs {
+					undeclared = append(undeclared, pkg.Name)
+				}
+			}
+		}
+		drifts[i].Undeclared = undeclared
+	}
+
+	return drifts, nil
+}
+
+func printDriftItems(label string, attribute color.Attribute, items []string) {
+	if len(items) == 0 {
+		return
+	}
+	color.Set(attribute)
+	fmt.Print("  " + label + ": ")
+	color.Unset()
+	fmt.Println(strings.Join(items, ", "))
+}
+
+// reconcileDifferences works like utils.GetDifferences, but checks the current and previous configurations
+// against what is actually present on the system.
+// Items are added if they are declared but not present,
+// and removed if they are no longer declared but still present.
+func reconcileDifferences(current, previous, present []string) ([]string, []string) {
+	added, _ := utils.GetDifferences(current, present)
+	_, removed := utils.GetDifferences(current, previous)
+	removed = slices.DeleteFunc(removed, func(s string) bool {
+		return !slices.Contains(present, s)
+	})
+	return added, removed
+}
+
+// userDifferences returns the usernames of users that need to be created, deleted and modified,
+// based on the configuration, the previously applied users and the users that exist on the system.
+func userDifferences(currentUsers map[string]modules.User, previousUsernames []string) ([]string, []string, []string, error) {
+	currentUsernames := make([]string, 0, len(currentUsers))
+	existingUsernames := []string{}
+	modifiedUsernames := []string{}
+
+	for username, currentUser := range currentUsers {
+		currentUsernames = append(currentUsernames, username)
+
+		actualUser, exists, err := lookupUser(username)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error looking up user %s: %w", username, err)
+		}
+		if exists {
+			existingUsernames = append(existingUsernames, username)
+			if userNeedsModification(currentUser, managedUser(currentUser, actualUser)) {
+				modifiedUsernames = append(modifiedUsernames, username)
+			}
+		}
+	}
+	for _, username := range previousUsernames {
+		if _, declared := currentUsers[username]; declared {
+			continue
+		}
+		_, exists, err := lookupUser(username)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error looking up user %s: %w", username, err)
+		}
+		if exists {
+			existingUsernames = append(existingUsernames, username)
+		}
+	}
+
+	slices.Sort(currentUsernames)
+	slices.Sort(modifiedUsernames)
+
+	added, removed := reconcileDifferences(currentUsernames, previousUsernames, existingUsernames)
+	return added, removed, modifiedUsernames, nil
+}
+
+// managedUser returns the system state of a user, limited to the fields managed by its declaration.
+// Fields that are not declared are copied from the declaration so that they are never seen as modified.
+func managedUser(declared, actual modules.User) modules.User {
+	managed := actual
+	managed.CreateHome = declared.CreateHome
+
+	if declared.FullName == "" {
+		managed.FullName = declared.FullName
+	}
+	if declared.HomeDir == "" {
+		managed.HomeDir = declared.HomeDir
+	}
+	if declared.Shell == "" || utils.GetApplicationPath(declared.Shell) == actual.Shell {
+		managed.Shell = declared.Shell
+	}
+
+	return managed
+}
+
+// userNeedsModification reports whether the fields of two user configurations differ.
+func userNeedsModification(currentUser, previousUser modules.User) bool {
+	needsModification := currentUser.FullName != previousUser.FullName ||
+		currentUser.Shell != previousUser.Shell ||
+		currentUser.CreateHome != previousUser.CreateHome ||
+		currentUser.HomeDir != previousUser.HomeDir
+
+	// Check if groups are different
+	if !needsModification {
+		addedGroups, removedGroups := utils.GetDifferences(currentUser.Groups, previousUser.Groups)
+		needsModification = len(addedGroups) > 0 || len(removedGroups) > 0
+	}
+
+	return needsModification
+}
+
+// flatpakRemoteDifferences returns the identifiers of remotes that need to be added and removed,
+// based on the configuration, the previously applied remotes and the remotes configured in Flatpak.
+func flatpakRemoteDifferences(currentRemotes []*parser.Section, previousIdentifiers []string) ([]string, []string, error) {
+	configuredRemotes, err := modules.FlatpakQueryRemotes()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error querying Flatpak remotes: %w", err)
+	}
+
+	present := []string{}
+	for _, remote := range configuredRemotes {
+		present = append(present, modules.FlatpakIdentifier(remote.Name, remote.Installation, remote.UserInstallation))
+	}
+
+	added, removed := reconcileDifferences(getFlatpakRemoteIdentifiers(currentRemotes), previousIdentifiers, present)
+	return added, removed, nil
+}
+
+func init() {
+	statusCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file, repeated to layer files over the first one")
+	statusCmd.PersistentFlags().BoolP("bare", "b", false, "Only check essential packages with the +bare tag (equivalent to --tags=\"-default +bare\")")
+
+	statusCmd.PersistentFlags().StringSlice("tags", []string{}, "List of tags or presets to include/exclude, e.g. '-default +bare'")
+
+	rootCmd.AddCommand(statusCmd)
+}
\ No newline at end of file
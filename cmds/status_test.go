@@ -0,0 +1,102 @@
+package cmds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/modules"
+)
+
+func TestReconcileDifferences(t *testing.T) {
+	tests := []struct {
+		name              string
+		current, previous []string
+		present           []string
+		added, removed    []string
+	}{
+		{"in sync", []string{"a", "b"}, []string{"a", "b"}, []string{"a", "b"}, []string{}, []string{}},
+		{"declared but missing", []string{"a", "b"}, []string{"a", "b"}, []string{"a"}, []string{"b"}, []string{}},
+		{"no longer declared", []string{"a"}, []string{"a", "b"}, []string{"a", "b"}, []string{}, []string{"b"}},
+		{"no longer declared and already gone", []string{"a"}, []string{"a", "b"}, []string{"a"}, []string{}, []string{}},
+		{"never declared", []string{"a"}, []string{"a"}, []string{"a", "c"}, []string{}, []string{}},
+	}
+
+	for _, test := range tests {
+		added, removed := reconcileDifferences(test.current, test.previous, test.present)
+		assert.ElementsMatch(t, test.added, added, test.name)
+		assert.ElementsMatch(t, test.removed, removed, test.name)
+	}
+}
+
+func TestManagedUser(t *testing.T) {
+	actual := modules.User{Username: "alice", FullName: "Alice", Shell: "/bin/zsh", CreateHome: false, HomeDir: "/home/alice", Groups: []string{"wheel"}}
+
+	tests := []struct {
+		name     string
+		declared modules.User
+		modified bool
+	}{
+		{"undeclared fields", modules.User{Username: "alice", CreateHome: true, Groups: []string{"wheel"}}, false},
+		{"shell by name", modules.User{Username: "alice", Shell: "zsh", Groups: []string{"wheel"}}, false},
+		{"shell by path", modules.User{Username: "alice", Shell: "/bin/zsh", Groups: []string{"wheel"}}, false},
+		{"other shell", modules.User{Username: "alice", Shell: "bash", Groups: []string{"wheel"}}, true},
+		{"other full name", modules.User{Username: "alice", FullName: "Alice Liddell", Groups: []string{"wheel"}}, true},
+		{"other groups", modules.User{Username: "alice", Groups: []string{"wheel", "video"}}, true},
+	}
+
+	for _, test := range tests {
+		managed := managedUser(test.declared, actual)
+		assert.Equal(t, test.declared.CreateHome, managed.CreateHome, test.name)
+		assert.Equal(t, test.modified, userNeedsModification(test.declared, managed), test.name)
+	}
+}
+
+func TestUserNeedsModification(t *testing.T) {
+	user := modules.User{Username: "alice", FullName: "Alice", Shell: "/bin/zsh", CreateHome: true, HomeDir: "/home/alice", Groups: []string{"wheel", "video"}}
+
+	tests := []struct {
+		name     string
+		modify   func(user *modules.User)
+		modified bool
+	}{
+		{"same", func(user *modules.User) {}, false},
+		{"groups in another order", func(user *modules.User) { user.Groups = []string{"video", "wheel"} }, false},
+		{"full name", func(user *modules.User) { user.FullName = "" }, true},
+		{"shell", func(user *modules.User) { user.Shell = "/bin/bash" }, true},
+		{"create home", func(user *modules.User) { user.CreateHome = false }, true},
+		{"home directory", func(user *modules.User) { user.HomeDir = "/srv/alice" }, true},
+		{"groups", func(user *modules.User) { user.Groups = []string{"wheel"} }, true},
+	}
+
+	for _, test := range tests {
+		previous := user
+		previous.Groups = append([]string{}, user.Groups...)
+		test.modify(&previous)
+		assert.Equal(t, test.modified, userNeedsModification(user, previous), test.name)
+	}
+}
+
+func TestUserDifferences(t *testing.T) {
+	t.Cleanup(func() { lookupUser = modules.LookupUser })
+	system := map[string]modules.User{
+		"alice": {Username: "alice", Shell: "/bin/zsh", Groups: []string{"wheel"}},
+		"bob":   {Username: "bob", Shell: "/bin/bash"},
+		"carol": {Username: "carol"},
+	}
+	lookupUser = func(username string) (modules.User, bool, error) {
+		user, exists := system[username]
+		return user, exists, nil
+	}
+
+	current := map[string]modules.User{
+		"alice": {Username: "alice", Shell: "zsh", Groups: []string{"wheel"}},
+		"bob":   {Username: "bob", Shell: "zsh"},
+		"dave":  {Username: "dave"},
+	}
+	added, removed, modified, err := userDifferences(current, []string{"alice", "carol", "erin"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"dave"}, added)
+	assert.Equal(t, []string{"carol"}, removed)
+	assert.Equal(t, []string{"bob"}, modified)
+}
\ No newline at end of file
@@ -0,0 +1,145 @@
+package cmds
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
+)
+
+// Packages that are never removed in strict mode, in addition to the essentials and the `protected` values.
+var builtinProtectedPackages = []string{"base", "base-devel", "git", "flatpak"}
+
+// queryExplicitPackages and queryFlatpakApps list the installed packages that strict mode compares to the declared ones.
+// They are variables so that tests can replace them.
+var (
+	queryExplicitPackages = modules.PacmanQueryExplicit
+	queryFlatpakApps      = modules.FlatpakQueryApps
+)
+
+// strictMode returns whether strict mode is enabled for a package section (e.g. "packages/pacman"),
+// and the action to take for undeclared packages ("remove" or, except for Flatpak, "mark_dependency").
+func strictMode(section *parser.Section, sectionPath string) (bool, string, error) {
+	strict, err := section.GetBool(sectionPath+"/strict", schema.Default(sectionPath+"/strict"))
+	if err != nil {
+		return false, "", err
+	}
+
+	action := section.GetFirst(sectionPath+"/strict_action", schema.Default(sectionPath+"/strict_action"))
+	if !slices.Contains(schema.Lookup(sectionPath+"/strict_action").Allowed, action) {
+		return false, "", fmt.Errorf("invalid value for 'strict_action' field in %s section: %s", sectionPath, action)
+	}
+
+	return strict, action, nil
+}
+
+// protectedPackages returns the packages that are never removed in strict mode.
+// These are the kernels, bootloader, network handler and AUR helper,
+// packages that apply always installs, and the `protected` values of the package section.
+func protectedPackages(section *parser.Section, sectionPath string) []string {
+	protected := slices.Clone(builtinProtectedPackages)
+	protected = append(protected, tagSet.GetAll(section, "essentials/kernel")...)
+	protected = append(protected, section.GetList("essentials/bootloader", schema.Default("essentials/bootloader"))...)
+	protected = append(protected, section.GetList("essentials/network_handler", schema.Default("essentials/network_handler"))...)
+
+	aurHelper := section.GetFirst("packages/aur/helper", schema.Default("packages/aur/helper"))
+	protected = append(protected, aurHelper, aurHelper+"-bin", aurHelper+"-git")
+
+	return append(protected, section.GetList(sectionPath+"/protected", "")...)
+}
+
+// undeclaredPackages returns the explicitly installed packages that are neither declared nor protected.
+// If foreign is true, only packages that are not in the sync repositories are checked, otherwise only packages that are.
+func undeclaredPackages(section *parser.Section, sectionPath string, foreign bool) ([]string, error) {
+	explicit, err := queryExplicitPackages(foreign)
+	if err != nil {
+		return nil, fmt.Errorf("error querying explicitly installed packages: %w", err)
+	}
+
+	declared := tagSet.GetAll(section, sectionPath+"/package")
+	protected := protectedPackages(section, sectionPath)
+
+	return slices.DeleteFunc(explicit, func(pkg string) bool {
+		return slices.Contains(declared, pkg) || slices.Contains(protected, pkg)
+	}), nil
+}
+
+// undeclaredFlatpakPackages returns the installed Flatpak applications that are neither declared nor protected.
+func undeclaredFlatpakPackages(section *parser.Section) ([]modules.FlatpakPackage, error) {
+	installed, err := queryFlatpakApps()
+	if err != nil {
+		return nil, fmt.Errorf("error querying installed Flatpak packages: %w", err)
+	}
+
+	declared := []string{}
+	for _, pkg := range getFlatpakPackages(section) {
+		declared = append(declared, pkg.Identifier())
+	}
+	protected := section.GetList("packages/flatpak/protected", "")
+
+	return slices.DeleteFunc(installed, func(pkg modules.FlatpakPackage) bool {
+		return slices.Contains(declared, pkg.Identifier()) ||
+			slices.Contains(protected, pkg.Name)
+	}), nil
+}
+
+// applyStrictPackages removes the undeclared packages of a package section if strict mode is enabled,
+// or marks them as dependencies if the strict action is `mark_dependency`.
+func applyStrictPackages(section *parser.Section, sectionPath string, foreign bool, packages *packageSet[string]) error {
+	strict, action, err := strictMode(section, sectionPath)
+	if err != nil || !strict {
+		return err
+	}
+
+	undeclared, err := undeclaredPackages(section, sectionPath, foreign)
+	if err != nil || len(undeclared) == 0 {
+		return err
+	}
+
+	printUndeclaredPackages(sectionPath, undeclared, action)
+
+	if action == "mark_dependency" {
+		return packages.removeWith(undeclared, modules.PacmanMarkDependency)
+	}
+	return packages.remove(undeclared)
+}
+
+// applyStrictFlatpakPackages removes the undeclared Flatpak applications if strict mode is enabled.
+// Flatpak has no dependency marking, so undeclared applications are always removed.
+func applyStrictFlatpakPackages(section *parser.Section, packages *packageSet[modules.FlatpakPackage]) error {
+	strict, _, err := strictMode(section, "packages/flatpak")
+	if err != nil || !strict {
+		return err
+	}
+
+	undeclared, err := undeclaredFlatpakPackages(section)
+	if err != nil || len(undeclared) == 0 {
+		return err
+	}
+
+	names := make([]string, len(undeclared))
+	for i, pkg := range undeclared {
+		names[i] = pkg.Name
+	}
+	printUndeclaredPackages("packages/flatpak", names, "remove")
+
+	return packages.remove(undeclared)
+}
+
+func printUndeclaredPackages(sectionPath string, pkgs []string, action string) {
+	color.Set(color.FgYellow)
+	fmt.Print("Undeclared packages in " + sectionPath)
+	if action == "mark_dependency" {
+		fmt.Print(" (marking as dependencies)")
+	} else {
+		fmt.Print(" (removing)")
+	}
+	fmt.Print(": ")
+	color.Unset()
+	fmt.Println(strings.Join(pkgs, ", "))
+}
\ No newline at end of file
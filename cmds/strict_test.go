@@ -0,0 +1,142 @@
+package cmds
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/parser"
+)
+
+// fakeExplicitPackages replaces the pacman query with the given native and foreign packages for a test.
+func fakeExplicitPackages(t *testing.T, native, foreign []string) {
+	t.Cleanup(func() { queryExplicitPackages = modules.PacmanQueryExplicit })
+	queryExplicitPackages = func(isForeign bool) ([]string, error) {
+		if isForeign {
+			return append([]string{}, foreign...), nil
+		}
+		return append([]string{}, native...), nil
+	}
+}
+
+func TestProtectedPackages(t *testing.T) {
+	tagSet = modules.NewTagSet("+default", "-server")
+
+	tests := []struct {
+		name      string
+		config    string
+		protected []string
+		removable []string
+	}{
+		{
+			name:      "defaults",
+			config:    "",
+			protected: []string{"base", "base-devel", "git", "flatpak", "grub", "efibootmgr", "networkmanager", "makepkg", "makepkg-bin", "makepkg-git"},
+			removable: []string{"linux", "yay"},
+		},
+		{
+			name: "configured",
+			config: "essentials {\n  kernel = linux-lts\n  kernel = linux-zen, +server\n  bootloader = systemd-boot\n  network_handler = iwd\n}\n" +
+				"packages {\n  aur {\n    helper = yay\n  }\n  pacman {\n    protected = vim nano\n  }\n}\n",
+			protected: []string{"base", "linux-lts", "systemd-boot", "iwd", "yay", "yay-bin", "yay-git", "vim", "nano"},
+			removable: []string{"linux-zen", "grub", "networkmanager", "makepkg", "paru"},
+		},
+	}
+
+	for _, test := range tests {
+		section, err := parser.Parse(test.config)
+		assert.NoError(t, err, test.name)
+
+		protected := protectedPackages(section, "packages/pacman")
+		for _, pkg := range test.protected {
+			assert.Contains(t, protected, pkg, test.name)
+		}
+		for _, pkg := range test.removable {
+			assert.NotContains(t, protected, pkg, test.name)
+		}
+	}
+}
+
+func TestUndeclaredPackages(t *testing.T) {
+	tagSet = modules.NewTagSet("+default", "-gaming")
+	config := "essentials {\n  kernel = linux\n}\npackages {\n  aur {\n    helper = paru\n    package = spotify\n  }\n" +
+		"  pacman {\n    package = neovim htop\n    package = steam, +gaming\n    protected = nano\n  }\n}\n"
+	section, err := parser.Parse(config)
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		sectionPath string
+		foreign     bool
+		installed   []string
+		undeclared  []string
+	}{
+		{"native", "packages/pacman", false,
+			[]string{"base", "base-devel", "linux", "grub", "efibootmgr", "networkmanager", "neovim", "htop", "nano", "steam", "firefox"},
+			[]string{"steam", "firefox"}},
+		{"foreign", "packages/aur", true,
+			[]string{"paru-bin", "spotify", "yay", "discord"},
+			[]string{"yay", "discord"}},
+		{"nothing installed", "packages/pacman", false, nil, []string{}},
+	}
+
+	for _, test := range tests {
+		if test.foreign {
+			fakeExplicitPackages(t, nil, test.installed)
+		} else {
+			fakeExplicitPackages(t, test.installed, nil)
+		}
+
+		undeclared, err := undeclaredPackages(section, test.sectionPath, test.foreign)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.undeclared, undeclared, test.name)
+	}
+}
+
+func TestUndeclaredPackages_QueryError(t *testing.T) {
+	t.Cleanup(func() { queryExplicitPackages = modules.PacmanQueryExplicit })
+	queryExplicitPackages = func(bool) ([]string, error) { return nil, errors.New("pacman not found") }
+
+	section, _ := parser.Parse("")
+	_, err := undeclaredPackages(section, "packages/pacman", false)
+	assert.EqualError(t, err, "error querying explicitly installed packages: pacman not found")
+}
+
+func TestApplyStrictPackages(t *testing.T) {
+	tagSet = modules.NewTagSet("+default")
+
+	tests := []struct {
+		name    string
+		config  string
+		queried bool
+		err     string
+	}{
+		{"disabled", "packages {\n  pacman {\n    package = neovim\n  }\n}\n", false, ""},
+		{"nothing undeclared", "packages {\n  pacman {\n    strict = true\n    package = neovim\n  }\n}\n", true, ""},
+		{"invalid action", "packages {\n  pacman {\n    strict = true\n    strict_action = purge\n  }\n}\n", false,
+			"invalid value for 'strict_action' field in packages/pacman section: purge"},
+	}
+
+	for _, test := range tests {
+		queried := false
+		t.Cleanup(func() { queryExplicitPackages = modules.PacmanQueryExplicit })
+		queryExplicitPackages = func(bool) ([]string, error) {
+			queried = true
+			return []string{"base", "neovim"}, nil
+		}
+
+		section, err := parser.Parse(test.config)
+		assert.NoError(t, err, test.name)
+
+		// Nothing is removed in these cases, so no package set is needed
+		err = applyStrictPackages(section, "packages/pacman", false, nil)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err, test.name)
+		} else {
+			assert.NoError(t, err, test.name)
+		}
+		assert.Equal(t, test.queried, queried, test.name)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,220 @@
+package cmds
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
+)
+
+var tagsCmd = &cobra.Command{
+	Use:   "tags",
+	Short: "Show the effective tags and the packages each tag pulls in",
+	Long: "Show the tags selected on this machine, from the host mappings of the `tags` section, --tags and --bare,\n" +
+		"the tag presets, and the packages declared with each tag used in the configuration.",
+	Run: func(cmd *cobra.Command, args []string) {
+		paths := configPaths(cmd)
+		configPath := paths[0]
+
+		section, host, err := parseConfigWithTags(cmd, paths)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Print("Error parsing configuration file: ")
+			color.Set(color.Bold)
+			fmt.Print(configPath)
+			color.Set(color.ResetBold)
+			fmt.Println(":")
+			color.Unset()
+			fmt.Fprintln(os.Stderr, err)
+			exitCode = 1
+			return
+		}
+
+		color.Set(color.Bold)
+		fmt.Print("Effective tags: ")
+		color.Unset()
+		fmt.Println(strings.Join(tagSet.Tags(), " "))
+		if host != "" {
+			fmt.Printf("  including the tags of host %s\n", host)
+		}
+
+		presets := modules.NewTagPresets(firstSection(section, "tags"))
+		if names := presets.Presets(); len(names) > 0 {
+			fmt.Println()
+			color.Set(color.Bold)
+			fmt.Println("Presets:")
+			color.Unset()
+			for _, name := range names {
+				tags, _ := presets.Expand([]string{name})
+				fmt.Printf("  %s: %s\n", name, strings.Join(tags, " "))
+			}
+		}
+
+		packages := taggedPackages(section)
+		if len(packages) == 0 {
+			return
+		}
+
+		fmt.Println()
+		color.Set(color.Bold)
+		fmt.Println("Tags used in the configuration:")
+		color.Unset()
+		for _, name := range slices.Sorted(maps.Keys(packages)) {
+			if tagSet.Selected(name) {
+				color.Set(color.FgGreen)
+				fmt.Printf("  +%s (selected)\n", name)
+			} else {
+				color.Set(color.FgYellow)
+				fmt.Printf("  +%s (not selected)\n", name)
+			}
+			color.Unset()
+
+			for _, group := range packages[name] {
+				fmt.Printf("    %s: %s\n", group.label, strings.Join(group.names, " "))
+			}
+		}
+	},
+}
+
+type packageGroup struct {
+	label string
+	names []string
+}
+
+// taggedPackages returns the packages declared with each tag, grouped by kernel or provider, regardless of the selected tags.
+// Packages in a section with a `tags` key are listed under the tags of the section too.
+func taggedPackages(section *parser.Section) map[string][]packageGroup {
+	packages := map[string][]packageGroup{}
+	add := func(label, value, sectionTags string) {
+		names, tags, _ := strings.Cut(value, ",")
+
+		tagNames := []string{}
+		for _, tags := range []string{tags, sectionTags} {
+			// Invalid tags are reported by verify
+			if expr, err := modules.ParseTags(tags); err == nil {
+				tagNames = append(tagNames, expr.Names()...)
+			}
+		}
+
+		slices.Sort(tagNames)
+		for _, tag := range slices.Compact(tagNames) {
+			groups := packages[tag]
+			i := slices.IndexFunc(groups, func(group packageGroup) bool { return group.label == label })
+			if i == -1 {
+				groups = append(groups, packageGroup{label: label})
+				i = len(groups) - 1
+			}
+			groups[i].names = append(groups[i].names, strings.Fields(names)...)
+			packages[tag] = groups
+		}
+	}
+
+	for _, value := range section.GetAll("essentials/kernel") {
+		add("kernel", value, "")
+	}
+	for _, provider := range modules.Providers() {
+		sectionPath := "packages/" + provider.Name() + "/package"
+		for _, value := range section.GetAll(sectionPath) {
+			add(provider.Name(), value, "")
+		}
+		pkgSections, _ := section.QuerySections(sectionPath, nil)
+		for _, pkgSection := range pkgSections {
+			for _, value := range pkgSection.GetAll("name") {
+				add(provider.Name(), value, pkgSection.GetFirst("tags", ""))
+			}
+		}
+	}
+	return packages
+}
+
+// newTagSet returns the tags selected for a configuration: the default tag, the tags mapped to this machine
+// by hostname or machine ID in the `tags` section, then the tags and presets given with --tags, and --bare.
+// It also returns the host entry that matched this machine, if any.
+func newTagSet(cmd *cobra.Command, section *parser.Section) (*modules.TagSet, string, error) {
+	ts := modules.NewTagSet("+default")
+	presets := modules.NewTagPresets(firstSection(section, "tags"))
+
+	facts := parser.Facts()
+	hostTags, host, err := presets.HostTags(facts["hostname"], facts["machine_id"])
+	if err != nil {
+		return nil, "", fmt.Errorf("tags/host %s: %w", host, err)
+	}
+	ts.AddTags(hostTags)
+
+	if items, _ := cmd.Flags().GetStringSlice("tags"); len(items) > 0 {
+		tags, err := presets.Expand(strings.Fields(strings.Join(items, " ")))
+		if err != nil {
+			return nil, "", fmt.Errorf("--tags: %w", err)
+		}
+		ts.AddTags(tags)
+	}
+
+	if bare, _ := cmd.Flags().GetBool("bare"); bare {
+		ts.AddTags([]string{"-default", "+bare"})
+	}
+	return ts, host, nil
+}
+
+// configPaths returns the absolute paths of the configuration files given with -c, in the order they are layered.
+// The first one is the main configuration file, which is the one edited or created by the commands that write it.
+// It is never empty: the default configuration file is used if the flag has no value.
+func configPaths(cmd *cobra.Command) []string {
+	paths, _ := cmd.Flags().GetStringArray("config")
+	if len(paths) == 0 {
+		paths = []string{"/etc/declarch/declarch.conf"}
+	}
+	for i, path := range paths {
+		paths[i], _ = filepath.Abs(path)
+	}
+	return paths
+}
+
+// parseConfig parses the configuration files, each layered over the previous ones.
+func parseConfig(paths []string, opts parser.ParseOptions) (*parser.Section, error) {
+	opts.Repeated = schema.Repeated
+	return parser.ParseLayers(paths, opts)
+}
+
+// parseConfigWithTags parses the configuration files and sets tagSet to the tags selected for them,
+// which are used by the `if tag(...)` conditions of the configuration.
+// The files are parsed twice, since the tag presets and host mappings are part of the configuration.
+func parseConfigWithTags(cmd *cobra.Command, paths []string) (*parser.Section, string, error) {
+	section, err := parseConfig(paths, parser.ParseOptions{})
+	if err != nil {
+		return nil, "", err
+	}
+
+	ts, host, err := newTagSet(cmd, section)
+	if err != nil {
+		return nil, "", err
+	}
+	tagSet = ts
+
+	section, err = parseConfig(paths, parser.ParseOptions{HasTag: tagSet.Selected})
+	return section, host, err
+}
+
+// firstSection returns the first sub-section with the given name, or nil if there is none.
+func firstSection(section *parser.Section, name string) *parser.Section {
+	if sections := section.Sections[name]; len(sections) > 0 {
+		return sections[0]
+	}
+	return nil
+}
+
+func init() {
+	tagsCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file, repeated to layer files over the first one")
+	tagsCmd.PersistentFlags().BoolP("bare", "b", false, "Show the tags selected with --bare (equivalent to --tags=\"-default +bare\")")
+	tagsCmd.PersistentFlags().StringSlice("tags", []string{}, "List of tags or presets to include/exclude, e.g. '-default +bare'")
+
+	rootCmd.AddCommand(tagsCmd)
+}
\ No newline at end of file
@@ -1,298 +1,301 @@
 package cmds
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
+	"maps"
 	"os"
-	"path/filepath"
 	"slices"
-	"strconv"
 	"strings"
-	"unicode"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 
+	"github.com/DevReaper0/declarch/modules"
 	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
 )
 
 var verifyCmd = &cobra.Command{
 	Use:   "verify",
 	Short: "Verify configuration",
+	Long:  "Verify configuration, reporting every problem found. Exits with status 1 if the configuration has errors.",
 	Run: func(cmd *cobra.Command, args []string) {
-		configPath, _ := cmd.Flags().GetString("config")
-		configPath, _ = filepath.Abs(configPath)
-
-		section, err := parser.ParseFile(configPath)
-		if err != nil {
-			color.Set(color.FgRed)
-			if errors.Is(err, fs.ErrNotExist) {
-				fmt.Print("Configuration file not found: ")
-				color.Set(color.Bold)
-				fmt.Print(configPath)
-				color.Set(color.ResetBold)
-				fmt.Println(".")
-				color.Unset()
-				return
-			}
-			fmt.Print("Error parsing configuration file: ")
-			color.Set(color.Bold)
-			fmt.Print(configPath)
-			color.Set(color.ResetBold)
-			fmt.Println(":")
-			color.Unset()
-			fmt.Fprintln(os.Stderr, err)
-			return
-		}
-
-		if Verify(section) == "" {
-			color.Set(color.FgGreen, color.Bold)
-			fmt.Println("Configuration is valid.")
-		} else {
-			color.Set(color.FgRed, color.Bold)
-			fmt.Println("Configuration is invalid.")
-		}
-		color.Unset()
+		output, _ := cmd.Flags().GetString("output")
+		runVerify(configPaths(cmd), output)
 	},
 }
 
-func Verify(section *parser.Section) string {
-	privilegeEscalation := section.GetFirst("essentials/privilige_escalation", "sudo")
-	if !slices.Contains([]string{"sudo", "doas", "pkexec", "su"}, privilegeEscalation) {
-		return fmt.Sprintf("Value '%s' is not allowed for privilege escalation. Allowed values are: sudo, doas, pkexec, su", privilegeEscalation)
+// runVerify verifies the configuration files, printing the problems found as text or, if output is "json", as a JSON document.
+// exitCode is set if the configuration has errors, but not if it only has warnings.
+func runVerify(paths []string, output string) {
+	configPath := paths[0]
+	if output != "text" && output != "json" {
+		color.Set(color.FgRed)
+		fmt.Println("Invalid output format '" + output + "', expected 'text' or 'json'.")
+		color.Unset()
+		exitCode = 1
+		return
 	}
 
-	if v := verifyUsers(section); v != "" {
-		return v
+	var ds Diagnostics
+	section, err := parseConfig(paths, parser.ParseOptions{})
+	if err != nil {
+		ds = parseDiagnostics(err, configPath)
+	} else {
+		ds = Verify(section)
 	}
 
-	if v := verifyPacman(section); v != "" {
-		return v
+	if ds.HasErrors() {
+		exitCode = 1
 	}
 
-	if v := verifyAUR(section); v != "" {
-		return v
+	if output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(struct {
+			Config      string      `json:"config"`
+			Valid       bool        `json:"valid"`
+			Diagnostics Diagnostics `json:"diagnostics"`
+		}{configPath, !ds.HasErrors(), ds})
+		return
 	}
 
-	if v := verifyFlatpak(section); v != "" {
-		return v
+	if errors.Is(err, fs.ErrNotExist) {
+		color.Set(color.FgRed)
+		fmt.Print("Configuration file not found: ")
+		color.Set(color.Bold)
+		fmt.Print(configPath)
+		color.Set(color.ResetBold)
+		fmt.Println(".")
+		color.Unset()
+		return
 	}
 
-	return ""
+	printDiagnostics(ds)
+	if !ds.HasErrors() {
+		color.Set(color.FgGreen, color.Bold)
+		fmt.Println("Configuration is valid.")
+	} else {
+		color.Set(color.FgRed, color.Bold)
+		fmt.Println("Configuration is invalid.")
+	}
+	color.Unset()
 }
 
-func verifyUsers(section *parser.Section) string {
-	userSections := getAllSections(section, "users/user")
-	for _, userSection := range userSections {
-		username := userSection.GetFirst("username", "")
-		if username == "" {
-			return "User section missing required 'username' field"
-		}
+// Verify checks the configuration against the schema and returns every problem found.
+func Verify(section *parser.Section) Diagnostics {
+	ds := Diagnostics{}
 
-		createHome := userSection.GetFirst("create_home", "true")
-		if _, err := strconv.ParseBool(createHome); err != nil {
-			return fmt.Sprintf("User '%s': invalid value for 'create_home': %s", username, createHome)
-		}
-	}
-
-	hookSections := getAllSections(section, "users/hook")
-	for _, hookSection := range hookSections {
-		username := hookSection.GetFirst("user", "")
-		if username == "" {
-			return "User hook section missing required 'user' field"
-		}
+	verifySchema(section, schema.Root, "", map[string]int{}, &ds)
 
-		if v := verifyHook(hookSection, "create", "delete", fmt.Sprintf("for user '%s'", username)); v != "" {
-			return v
-		}
+	for _, provider := range modules.Providers() {
+		verifyPackageHooks(section, provider, &ds)
 	}
+	verifyTagPresets(section, &ds)
 
-	return ""
+	ds.Sort()
+	return ds
 }
 
-func verifyPacman(section *parser.Section) string {
-	color := section.GetFirst("packages/pacman/color", "true")
-	if _, err := strconv.ParseBool(color); err != nil {
-		return fmt.Sprintf("Invalid value for packages/pacman/color: %s", color)
-	}
-
-	verbosePkgLists := section.GetFirst("packages/pacman/verbose_pkg_lists", "false")
-	if _, err := strconv.ParseBool(verbosePkgLists); err != nil {
-		return fmt.Sprintf("Invalid value for packages/pacman/verbose_pkg_lists: %s", verbosePkgLists)
-	}
+// verifySchema checks the values and sub-sections of a section against its schema,
+// reporting unknown keys, invalid values and missing required keys.
+// indexes counts the repeated sections seen so far by path, so that they are numbered across the whole configuration.
+func verifySchema(section *parser.Section, key *schema.Key, path string, indexes map[string]int, ds *Diagnostics) {
+	for _, name := range slices.Sorted(maps.Keys(section.Values)) {
+		valuePath := joinPath(path, name)
+		values := section.Values[name]
+
+		child := key.Child(name)
+		if child == nil {
+			ds.Error(section.ValuePos(name, 0), valuePath, "unknown key '%s'%s", name, suggestion(name, key))
+			continue
+		} else if !child.IsValue() {
+			ds.Error(section.ValuePos(name, 0), valuePath, "'%s' is a section, write it as '%s { ... }'", name, name)
+			continue
+		} else if child.Argument != "" && name == child.Name {
+			ds.Error(section.ValuePos(name, 0), valuePath, "missing %s, write it as '%s <%s> = value'", child.Argument, name, child.Argument)
+			continue
+		}
 
-	iLoveCandy := section.GetFirst("packages/pacman/i_love_candy", "false")
-	if _, err := strconv.ParseBool(iLoveCandy); err != nil {
-		return fmt.Sprintf("Invalid value for packages/pacman/i_love_candy: %s", iLoveCandy)
+		for i, value := range values {
+			if child.Repeated {
+				verifyValue(value, child, section.ValuePos(name, i), indexedPath(valuePath, i), ds)
+			} else {
+				verifyValue(value, child, section.ValuePos(name, i), valuePath, ds)
+			}
+		}
+		if !child.Repeated && len(values) > 1 {
+			ds.Warning(section.ValuePos(name, 1), valuePath, "set %d times, only the first value is used", len(values))
+		}
 	}
 
-	if parallelDownloads := section.GetFirst("packages/pacman/parallel_downloads", ""); parallelDownloads != "" {
-		if _, err := strconv.Atoi(parallelDownloads); err != nil {
-			return fmt.Sprintf("Invalid value for parallel_downloads: %s", parallelDownloads)
+	for _, removal := range section.Removals {
+		if child := key.Child(removal.Key); child == nil {
+			ds.Error(removal.Pos, joinPath(path, removal.Key), "unknown key '%s'%s", removal.Key, suggestion(removal.Key, key))
+		} else if !child.IsValue() {
+			ds.Error(removal.Pos, joinPath(path, removal.Key), "'%s' is a section, remove it with 'unset %s'", removal.Key, removal.Key)
 		}
 	}
-
-	for _, item := range section.GetAll("packages/pacman/package") {
-		if v := VerifyTags(item); v != "" {
-			return v
+	for _, unset := range section.Unsets {
+		if q, err := parser.ParseQuery(unset.Path); err == nil && key.Lookup(q.Path()) == nil {
+			ds.Error(unset.Pos, joinPath(path, q.Path()), "unknown key or section in 'unset %s'", unset.Path)
 		}
 	}
 
-	hookSections := getAllSections(section, "packages/pacman/hook")
-	for _, hookSection := range hookSections {
-		pkgName := hookSection.GetFirst("package", "")
-		if pkgName == "" {
-			return "Pacman hook section missing required 'package' field"
+	for _, name := range slices.Sorted(maps.Keys(section.Sections)) {
+		sectionPath := joinPath(path, name)
+		subSections := section.Sections[name]
+
+		child := key.Child(name)
+		if child == nil {
+			ds.Error(subSections[0].Pos, sectionPath, "unknown section '%s'%s", name, suggestion(name, key))
+			continue
+		} else if !child.IsSection() {
+			ds.Error(subSections[0].Pos, sectionPath, "'%s' is a key, write it as '%s = value'", name, name)
+			continue
 		}
 
-		if v := verifyHook(hookSection, "install", "remove", fmt.Sprintf("for Pacman package '%s'", pkgName)); v != "" {
-			return v
+		for _, subSection := range subSections {
+			if child.Repeated {
+				verifySchema(subSection, child, indexedPath(sectionPath, indexes[sectionPath]), indexes, ds)
+				indexes[sectionPath]++
+			} else {
+				verifySchema(subSection, child, sectionPath, indexes, ds)
+			}
 		}
 	}
 
-	return ""
-}
-
-func verifyAUR(section *parser.Section) string {
-	for _, item := range section.GetAll("packages/aur/package") {
-		if v := VerifyTags(item); v != "" {
-			return v
+	for _, child := range key.Keys {
+		if child.Required && len(section.Values[child.Name]) == 0 && len(section.Sections[child.Name]) == 0 {
+			ds.Error(section.Pos, path, "missing required '%s' field", child.Name)
 		}
 	}
+}
 
-	hookSections := getAllSections(section, "packages/aur/hook")
-	for _, hookSection := range hookSections {
-		pkgName := hookSection.GetFirst("package", "")
-		if pkgName == "" {
-			return "AUR hook section missing required 'package' field"
-		}
-
-		if v := verifyHook(hookSection, "install", "remove", fmt.Sprintf("for AUR package '%s'", pkgName)); v != "" {
-			return v
+// verifyTagPresets checks that the presets and host mappings of the `tags` section only refer to valid tags and existing presets.
+func verifyTagPresets(section *parser.Section, ds *Diagnostics) {
+	for _, tagsSection := range section.Sections["tags"] {
+		presets := modules.NewTagPresets(tagsSection)
+		for _, name := range slices.Sorted(maps.Keys(tagsSection.Values)) {
+			if !strings.HasPrefix(name, "preset ") && !strings.HasPrefix(name, "host ") {
+				continue
+			}
+			if _, err := presets.Expand(strings.Fields(tagsSection.GetFirst(name, ""))); err != nil {
+				ds.Error(tagsSection.ValuePos(name, 0), "tags/"+name, "%s", err)
+			}
 		}
 	}
-
-	return ""
 }
 
-func verifyFlatpak(section *parser.Section) string {
-	remoteSections := getAllSections(section, "packages/flatpak/remote")
-	for _, remote := range remoteSections {
-		name := remote.GetFirst("name", "")
-		if name == "" {
-			return "Flatpak remote section missing required 'name' field"
-		}
-
-		url := remote.GetFirst("url", "")
-		if url == "" {
-			return fmt.Sprintf("Flatpak remote '%s' missing required 'url' field", name)
-		}
-
-		userInstallation := remote.GetFirst("user_installation", "false")
-		if _, err := strconv.ParseBool(userInstallation); err != nil {
-			return fmt.Sprintf("Flatpak remote '%s': invalid value for 'user_installation': %s", name, userInstallation)
-		}
-
-		disable := remote.GetFirst("disable", "false")
-		if _, err := strconv.ParseBool(disable); err != nil {
-			return fmt.Sprintf("Flatpak remote '%s': invalid value for 'disable': %s", name, disable)
+// verifyValue checks a value against the type and allowed values of its key.
+func verifyValue(value string, key *schema.Key, pos parser.Position, path string, ds *Diagnostics) {
+	if key.Tagged {
+		if v := VerifyTags(value); v != "" {
+			ds.Error(pos, path, "%s", v)
 		}
+		value = strings.TrimSpace(strings.SplitN(value, ",", 2)[0])
 	}
 
-	for _, item := range section.GetAll("packages/flatpak/package") {
-		if v := VerifyTags(item); v != "" {
-			return v
+	if value == "" {
+		if key.Required {
+			ds.Error(pos, path, "value must not be empty")
 		}
+		return
 	}
 
-	packageSections := getAllSections(section, "packages/flatpak/package")
-	for _, pkg := range packageSections {
-		name := pkg.GetFirst("name", "")
-		if name == "" {
-			return "Flatpak package section missing required 'name' field"
+	switch key.Type {
+	case schema.Bool:
+		if _, err := parser.ParseBool(value); err != nil {
+			ds.Error(pos, path, "%s", err)
 		}
-
-		userInstallation := pkg.GetFirst("user_installation", "false")
-		if _, err := strconv.ParseBool(userInstallation); err != nil {
-			return fmt.Sprintf("Flatpak package '%s': invalid value for 'user_installation': %s", name, userInstallation)
+	case schema.Int:
+		if _, err := parser.ParseInt(value); err != nil {
+			ds.Error(pos, path, "%s", err)
 		}
-	}
-
-	hookSections := getAllSections(section, "packages/flatpak/hook")
-	for _, hookSection := range hookSections {
-		pkgName := hookSection.GetFirst("package", "")
-		if pkgName == "" {
-			return "Flatpak hook section missing required 'package' field"
-		}
-
-		if v := verifyHook(hookSection, "install", "remove", fmt.Sprintf("for Flatpak package '%s'", pkgName)); v != "" {
-			return v
+	case schema.Tags:
+		if _, err := modules.ParseTags(value); err != nil {
+			ds.Error(pos, path, "%s", err)
 		}
 	}
 
-	return ""
-}
-
-func verifyHook(section *parser.Section, additionTerm, removalTerm string, context string) string {
-	forValue := section.GetFirst("for", additionTerm)
-	if forValue != additionTerm && forValue != removalTerm {
-		return fmt.Sprintf("Invalid hook %s: 'for' value must be '%s' or '%s', got '%s'",
-			context, additionTerm, removalTerm, forValue)
+	if len(key.Allowed) > 0 && !slices.Contains(key.Allowed, value) {
+		ds.Error(pos, path, "value '%s' is not allowed, expected one of: %s", value, strings.Join(key.Allowed, ", "))
 	}
+}
 
-	when := section.GetFirst("when", "after")
-	if when != "before" && when != "after" {
-		return fmt.Sprintf("Invalid hook %s: 'when' value must be 'before' or 'after', got '%s'",
-			context, when)
+// suggestion returns a "did you mean" hint for a name that is not a sub-key of the key.
+func suggestion(name string, key *schema.Key) string {
+	names := make([]string, len(key.Keys))
+	for i, child := range key.Keys {
+		names[i] = child.Name
 	}
 
-	run := section.GetFirst("run", "")
-	if run == "" {
-		return fmt.Sprintf("Invalid hook %s: 'run' field is required", context)
+	if suggested := schema.Suggest(name, names); suggested != "" {
+		return fmt.Sprintf(", did you mean '%s'?", suggested)
 	}
-
 	return ""
 }
 
-func VerifyTags(packageEntry string) string {
-	parts := strings.SplitN(packageEntry, ",", 2)
-	if len(parts) <= 1 {
-		return ""
+// verifyPackageHooks warns about hooks of a package section that refer to packages that are not declared.
+func verifyPackageHooks(section *parser.Section, provider modules.RegisteredProvider, ds *Diagnostics) {
+	sectionPath := "packages/" + provider.Name()
+
+	// Packages hooks can refer to, regardless of tags
+	declaredPaths := []string{sectionPath + "/package", sectionPath + "/package/name", sectionPath + "/protected"}
+	declared := slices.Clone(builtinProtectedPackages)
+	if provider.Name() == "pacman" {
+		declaredPaths = append(declaredPaths, "essentials/kernel")
+		declared = append(declared, section.GetList("essentials/bootloader", schema.Default("essentials/bootloader"))...)
+		declared = append(declared, section.GetList("essentials/network_handler", schema.Default("essentials/network_handler"))...)
 	}
-
-	tagPart := strings.TrimSpace(parts[1])
-	for _, tag := range strings.Fields(tagPart) {
-		if v := VerifyTag(tag); v != "" {
-			return v
+	for _, path := range declaredPaths {
+		for _, value := range section.GetAll(path) {
+			declared = append(declared, strings.Fields(strings.SplitN(value, ",", 2)[0])...)
 		}
 	}
-	return ""
-}
 
-func VerifyTag(tag string) string {
-	if !strings.HasPrefix(tag, "+") {
-		return fmt.Sprintf("Tag '%s' must start with '+' character", tag)
+	for i, hookSection := range getAllSections(section, sectionPath+"/hook") {
+		pkgName, pos := hookSection.GetFirstPos("package", "")
+		if pkgName == "" {
+			continue
+		}
+		if _, name, _ := strings.Cut(pkgName, ":"); !slices.Contains(declared, pkgName) && !slices.Contains(declared, name) {
+			ds.Warning(pos, indexedPath(sectionPath+"/hook", i)+"/package", "package '%s' is not declared in %s, so the hook never runs", pkgName, sectionPath)
+		}
 	}
+}
 
-	tagName := strings.TrimPrefix(tag, "+")
-	tagName = strings.TrimPrefix(tagName, "!")
+// indexedPath returns the config path of the section at the given index of the sections at path.
+func indexedPath(path string, index int) string {
+	return fmt.Sprintf("%s[%d]", path, index)
+}
 
-	if len(tagName) == 0 {
-		return "Tag name cannot be empty"
+func joinPath(sectionPath, path string) string {
+	if sectionPath == "" {
+		return path
 	}
+	return sectionPath + "/" + path
+}
 
-	for _, char := range tagName {
-		if !unicode.IsLetter(char) && !unicode.IsNumber(char) && char != '_' && char != '-' {
-			return fmt.Sprintf("Tag name '%s' contains invalid character '%c'", tagName, char)
-		}
+// VerifyTags checks the tags of a value, e.g. `firefox, +desktop`, and returns a description of the problem or an empty string.
+func VerifyTags(packageEntry string) string {
+	_, tags, found := strings.Cut(packageEntry, ",")
+	if !found {
+		return ""
 	}
 
+	if _, err := modules.ParseTags(tags); err != nil {
+		return err.Error()
+	}
 	return ""
 }
 
 func init() {
-	verifyCmd.PersistentFlags().StringP("config", "c", "/etc/declarch/declarch.conf", "Configuration file")
+	verifyCmd.PersistentFlags().StringArrayP("config", "c", []string{"/etc/declarch/declarch.conf"}, "Configuration file, repeated to layer files over the first one")
+	verifyCmd.PersistentFlags().StringP("output", "o", "text", "Output format: text or json")
 
 	rootCmd.AddCommand(verifyCmd)
 }
\ No newline at end of file
@@ -0,0 +1,115 @@
+package cmds
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+func TestVerify(t *testing.T) {
+	section, err := parser.Parse("users {\n  user {\n    username = alice\n  }\n  user {\n    shell = /bin/zsh\n  }\n}\n" +
+		"packages {\n  pacman {\n    parallel_downloads = many\n    colour = true\n  }\n}\n" +
+		"essentials {\n  network_handler = iwd\n  network_handler = networkmanager\n}\n")
+	assert.NoError(t, err)
+
+	var messages []string
+	for _, d := range Verify(section) {
+		messages = append(messages, d.String())
+	}
+	assert.Equal(t, []string{
+		"5:3: error: users/user[1]: missing required 'username' field",
+		"11:5: error: packages/pacman/parallel_downloads: invalid value 'many', expected a number",
+		"12:5: error: packages/pacman/colour: unknown key 'colour', did you mean 'color'?",
+		"17:3: warning: essentials/network_handler: set 2 times, only the first value is used",
+	}, messages)
+}
+
+func TestDiagnostics_Sort(t *testing.T) {
+	ds := Diagnostics{}
+	ds.Error(parser.Position{}, "", "no position")
+	ds.Error(parser.Position{File: "b.conf", Line: 1, Column: 1}, "", "b")
+	ds.Warning(parser.Position{File: "a.conf", Line: 2, Column: 1}, "", "a:2")
+	ds.Error(parser.Position{File: "a.conf", Line: 1, Column: 7}, "", "a:1:7")
+	ds.Error(parser.Position{File: "a.conf", Line: 1, Column: 3}, "", "a:1:3")
+	ds.Sort()
+
+	var messages []string
+	for _, d := range ds {
+		messages = append(messages, d.Message)
+	}
+	assert.Equal(t, []string{"a:1:3", "a:1:7", "a:2", "b", "no position"}, messages)
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	_, err := parser.Parse("packages {\n  pacman\n")
+	ds := parseDiagnostics(err, "declarch.conf")
+	assert.True(t, ds.HasErrors())
+	assert.Equal(t, SeverityError, ds[0].Severity)
+	assert.Empty(t, ds[0].Path)
+
+	_, err = parser.ParseFile(filepath.Join(t.TempDir(), "missing.conf"))
+	ds = parseDiagnostics(err, "declarch.conf")
+	assert.Len(t, ds, 1)
+	assert.Equal(t, parser.Position{File: "declarch.conf"}, ds[0].Pos)
+}
+
+// captureVerify runs verify on a configuration and returns its output and exit code.
+func captureVerify(t *testing.T, config string, output string) (string, int) {
+	path := filepath.Join(t.TempDir(), "declarch.conf")
+	os.WriteFile(path, []byte(config), 0o644)
+
+	reader, writer, _ := os.Pipe()
+	stdout := os.Stdout
+	os.Stdout = writer
+	t.Cleanup(func() { os.Stdout = stdout })
+
+	exitCode = 0
+	runVerify([]string{path}, output)
+	writer.Close()
+	os.Stdout = stdout
+
+	out, _ := io.ReadAll(reader)
+	return string(out), exitCode
+}
+
+func TestRunVerify_ExitCode(t *testing.T) {
+	_, code := captureVerify(t, "essentials {\n  network_handler = iwd\n  network_handler = networkmanager\n}\n", "text")
+	assert.Equal(t, 0, code, "warnings")
+
+	_, code = captureVerify(t, "packages {\n  pacman {\n    color = maybe\n  }\n}\n", "text")
+	assert.Equal(t, 1, code, "errors")
+
+	_, code = captureVerify(t, "packages {\n", "text")
+	assert.Equal(t, 1, code, "parse errors")
+
+	_, code = captureVerify(t, "", "xml")
+	assert.Equal(t, 1, code, "invalid output")
+}
+
+func TestRunVerify_JSON(t *testing.T) {
+	out, code := captureVerify(t, "packages {\n  pacman {\n    color = maybe\n  }\n}\n", "json")
+	assert.Equal(t, 1, code)
+
+	var result map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, false, result["valid"])
+	assert.Equal(t, "declarch.conf", filepath.Base(result["config"].(string)))
+	assert.Equal(t, []any{map[string]any{
+		"severity": "error",
+		"path":     "packages/pacman/color",
+		"message":  "invalid value 'maybe', expected true or false",
+		"position": map[string]any{"file": result["config"], "line": 3.0, "column": 5.0},
+	}}, result["diagnostics"])
+
+	out, code = captureVerify(t, "", "json")
+	assert.Equal(t, 0, code)
+	assert.NoError(t, json.Unmarshal([]byte(out), &result))
+	assert.Equal(t, true, result["valid"])
+	assert.Equal(t, []any{}, result["diagnostics"])
+}
\ No newline at end of file
@@ -0,0 +1,241 @@
+package lsp
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
+)
+
+// locate returns the sections containing a one-based line, from the root to the innermost one,
+// and the node on that line if there is one. The header line of a section belongs to its parent.
+func locate(root *parser.Node, line int) ([]*parser.Node, *parser.Node) {
+	stack := []*parser.Node{root}
+	current := root
+	for {
+		var next *parser.Node
+		for _, child := range current.Children {
+			if child.Pos.Line == line {
+				return stack, child
+			}
+			if (child.Type == parser.NodeSection || child.Type == parser.NodeCondition) && child.Pos.Line < line && (!child.End.IsValid() || line < child.End.Line) {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return stack, nil
+		}
+		stack = append(stack, next)
+		current = next
+	}
+}
+
+// schemaPath returns the configuration path of the innermost section of a stack returned by locate.
+// Conditional blocks are not part of the path, since their lines belong to the enclosing section.
+func schemaPath(stack []*parser.Node) string {
+	names := []string{}
+	for _, section := range stack[1:] {
+		if section.Type == parser.NodeSection {
+			names = append(names, section.Key)
+		}
+	}
+	return strings.Join(names, "/")
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "/" + key
+}
+
+// lookupKey returns the schema key at a configuration path, or the root for an empty path.
+func lookupKey(path string) *schema.Key {
+	if path == "" {
+		return schema.Root
+	}
+	return schema.Lookup(path)
+}
+
+// variableAt returns the name of the `$variable` at a character of a line, if there is one.
+func variableAt(line string, character int) string {
+	start := strings.LastIndex(line[:min(character, len(line))], "$")
+	if start == -1 {
+		return ""
+	}
+
+	end := start + 1
+	for end < len(line) && isIdentifierChar(line[end]) {
+		end++
+	}
+	if end == start+1 || character > end {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func isIdentifierChar(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c == '_'
+}
+
+// findVariable returns the definition of a variable visible in the innermost section of a stack.
+// Like the parser, a variable defined in a section is visible in the whole section and its sub-sections.
+func findVariable(stack []*parser.Node, name string) *parser.Node {
+	for i := len(stack) - 1; i >= 0; i-- {
+		for _, child := range stack[i].Children {
+			if child.Type == parser.NodeVariable && child.Key == name {
+				return child
+			}
+		}
+	}
+	return nil
+}
+
+// completion returns the keys, values or variables that can be written at a position.
+func completion(text, path string, pos Position) []CompletionItem {
+	lines := strings.Split(text, "\n")
+	if pos.Line >= len(lines) {
+		return []CompletionItem{}
+	}
+	before := lines[pos.Line][:min(pos.Character, len(lines[pos.Line]))]
+
+	doc, _ := parser.ParseDocument(text, path)
+	stack, _ := locate(doc.Root, pos.Line+1)
+	sectionPath := schemaPath(stack)
+
+	items := []CompletionItem{}
+	if dollar := strings.LastIndex(before, "$"); dollar != -1 && !strings.ContainsFunc(before[dollar+1:], func(r rune) bool { return r > 127 || !isIdentifierChar(byte(r)) }) {
+		seen := []string{}
+		for i := len(stack) - 1; i >= 0; i-- {
+			for _, child := range stack[i].Children {
+				if child.Type == parser.NodeVariable && !slices.Contains(seen, child.Key) {
+					seen = append(seen, child.Key)
+					items = append(items, CompletionItem{Label: "$" + child.Key, Kind: kindVariable, Detail: child.Value, InsertText: child.Key})
+				}
+			}
+		}
+		for _, name := range parser.FactNames {
+			items = append(items, CompletionItem{Label: "$" + name, Kind: kindVariable, Detail: "built-in: " + parser.Facts()[name], InsertText: name})
+		}
+		return items
+	}
+
+	if keyText, _, found := strings.Cut(before, "="); found {
+		key := lookupKey(joinPath(sectionPath, strings.TrimSpace(keyText)))
+		if key == nil {
+			return items
+		}
+
+		values := key.Allowed
+		if len(values) == 0 && key.Type == schema.Bool {
+			values = []string{"true", "false"}
+		}
+		for _, value := range values {
+			items = append(items, CompletionItem{Label: value, Kind: kindValue})
+		}
+		return items
+	}
+
+	key := lookupKey(sectionPath)
+	if key == nil {
+		return items
+	}
+	for _, child := range key.Keys {
+		item := CompletionItem{
+			Label:         child.Name,
+			Kind:          kindField,
+			Detail:        string(child.Type),
+			Documentation: &MarkupContent{Kind: "markdown", Value: child.Markdown(joinPath(sectionPath, child.Name))},
+		}
+		if !child.IsValue() {
+			item.Kind = kindModule
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// hover returns the documentation of the key or section on a line, or the value of the variable under the cursor.
+func hover(text, path string, pos Position) *Hover {
+	lines := strings.Split(text, "\n")
+	if pos.Line >= len(lines) {
+		return nil
+	}
+
+	doc, _ := parser.ParseDocument(text, path)
+	stack, node := locate(doc.Root, pos.Line+1)
+
+	if name := variableAt(lines[pos.Line], pos.Character); name != "" {
+		if node != nil && node.Type == parser.NodeSection {
+			stack = append(stack, node)
+		}
+		if definition := findVariable(stack, name); definition != nil {
+			return &Hover{Contents: MarkupContent{Kind: "markdown", Value: "`$" + name + " = " + definition.Value + "`"}}
+		}
+		if value, ok := parser.Facts()[name]; ok {
+			return &Hover{Contents: MarkupContent{Kind: "markdown", Value: "`$" + name + " = " + value + "` (built-in)"}}
+		}
+		return nil
+	}
+
+	if node == nil || (node.Type != parser.NodeValue && node.Type != parser.NodeSection) {
+		return nil
+	}
+
+	keyPath := joinPath(schemaPath(stack), node.Key)
+	key := schema.Lookup(keyPath)
+	if key == nil {
+		return nil
+	}
+
+	r := lineRange(node.Pos, lines)
+	r.End.Character = r.Start.Character + len(node.Key)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "**" + keyPath + "**\n\n" + key.Markdown(keyPath)},
+		Range:    &r,
+	}
+}
+
+// definition returns the files sourced on a line, or the definition of the variable under the cursor.
+func definition(text, path, uri string, pos Position) []Location {
+	lines := strings.Split(text, "\n")
+	if pos.Line >= len(lines) {
+		return []Location{}
+	}
+
+	doc, _ := parser.ParseDocument(text, path)
+	stack, node := locate(doc.Root, pos.Line+1)
+
+	if name := variableAt(lines[pos.Line], pos.Character); name != "" {
+		if node != nil && node.Type == parser.NodeSection {
+			stack = append(stack, node)
+		}
+		if definition := findVariable(stack, name); definition != nil {
+			return []Location{{URI: uri, Range: lineRange(definition.Pos, lines)}}
+		}
+		return []Location{}
+	}
+
+	if node == nil || node.Type != parser.NodeSource {
+		return []Location{}
+	}
+
+	// Sourced paths are resolved like the parser does, relative to the including file
+	sourcePath := node.Value
+	if path != "" && !filepath.IsAbs(sourcePath) {
+		sourcePath = filepath.Join(filepath.Dir(path), sourcePath)
+	}
+	paths := []string{sourcePath}
+	if strings.ContainsAny(sourcePath, "*?[") {
+		paths, _ = filepath.Glob(sourcePath)
+	}
+
+	locations := []Location{}
+	for _, sourced := range paths {
+		locations = append(locations, Location{URI: pathToURI(sourced)})
+	}
+	return locations
+}
\ No newline at end of file
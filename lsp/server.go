@@ -0,0 +1,176 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
+)
+
+// Problem is a problem found in a configuration by Server.Verify.
+type Problem struct {
+	Pos      parser.Position
+	Severity int
+	Message  string
+}
+
+// Server is a language server for DeclArch configuration files.
+type Server struct {
+	// Verify checks a configuration that was parsed without errors, so that editors report the same problems as apply.
+	Verify func(section *parser.Section) []Problem
+
+	documents map[string]string
+	writer    io.Writer
+}
+
+func NewServer(verify func(section *parser.Section) []Problem) *Server {
+	return &Server{
+		Verify:    verify,
+		documents: make(map[string]string),
+	}
+}
+
+// Run serves requests read from in, writing responses and notifications to out,
+// until the client sends `exit` or closes the input.
+func (s *Server) Run(in io.Reader, out io.Writer) error {
+	s.writer = out
+	reader := bufio.NewReader(in)
+
+	for {
+		msg, err := readMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil && msg == nil {
+			return err
+		} else if err != nil {
+			s.respond(nil, nil, &responseError{Code: codeParseError, Message: err.Error()})
+			continue
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		result, respErr := s.handle(msg)
+		if msg.ID != nil {
+			if err := s.respond(msg.ID, result, respErr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) respond(id *json.RawMessage, result any, respErr *responseError) error {
+	response := map[string]any{"id": id}
+	if respErr != nil {
+		response["error"] = respErr
+	} else {
+		response["result"] = result
+	}
+	return writeMessage(s.writer, response)
+}
+
+func (s *Server) notify(method string, params any) error {
+	return writeMessage(s.writer, map[string]any{"method": method, "params": params})
+}
+
+func (s *Server) handle(msg *message) (any, *responseError) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":   1, // Full
+				"completionProvider": map[string]any{"triggerCharacters": []string{"$", "="}},
+				"hoverProvider":      true,
+				"definitionProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "declarch"},
+		}, nil
+	case "initialized", "shutdown":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.documents[params.TextDocument.URI] = params.TextDocument.Text
+		s.publishDiagnostics(params.TextDocument.URI)
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if len(params.ContentChanges) > 0 {
+			s.documents[params.TextDocument.URI] = params.ContentChanges[len(params.ContentChanges)-1].Text
+		}
+		s.publishDiagnostics(params.TextDocument.URI)
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.documents, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+		return nil, nil
+	case "textDocument/completion", "textDocument/hover", "textDocument/definition":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		text, path := s.documents[params.TextDocument.URI], uriToPath(params.TextDocument.URI)
+
+		switch msg.Method {
+		case "textDocument/completion":
+			return completion(text, path, params.Position), nil
+		case "textDocument/hover":
+			return hover(text, path, params.Position), nil
+		default:
+			return definition(text, path, params.TextDocument.URI, params.Position), nil
+		}
+	}
+
+	if msg.ID != nil {
+		return nil, &responseError{Code: codeMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+	return nil, nil
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// publishDiagnostics sends the problems found in a document by the parser and Verify.
+// Problems in sourced files are not reported, since they have to be fixed in those files.
+func (s *Server) publishDiagnostics(uri string) {
+	text, path := s.documents[uri], uriToPath(uri)
+	lines := strings.Split(text, "\n")
+
+	diagnostics := []Diagnostic{}
+	add := func(pos parser.Position, severity int, message string) {
+		if pos.File != path && pos.File != "" {
+			return
+		}
+		diagnostics = append(diagnostics, Diagnostic{Range: lineRange(pos, lines), Severity: severity, Source: "declarch", Message: message})
+	}
+
+	// Overlays are layered like the commands layer them, so that overridden keys aren't reported as set several times
+	section, err := parser.ParseContentWith(text, path, parser.ParseOptions{Repeated: schema.Repeated})
+	var errs parser.ErrorList
+	if errors.As(err, &errs) {
+		for _, e := range errs {
+			add(e.Pos, SeverityError, e.Message)
+		}
+	} else if err == nil && s.Verify != nil {
+		for _, problem := range s.Verify(section) {
+			add(problem.Pos, problem.Severity, problem.Message)
+		}
+	}
+
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
\ No newline at end of file
@@ -0,0 +1,211 @@
+package lsp_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DevReaper0/declarch/lsp"
+	"github.com/DevReaper0/declarch/parser"
+)
+
+type response struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Params json.RawMessage `json:"params"`
+	Error  *struct {
+		Code int `json:"code"`
+	} `json:"error"`
+}
+
+// run sends requests to a server and returns its responses and notifications.
+// Requests without an ID are sent as notifications.
+func run(t *testing.T, verify func(*parser.Section) []lsp.Problem, requests ...map[string]any) []response {
+	var in bytes.Buffer
+	for _, request := range requests {
+		request["jsonrpc"] = "2.0"
+		body, err := json.Marshal(request)
+		require.NoError(t, err)
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+
+	var out bytes.Buffer
+	require.NoError(t, lsp.NewServer(verify).Run(&in, &out))
+
+	responses := []response{}
+	reader := bufio.NewReader(&out)
+	for reader.Buffered() > 0 || out.Len() > 0 {
+		header, err := textproto.NewReader(reader).ReadMIMEHeader()
+		require.NoError(t, err)
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		require.NoError(t, err)
+
+		body := make([]byte, length)
+		_, err = io.ReadFull(reader, body)
+		require.NoError(t, err)
+
+		var r response
+		require.NoError(t, json.Unmarshal(body, &r))
+		responses = append(responses, r)
+	}
+	return responses
+}
+
+func open(uri, text string) map[string]any {
+	return map[string]any{
+		"method": "textDocument/didOpen",
+		"params": map[string]any{"textDocument": map[string]any{"uri": uri, "languageId": "declarch", "version": 1, "text": text}},
+	}
+}
+
+func at(id int, method, uri string, line, character int) map[string]any {
+	return map[string]any{
+		"id":     id,
+		"method": method,
+		"params": map[string]any{"textDocument": map[string]any{"uri": uri}, "position": map[string]any{"line": line, "character": character}},
+	}
+}
+
+func TestServer_Initialize(t *testing.T) {
+	responses := run(t, nil,
+		map[string]any{"id": 1, "method": "initialize", "params": map[string]any{}},
+		map[string]any{"id": 2, "method": "unknown/method", "params": map[string]any{}},
+		map[string]any{"method": "exit"},
+	)
+	require.Len(t, responses, 2)
+
+	var result struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	require.NoError(t, json.Unmarshal(responses[0].Result, &result))
+	assert.Equal(t, true, result.Capabilities["hoverProvider"])
+	assert.Equal(t, true, result.Capabilities["definitionProvider"])
+	assert.Contains(t, result.Capabilities, "completionProvider")
+
+	require.NotNil(t, responses[1].Error)
+	assert.Equal(t, -32601, responses[1].Error.Code)
+}
+
+func TestServer_Diagnostics(t *testing.T) {
+	verify := func(section *parser.Section) []lsp.Problem {
+		_, pos := section.GetFirstPos("essentials/kernel", "")
+		return []lsp.Problem{{Pos: pos, Severity: lsp.SeverityWarning, Message: "checked"}}
+	}
+
+	responses := run(t, verify,
+		open("file:///tmp/bad.conf", "essentials {\n  kernel = $missing\n}\n"),
+		open("file:///tmp/good.conf", "essentials {\n  kernel = linux\n}\n"),
+	)
+	require.Len(t, responses, 2)
+
+	var params struct {
+		URI         string           `json:"uri"`
+		Diagnostics []lsp.Diagnostic `json:"diagnostics"`
+	}
+	require.NoError(t, json.Unmarshal(responses[0].Params, &params))
+	assert.Equal(t, "textDocument/publishDiagnostics", responses[0].Method)
+	assert.Equal(t, "file:///tmp/bad.conf", params.URI)
+	require.Len(t, params.Diagnostics, 1)
+	assert.Equal(t, lsp.SeverityError, params.Diagnostics[0].Severity)
+	assert.Equal(t, 1, params.Diagnostics[0].Range.Start.Line)
+	assert.Contains(t, params.Diagnostics[0].Message, "missing")
+
+	// Verify only runs on documents without syntax errors
+	require.NoError(t, json.Unmarshal(responses[1].Params, &params))
+	require.Len(t, params.Diagnostics, 1)
+	assert.Equal(t, lsp.SeverityWarning, params.Diagnostics[0].Severity)
+	assert.Equal(t, "checked", params.Diagnostics[0].Message)
+	assert.Equal(t, lsp.Range{Start: lsp.Position{Line: 1, Character: 2}, End: lsp.Position{Line: 1, Character: 16}}, params.Diagnostics[0].Range)
+}
+
+func TestServer_Completion(t *testing.T) {
+	uri := "file:///tmp/test.conf"
+	text := "$user = ghost\n\nessentials {\n  \n  privilege_escalation = \n}\nusers {\n  $home = /home\n  user {\n    name = $\n  }\n}\n"
+
+	responses := run(t, nil,
+		open(uri, text),
+		at(1, "textDocument/completion", uri, 1, 0),
+		at(2, "textDocument/completion", uri, 3, 2),
+		at(3, "textDocument/completion", uri, 4, 25),
+		at(4, "textDocument/completion", uri, 9, 12),
+	)
+	require.Len(t, responses, 5)
+
+	labels := func(r response) []string {
+		var items []lsp.CompletionItem
+		require.NoError(t, json.Unmarshal(r.Result, &items))
+		labels := []string{}
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+	assert.Contains(t, labels(responses[1]), "essentials")
+	assert.Contains(t, labels(responses[1]), "packages")
+	assert.Contains(t, labels(responses[2]), "kernel")
+	assert.NotContains(t, labels(responses[2]), "essentials")
+	assert.Contains(t, labels(responses[3]), "sudo")
+	assert.Equal(t, []string{"$home", "$user", "$hostname", "$arch", "$cpu_vendor", "$kernel_release", "$machine_id"}, labels(responses[4]))
+}
+
+func TestServer_Hover(t *testing.T) {
+	uri := "file:///tmp/test.conf"
+	text := "$kernel = linux\nessentials {\n  kernel = $kernel\n  unknown = value\n}\n"
+
+	responses := run(t, nil,
+		open(uri, text),
+		at(1, "textDocument/hover", uri, 2, 3),
+		at(2, "textDocument/hover", uri, 2, 14),
+		at(3, "textDocument/hover", uri, 3, 3),
+	)
+	require.Len(t, responses, 4)
+
+	var hover lsp.Hover
+	require.NoError(t, json.Unmarshal(responses[1].Result, &hover))
+	assert.True(t, strings.HasPrefix(hover.Contents.Value, "**essentials/kernel**"))
+	assert.Equal(t, &lsp.Range{Start: lsp.Position{Line: 2, Character: 2}, End: lsp.Position{Line: 2, Character: 8}}, hover.Range)
+
+	require.NoError(t, json.Unmarshal(responses[2].Result, &hover))
+	assert.Contains(t, hover.Contents.Value, "$kernel = linux")
+
+	assert.Equal(t, "null", string(responses[3].Result))
+}
+
+func TestServer_Definition(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.conf"), []byte(""), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.conf"), []byte(""), 0644))
+
+	uri := "file://" + filepath.ToSlash(filepath.Join(dir, "main.conf"))
+	text := "$kernel = linux\nsource = *.conf\nessentials {\n  kernel = $kernel\n}\n"
+
+	responses := run(t, nil,
+		open(uri, text),
+		at(1, "textDocument/definition", uri, 3, 13),
+		at(2, "textDocument/definition", uri, 1, 3),
+	)
+	require.Len(t, responses, 3)
+
+	var locations []lsp.Location
+	require.NoError(t, json.Unmarshal(responses[1].Result, &locations))
+	require.Len(t, locations, 1)
+	assert.Equal(t, uri, locations[0].URI)
+	assert.Equal(t, 0, locations[0].Range.Start.Line)
+
+	require.NoError(t, json.Unmarshal(responses[2].Result, &locations))
+	require.Len(t, locations, 2)
+	assert.Equal(t, "file://"+filepath.ToSlash(filepath.Join(dir, "a.conf")), locations[0].URI)
+	assert.Equal(t, "file://"+filepath.ToSlash(filepath.Join(dir, "b.conf")), locations[1].URI)
+}
\ No newline at end of file
@@ -7,12 +7,7 @@ import (
 	"github.com/DevReaper0/declarch/utils"
 )
 
-func AURInstall(helper string, pkgs interface{}) error {
-	pkgNames, ok := pkgs.([]string)
-	if !ok {
-		return fmt.Errorf("expected []string for package names, got %T", pkgs)
-	}
-
+func AURInstall(helper string, pkgNames []string) error {
 	if helper == "makepkg" {
 		for _, pkgName := range pkgNames {
 			if err := MakepkgInstall(pkgName); err != nil {
@@ -50,4 +45,56 @@ func PacmanWrapperSystemUpgrade(wrapper string) error {
 	}, "", user)
 }
 
-var rootPacmanWrappers = []string{}
\ No newline at end of file
+var rootPacmanWrappers = []string{}
+
+// AURProvider installs packages from the AUR, either with makepkg or with an AUR helper.
+type AURProvider struct {
+	// Helper is the AUR helper to use (e.g. "yay" or "paru"), or "makepkg" to build packages directly.
+	Helper string
+}
+
+func (AURProvider) Name() string {
+	return "aur"
+}
+
+func (p AURProvider) Description() string {
+	if p.CanUpgrade() {
+		return "AUR packages via " + p.Helper
+	}
+	return "AUR packages"
+}
+
+func (AURProvider) ID(pkgName string) string {
+	return pkgName
+}
+
+// Query returns all installed packages that are not in the sync repositories.
+func (AURProvider) Query() ([]string, error) {
+	return PacmanQueryForeign()
+}
+
+func (p AURProvider) Install(pkgNames []string) error {
+	return AURInstall(p.Helper, pkgNames)
+}
+
+func (AURProvider) Remove(pkgNames []string) error {
+	return PacmanRemove(pkgNames)
+}
+
+// Upgrade upgrades all packages with the AUR helper.
+func (p AURProvider) Upgrade() error {
+	if !p.CanUpgrade() {
+		return fmt.Errorf("upgrading AUR packages requires an AUR helper")
+	}
+	return PacmanWrapperSystemUpgrade(p.Helper)
+}
+
+// CanUpgrade reports whether an AUR helper is configured.
+// Packages built with makepkg can't be upgraded, since makepkg doesn't track where they came from.
+func (p AURProvider) CanUpgrade() bool {
+	return p.Helper != "" && p.Helper != "makepkg"
+}
+
+func (AURProvider) IsInstalled(pkgName string) (bool, error) {
+	return PacmanIsInstalled(pkgName)
+}
\ No newline at end of file
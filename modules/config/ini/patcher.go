@@ -14,24 +14,31 @@ type Patcher struct {
 // Patch performs in-memory modifications by parsing the file into nodes,
 // updating those nodes recursively, then generating the updated file content.
 func (p *Patcher) Patch(parser *Parser, filePath string, modifications map[string]interface{}) error {
-	root, err := parser.Parse(filePath)
+	content, err := p.Preview(parser, filePath, modifications)
 	if err != nil {
 		return err
 	}
 
-	p.applyModifications(parser, root, modifications)
+	return os.WriteFile(filePath, content, 0o644)
+}
 
-	content, err := parser.Generate(root)
+// Preview returns the content Patch would write to the file, without writing it.
+func (p *Patcher) Preview(parser *Parser, filePath string, modifications map[string]interface{}) ([]byte, error) {
+	root, err := parser.Parse(filePath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return os.WriteFile(filePath, content, 0o644)
+	p.applyModifications(parser, root, modifications)
+
+	return parser.Generate(root)
 }
 
 // applyModifications applies updates to the given node based on modifications.
 // If the modification value is a string, it updates/removes a key in the current node.
 // If the modification value is a map, it recurses into the corresponding section.
+// The "~ORDER" key can list section names, which are then kept in that order relative to each other
+// (e.g. pacman repositories, whose order sets their priority).
 func (p *Patcher) applyModifications(parser *Parser, node *Node, mods map[string]interface{}) {
 	// First pass: process modifications for keys already present.
 	for key, mod := range mods {
@@ -73,13 +80,58 @@ func (p *Patcher) applyModifications(parser *Parser, node *Node, mods map[string
 			p.insertKeyBeforeBlankLines(node, key, val)
 		}
 	}
-	// Then, process section modifications (map values)
+	// Then, process section modifications (map values),
+	// ordered sections first, followed by the others in lexicographical order.
+	order, _ := mods["~ORDER"].([]string)
+	var otherSections []string
 	for key, mod := range mods {
-		if secMods, ok := mod.(map[string]interface{}); ok { // section update
-			secNode := p.findOrCreateSectionNode(node, key, parser.options)
-			p.applyModifications(parser, secNode, secMods)
+		if _, ok := mod.(map[string]interface{}); ok && !slices.Contains(order, key) {
+			otherSections = append(otherSections, key)
+		}
+	}
+	sort.Strings(otherSections)
+
+	var previous *Node
+	for _, key := range append(slices.Clone(order), otherSections...) {
+		secMods, ok := mods[key].(map[string]interface{}) // section update
+		if !ok {
+			continue
+		}
+		secNode := p.findOrCreateSectionNode(node, key, parser.options)
+		if slices.Contains(order, key) {
+			if previous != nil {
+				p.moveSectionAfter(node, secNode, previous)
+			}
+			previous = secNode
 		}
+		p.applyModifications(parser, secNode, secMods)
+	}
+}
+
+// moveSectionAfter moves a section node right after another one if it currently comes before it.
+// The trailing blank lines of both sections are swapped, so that the spacing between sections is kept.
+func (p *Patcher) moveSectionAfter(root *Node, sectionNode *Node, after *Node) {
+	idx := slices.Index(root.Children, sectionNode)
+	afterIdx := slices.Index(root.Children, after)
+	if idx == -1 || afterIdx == -1 || idx > afterIdx {
+		return
+	}
+	root.Children = slices.Delete(root.Children, idx, idx+1)
+	root.Children = slices.Insert(root.Children, afterIdx, sectionNode)
+
+	sectionContent, sectionBlanks := splitTrailingBlanks(sectionNode.Children)
+	afterContent, afterBlanks := splitTrailingBlanks(after.Children)
+	sectionNode.Children = append(sectionContent, afterBlanks...)
+	after.Children = append(afterContent, sectionBlanks...)
+}
+
+// splitTrailingBlanks splits nodes into the content and the blank lines after it.
+func splitTrailingBlanks(nodes []*Node) ([]*Node, []*Node) {
+	idx := len(nodes)
+	for idx > 0 && nodes[idx-1].Type == NodeBlank {
+		idx--
 	}
+	return slices.Clip(nodes[:idx]), slices.Clone(nodes[idx:])
 }
 
 func (p *Patcher) modifyExistingKey(sectionNode *Node, key, value string) {
@@ -358,6 +358,57 @@ Key1 = NewValue1
 
 [zxc]
 Zxc = 789
+`
+
+	resultBytes, _ := os.ReadFile(testFile)
+	assert.Equal(t, strings.TrimSpace(expected), strings.TrimSpace(string(resultBytes)))
+}
+func TestINIPatcher_SectionOrder(t *testing.T) {
+	parser := ini.NewParser(ini.Options{AllowInlineComment: true})
+	patcher := &ini.Patcher{}
+	testFile := "test_section_order.conf"
+	defer os.Remove(testFile)
+
+	original := `
+[options]
+Color
+
+[extra]
+Include = /etc/pacman.d/mirrorlist
+
+[core]
+Include = /etc/pacman.d/mirrorlist
+`
+	os.WriteFile(testFile, []byte(original), 0o644)
+
+	modifications := map[string]interface{}{
+		"~ORDER": []string{"core", "extra", "custom"},
+		"custom": map[string]interface{}{
+			"Server": "https://example.com",
+		},
+		"extra": map[string]interface{}{
+			"Include": "/etc/pacman.d/mirrorlist",
+		},
+		"core": map[string]interface{}{
+			"Include": "/etc/pacman.d/mirrorlist",
+		},
+	}
+
+	err := patcher.Patch(parser, testFile, modifications)
+	assert.NoError(t, err)
+
+	expected := `
+[options]
+Color
+
+[core]
+Include = /etc/pacman.d/mirrorlist
+
+[extra]
+Include = /etc/pacman.d/mirrorlist
+
+[custom]
+Server = https://example.com
 `
 
 	resultBytes, _ := os.ReadFile(testFile)
@@ -0,0 +1,43 @@
+package modules_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
+)
+
+// The defaults of the struct tags must match the schema, which documents and verifies them.
+func TestFrom_SchemaDefaults(t *testing.T) {
+	section, err := parser.Parse("username = alice\nname = flathub\nurl = https://dl.flathub.org/repo/\n")
+	assert.NoError(t, err)
+
+	user, err := modules.UserFrom(section)
+	assert.NoError(t, err)
+	assert.Equal(t, schema.Default("users/user/create_home") == "true", user.CreateHome)
+
+	pkg, err := modules.FlatpakPackageFrom(section)
+	assert.NoError(t, err)
+	assert.Equal(t, schema.Default("packages/flatpak/package/user_installation") == "true", pkg.UserInstallation)
+
+	remote, err := modules.FlatpakRemoteFrom(section)
+	assert.NoError(t, err)
+	assert.Equal(t, schema.Default("packages/flatpak/remote/user_installation") == "true", remote.UserInstallation)
+	assert.Equal(t, schema.Default("packages/flatpak/remote/disable") == "true", remote.Disable)
+}
+
+func TestUserFrom(t *testing.T) {
+	section, err := parser.ParseContent("user {\n  create_home = sometimes\n}\nuser {\n  username = bob\n  group = wheel audio\n  group = video\n}\n", "declarch.conf")
+	assert.NoError(t, err)
+
+	users := section.Sections["user"]
+	_, err = modules.UserFrom(users[0])
+	assert.EqualError(t, err, "invalid user section: declarch.conf:1:1: missing required 'username' field")
+
+	user, err := modules.UserFrom(users[1])
+	assert.NoError(t, err)
+	assert.Equal(t, modules.User{Username: "bob", CreateHome: true, Groups: []string{"wheel", "audio", "video"}}, user)
+}
\ No newline at end of file
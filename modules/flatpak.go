@@ -2,7 +2,8 @@ package modules
 
 import (
 	"fmt"
-	"strconv"
+	"os/exec"
+	"slices"
 	"strings"
 
 	"github.com/DevReaper0/declarch/parser"
@@ -10,12 +11,12 @@ import (
 )
 
 type FlatpakPackage struct {
-	Name             string
-	Remote           string
-	UserInstallation bool
-	Installation     string
-	Architecture     string
-	Subpath          string
+	Name             string `declarch:"name,required"`
+	Remote           string `declarch:"remote"`
+	UserInstallation bool   `declarch:"user_installation,default=false"`
+	Installation     string `declarch:"installation"`
+	Architecture     string `declarch:"architecture"`
+	Subpath          string `declarch:"subpath"`
 }
 
 func FlatpakPackageFrom(input interface{}) (FlatpakPackage, error) {
@@ -23,26 +24,9 @@ func FlatpakPackageFrom(input interface{}) (FlatpakPackage, error) {
 
 	switch v := input.(type) {
 	case *parser.Section:
-		if name := v.GetFirst("name", ""); name != "" {
-			pkg.Name = name
-		} else {
-			return pkg, fmt.Errorf("Flatpak package section is missing 'name' field")
+		if err := v.Decode(&pkg); err != nil {
+			return pkg, fmt.Errorf("invalid Flatpak package section: %w", err)
 		}
-
-		pkg.Remote = v.GetFirst("remote", "")
-
-		{
-			userInstallationString := v.GetFirst("user_installation", "false")
-			userInstallation, err := strconv.ParseBool(userInstallationString)
-			if err != nil {
-				return pkg, fmt.Errorf("invalid value for 'user_installation' field in Flatpak package section '%s': %s", pkg.Name, userInstallationString)
-			}
-			pkg.UserInstallation = userInstallation
-		}
-		pkg.Installation = v.GetFirst("installation", "")
-
-		pkg.Architecture = v.GetFirst("architecture", "")
-		pkg.Subpath = v.GetFirst("subpath", "")
 	case string:
 		pkg.Name = v
 	default:
@@ -51,18 +35,42 @@ func FlatpakPackageFrom(input interface{}) (FlatpakPackage, error) {
 	return pkg, nil
 }
 
+// Identifier returns the identifier of the package, see FlatpakIdentifier.
+func (p FlatpakPackage) Identifier() string {
+	return FlatpakIdentifier(p.Name, p.Installation, p.UserInstallation)
+}
+
+// PackageName returns the application ID of the package, which hooks can refer to instead of the identifier.
+func (p FlatpakPackage) PackageName() string {
+	return p.Name
+}
+
+// FlatpakIdentifier creates a standardized identifier for Flatpak resources
+// Format: "[installation]:[name]" if specific system-wide installation was specified
+// Format: ":[name]" if specified to use per-user installation
+// Format: "default:[name]" if no installation was specified (default system-wide installation)
+func FlatpakIdentifier(name, installation string, userInstallation bool) string {
+	if installation != "" {
+		return installation + ":" + name
+	}
+	if userInstallation {
+		return ":" + name
+	}
+	return "default:" + name
+}
+
 type FlatpakRemote struct {
-	Name             string
-	URL              string
-	UserInstallation bool
-	Installation     string
-	Disable          bool
-	Title            string
-	Comment          string
-	Description      string
-	Homepage         string
-	Icon             string
-	DefaultBranch    string
+	Name             string `declarch:"name,required"`
+	URL              string `declarch:"url,required"`
+	UserInstallation bool   `declarch:"user_installation,default=false"`
+	Installation     string `declarch:"installation"`
+	Disable          bool   `declarch:"disable,default=false"`
+	Title            string `declarch:"title"`
+	Comment          string `declarch:"comment"`
+	Description      string `declarch:"description"`
+	Homepage         string `declarch:"homepage"`
+	Icon             string `declarch:"icon"`
+	DefaultBranch    string `declarch:"default_branch"`
 }
 
 func FlatpakRemoteFrom(input interface{}) (FlatpakRemote, error) {
@@ -70,43 +78,9 @@ func FlatpakRemoteFrom(input interface{}) (FlatpakRemote, error) {
 
 	switch v := input.(type) {
 	case *parser.Section:
-		if name := v.GetFirst("name", ""); name != "" {
-			remote.Name = name
-		} else {
-			return remote, fmt.Errorf("Flatpak remote section is missing 'name' field")
-		}
-
-		if url := v.GetFirst("url", ""); url != "" {
-			remote.URL = url
-		} else {
-			return remote, fmt.Errorf("Flatpak remote section '%s' is missing 'url' field", remote.Name)
+		if err := v.Decode(&remote); err != nil {
+			return remote, fmt.Errorf("invalid Flatpak remote section: %w", err)
 		}
-
-		{
-			userInstallationString := v.GetFirst("user_installation", "false")
-			userInstallation, err := strconv.ParseBool(userInstallationString)
-			if err != nil {
-				return remote, fmt.Errorf("invalid value for 'user_installation' field in Flatpak remote section '%s': %s", remote.Name, userInstallationString)
-			}
-			remote.UserInstallation = userInstallation
-		}
-		remote.Installation = v.GetFirst("installation", "")
-
-		{
-			disableString := v.GetFirst("disable", "false")
-			disable, err := strconv.ParseBool(disableString)
-			if err != nil {
-				return remote, fmt.Errorf("invalid value for 'disable' field in Flatpak remote section '%s': %s", remote.Name, disableString)
-			}
-			remote.Disable = disable
-		}
-
-		remote.Title = v.GetFirst("title", "")
-		remote.Comment = v.GetFirst("comment", "")
-		remote.Description = v.GetFirst("description", "")
-		remote.Homepage = v.GetFirst("homepage", "")
-		remote.Icon = v.GetFirst("icon", "")
-		remote.DefaultBranch = v.GetFirst("default_branch", "")
 	case string:
 		remote.Name = v
 	default:
@@ -115,12 +89,7 @@ func FlatpakRemoteFrom(input interface{}) (FlatpakRemote, error) {
 	return remote, nil
 }
 
-func FlatpakInstall(pkgs interface{}) error {
-	pkgObjs, ok := pkgs.([]FlatpakPackage)
-	if !ok {
-		return fmt.Errorf("expected []FlatpakPackage for package objects, got %T", pkgs)
-	}
-
+func FlatpakInstall(pkgObjs []FlatpakPackage) error {
 	for _, pkgObj := range pkgObjs {
 		args := []string{"flatpak", "install", "--noninteractive", "--assumeyes"}
 
@@ -155,12 +124,7 @@ func FlatpakInstall(pkgs interface{}) error {
 	return nil
 }
 
-func FlatpakRemove(pkgs interface{}) error {
-	pkgObjs, ok := pkgs.([]FlatpakPackage)
-	if !ok {
-		return fmt.Errorf("expected []FlatpakPackage for package objects, got %T", pkgs)
-	}
-
+func FlatpakRemove(pkgObjs []FlatpakPackage) error {
 	for _, pkgObj := range pkgObjs {
 		args := []string{"flatpak", "uninstall", "--noninteractive", "--assumeyes"}
 
@@ -297,4 +261,133 @@ func FlatpakModifyRemote(remote FlatpakRemote) error {
 	args = append(args, remote.Name)
 
 	return utils.ExecCommand(args, "", PrimaryUser)
+}
+
+// FlatpakQuery returns all installed Flatpak applications and runtimes.
+// If Flatpak itself is not installed, no packages are returned.
+func FlatpakQuery() ([]FlatpakPackage, error) {
+	return flatpakList(false)
+}
+
+// FlatpakQueryApps returns all installed Flatpak applications, without runtimes.
+// If Flatpak itself is not installed, no packages are returned.
+func FlatpakQueryApps() ([]FlatpakPackage, error) {
+	return flatpakList(true)
+}
+
+func flatpakList(appsOnly bool) ([]FlatpakPackage, error) {
+	if _, err := exec.LookPath("flatpak"); err != nil {
+		return []FlatpakPackage{}, nil
+	}
+
+	args := []string{"flatpak", "list", "--columns=application,installation"}
+	if appsOnly {
+		args = append(args, "--app")
+	}
+
+	output, err := utils.CommandOutput(args, PrimaryUser)
+	if err != nil {
+		return nil, err
+	}
+
+	pkgs := []FlatpakPackage{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+
+		pkg := FlatpakPackage{Name: fields[0]}
+		pkg.UserInstallation, pkg.Installation = parseFlatpakInstallation(fields[1])
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs, nil
+}
+
+// FlatpakQueryRemotes returns all configured Flatpak remotes.
+// Only the name, URL, installation and disabled state of the remotes are filled in.
+// If Flatpak itself is not installed, no remotes are returned.
+func FlatpakQueryRemotes() ([]FlatpakRemote, error) {
+	if _, err := exec.LookPath("flatpak"); err != nil {
+		return []FlatpakRemote{}, nil
+	}
+
+	output, err := utils.CommandOutput([]string{
+		"flatpak", "remotes", "--columns=name,options,url",
+	}, PrimaryUser)
+	if err != nil {
+		return nil, err
+	}
+
+	remotes := []FlatpakRemote{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+
+		options := strings.Split(fields[1], ",")
+		remote := FlatpakRemote{Name: fields[0]}
+		if len(fields) > 2 {
+			remote.URL = fields[2]
+		}
+		remote.UserInstallation, remote.Installation = parseFlatpakInstallation(options[0])
+		remote.Disable = slices.Contains(options, "disabled")
+		remotes = append(remotes, remote)
+	}
+	return remotes, nil
+}
+
+// parseFlatpakInstallation converts an installation as reported by Flatpak ("system", "user", or a custom name)
+// into the user installation flag and installation name used in the configuration.
+func parseFlatpakInstallation(installation string) (bool, string) {
+	switch installation {
+	case "system":
+		return false, ""
+	case "user":
+		return true, ""
+	default:
+		return false, installation
+	}
+}
+
+// FlatpakProvider installs Flatpak applications and runtimes.
+type FlatpakProvider struct{}
+
+func (FlatpakProvider) Name() string {
+	return "flatpak"
+}
+
+func (FlatpakProvider) Description() string {
+	return "Flatpak packages"
+}
+
+func (FlatpakProvider) ID(pkg FlatpakPackage) string {
+	return pkg.Identifier()
+}
+
+func (FlatpakProvider) Query() ([]FlatpakPackage, error) {
+	return FlatpakQuery()
+}
+
+func (FlatpakProvider) Install(pkgs []FlatpakPackage) error {
+	return FlatpakInstall(pkgs)
+}
+
+func (FlatpakProvider) Remove(pkgs []FlatpakPackage) error {
+	return FlatpakRemove(pkgs)
+}
+
+func (FlatpakProvider) Upgrade() error {
+	return FlatpakSystemUpgrade()
+}
+
+func (FlatpakProvider) IsInstalled(pkg FlatpakPackage) (bool, error) {
+	installed, err := FlatpakQuery()
+	if err != nil {
+		return false, err
+	}
+	return slices.ContainsFunc(installed, func(installedPkg FlatpakPackage) bool {
+		return installedPkg.Identifier() == pkg.Identifier()
+	}), nil
 }
\ No newline at end of file
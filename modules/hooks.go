@@ -4,45 +4,38 @@ import (
 	"fmt"
 
 	"github.com/DevReaper0/declarch/parser"
+	"github.com/DevReaper0/declarch/schema"
 	"github.com/DevReaper0/declarch/utils"
 )
 
 type Hook struct {
-	For  string
-	When string
-	As   string
-	Run  string
+	For  string `declarch:"for"`
+	When string `declarch:"when"`
+	As   string `declarch:"as"`
+	Run  string `declarch:"run,required"`
 }
 
 func (h Hook) Exec() error {
 	return utils.ExecCommand([]string{"sh", "-c", h.Run}, "", h.As)
 }
 
+// HookFrom reads a hook section. The terms are the values of its `for` key, e.g. "install" and "remove" for package hooks,
+// and hooks run as the primary user unless `as` is set.
 func HookFrom(section *parser.Section, additionTerm, removalTerm string) (Hook, error) {
-	hook := Hook{}
-
-	if forValue := section.GetFirst("for", additionTerm); forValue == additionTerm || forValue == removalTerm {
-		hook.For = forValue
-	} else {
-		return hook, fmt.Errorf("invalid value for 'for' field in hook section: %s (expected '%s' or '%s')", forValue, additionTerm, removalTerm)
+	hookSchema := schema.Hook(additionTerm, removalTerm)
+	hook := Hook{For: hookSchema.DefaultOf("for"), When: hookSchema.DefaultOf("when"), As: PrimaryUser}
+	if err := section.Decode(&hook); err != nil {
+		return hook, fmt.Errorf("invalid hook section: %w", err)
 	}
 
-	if when := section.GetFirst("when", "after"); when == "before" || when == "after" {
-		hook.When = when
-	} else {
-		return hook, fmt.Errorf("invalid value for 'when' field in hook section: %s", when)
+	if hook.For != additionTerm && hook.For != removalTerm {
+		return hook, fmt.Errorf("invalid value for 'for' field in hook section: %s (expected '%s' or '%s')", hook.For, additionTerm, removalTerm)
 	}
-
-	if as := section.GetFirst("as", PrimaryUser); as != "" {
-		hook.As = as
-	} else {
-		return hook, fmt.Errorf("hook section is missing 'as' field, and no default user is set")
+	if hook.When != "before" && hook.When != "after" {
+		return hook, fmt.Errorf("invalid value for 'when' field in hook section: %s", hook.When)
 	}
-
-	if run := section.GetFirst("run", ""); run != "" {
-		hook.Run = run
-	} else {
-		return hook, fmt.Errorf("hook section is missing 'run' field")
+	if hook.As == "" {
+		return hook, fmt.Errorf("hook section is missing 'as' field, and no default user is set")
 	}
 
 	return hook, nil
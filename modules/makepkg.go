@@ -2,22 +2,28 @@ package modules
 
 import (
 	"os"
+	"path/filepath"
 
 	"github.com/DevReaper0/declarch/utils"
 )
 
 func MakepkgInstall(pkgName string) error {
-	dir, err := os.MkdirTemp("", pkgName)
-	if err != nil {
-		return err
-	}
-
-	err = utils.Chown(dir, PrimaryUser)
-	if err != nil {
-		return err
+	// A dry run doesn't create the build directory, so the commands show the pattern of its name instead
+	dir := filepath.Join(os.TempDir(), pkgName+"*")
+	if !utils.DryRun {
+		var err error
+		dir, err = os.MkdirTemp("", pkgName)
+		if err != nil {
+			return err
+		}
+
+		err = utils.Chown(dir, PrimaryUser)
+		if err != nil {
+			return err
+		}
 	}
 
-	err = utils.ExecCommand([]string{
+	err := utils.ExecCommand([]string{
 		"git", "clone", "https://aur.archlinux.org/" + pkgName + ".git", dir,
 	}, "", PrimaryUser)
 	if err != nil {
@@ -31,10 +37,8 @@ func MakepkgInstall(pkgName string) error {
 		return err
 	}
 
-	err = os.RemoveAll(dir)
-	if err != nil {
-		return err
+	if utils.DryRun {
+		return nil
 	}
-
-	return nil
+	return os.RemoveAll(dir)
 }
\ No newline at end of file
@@ -4,19 +4,19 @@ import (
 	"github.com/DevReaper0/declarch/parser"
 )
 
-type Package struct {
-	value interface{}
+type Package[P any] struct {
+	Value P
 	hooks []Hook
 }
 
-func NewPackage(value interface{}) *Package {
-	return &Package{
-		value: value,
+func NewPackage[P any](value P) *Package[P] {
+	return &Package[P]{
+		Value: value,
 		hooks: make([]Hook, 0),
 	}
 }
 
-func (p *Package) AddHook(section *parser.Section) error {
+func (p *Package[P]) AddHook(section *parser.Section) error {
 	hook, err := HookFrom(section, "install", "remove")
 	if err != nil {
 		return err
@@ -26,29 +26,29 @@ func (p *Package) AddHook(section *parser.Section) error {
 	return nil
 }
 
-type PackageList struct {
-	Packages    []*Package
-	InstallFunc func(interface{}) error
-	RemoveFunc  func(interface{}) error
+type PackageList[P any] struct {
+	Packages    []*Package[P]
+	InstallFunc func([]P) error
+	RemoveFunc  func([]P) error
 }
 
-func NewPackageList(installFunc, removeFunc func(interface{}) error) *PackageList {
-	return &PackageList{
-		Packages:    make([]*Package, 0),
+func NewPackageList[P any](installFunc, removeFunc func([]P) error) *PackageList[P] {
+	return &PackageList[P]{
+		Packages:    make([]*Package[P], 0),
 		InstallFunc: installFunc,
 		RemoveFunc:  removeFunc,
 	}
 }
 
-func (pl *PackageList) Add(pkg *Package) {
+func (pl *PackageList[P]) Add(pkg *Package[P]) {
 	pl.Packages = append(pl.Packages, pkg)
 }
 
-func (pl *PackageList) Clear() {
-	pl.Packages = make([]*Package, 0)
+func (pl *PackageList[P]) Clear() {
+	pl.Packages = make([]*Package[P], 0)
 }
 
-func (pl *PackageList) Install() error {
+func (pl *PackageList[P]) Install() error {
 	// Run before hooks for all packages
 	for _, pkg := range pl.Packages {
 		for _, hook := range pkg.hooks {
@@ -62,12 +62,7 @@ func (pl *PackageList) Install() error {
 
 	// Install all packages in one command
 	if len(pl.Packages) > 0 {
-		values := make([]interface{}, len(pl.Packages))
-		for i, pkg := range pl.Packages {
-			values[i] = pkg.value
-		}
-
-		if err := pl.InstallFunc(values); err != nil {
+		if err := pl.InstallFunc(pl.values()); err != nil {
 			return err
 		}
 	}
@@ -86,7 +81,7 @@ func (pl *PackageList) Install() error {
 	return nil
 }
 
-func (pl *PackageList) Remove() error {
+func (pl *PackageList[P]) Remove() error {
 	// Run before hooks for all packages
 	for _, pkg := range pl.Packages {
 		for _, hook := range pkg.hooks {
@@ -100,12 +95,7 @@ func (pl *PackageList) Remove() error {
 
 	// Remove all packages in one command
 	if len(pl.Packages) > 0 {
-		values := make([]interface{}, len(pl.Packages))
-		for i, pkg := range pl.Packages {
-			values[i] = pkg.value
-		}
-
-		if err := pl.RemoveFunc(values); err != nil {
+		if err := pl.RemoveFunc(pl.values()); err != nil {
 			return err
 		}
 	}
@@ -122,4 +112,12 @@ func (pl *PackageList) Remove() error {
 	}
 
 	return nil
+}
+
+func (pl *PackageList[P]) values() []P {
+	values := make([]P, len(pl.Packages))
+	for i, pkg := range pl.Packages {
+		values[i] = pkg.Value
+	}
+	return values
 }
\ No newline at end of file
@@ -1,34 +1,115 @@
 package modules
 
 import (
-	"fmt"
+	"errors"
+	"os/exec"
+	"strings"
 
 	"github.com/DevReaper0/declarch/utils"
 )
 
-func PacmanInstall(pkgs interface{}) error {
-	pkgNames, ok := pkgs.([]string)
-	if !ok {
-		return fmt.Errorf("expected []string for package names, got %T", pkgs)
-	}
+func PacmanInstall(pkgNames []string) error {
 	return utils.ExecCommand(append([]string{
 		"pacman", "-S", "--needed", "--noconfirm",
 	}, pkgNames...), "", "")
 }
 
-func PacmanRemove(pkgs interface{}) error {
-	pkgNames, ok := pkgs.([]string)
-	if !ok {
-		return fmt.Errorf("expected []string for package names, got %T", pkgs)
-	}
-
+func PacmanRemove(pkgNames []string) error {
 	return utils.ExecCommand(append([]string{
 		"pacman", "-R", "--noconfirm",
 	}, pkgNames...), "", "")
 }
 
+// PacmanMarkDependency marks packages as installed as dependencies,
+// so that they are removed with other orphaned packages once nothing depends on them.
+func PacmanMarkDependency(pkgNames []string) error {
+	return utils.ExecCommand(append([]string{
+		"pacman", "-D", "--asdeps",
+	}, pkgNames...), "", "")
+}
+
 func PacmanSystemUpgrade() error {
 	return utils.ExecCommand([]string{
 		"pacman", "-Syu", "--noconfirm",
 	}, "", "")
+}
+
+// PacmanQuery returns the names of all installed packages.
+func PacmanQuery() ([]string, error) {
+	return pacmanQuery("-Qq")
+}
+
+// PacmanQueryExplicit returns the names of explicitly installed packages.
+// If foreign is true, only packages that are not in the sync repositories (e.g. from the AUR) are returned,
+// otherwise only packages from the sync repositories are returned.
+func PacmanQueryExplicit(foreign bool) ([]string, error) {
+	filter := "-Qqen"
+	if foreign {
+		filter = "-Qqem"
+	}
+
+	return pacmanQuery(filter)
+}
+
+// PacmanQueryForeign returns the names of all installed packages that are not in the sync repositories,
+// such as packages from the AUR.
+func PacmanQueryForeign() ([]string, error) {
+	return pacmanQuery("-Qqm")
+}
+
+// PacmanIsInstalled returns whether a package is installed.
+func PacmanIsInstalled(pkgName string) (bool, error) {
+	pkgNames, err := pacmanQuery("-Qq", pkgName)
+	if err != nil {
+		return false, err
+	}
+	return len(pkgNames) > 0, nil
+}
+
+// pacmanQuery returns the package names printed by a Pacman query.
+// Pacman exits with an error if no packages match the query's filters, which is treated as an empty result.
+func pacmanQuery(args ...string) ([]string, error) {
+	output, err := utils.CommandOutput(append([]string{"pacman"}, args...), "")
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return strings.Fields(output), nil
+}
+
+// PacmanProvider installs packages from the sync repositories with Pacman.
+type PacmanProvider struct{}
+
+func (PacmanProvider) Name() string {
+	return "pacman"
+}
+
+func (PacmanProvider) Description() string {
+	return "system packages via Pacman"
+}
+
+func (PacmanProvider) ID(pkgName string) string {
+	return pkgName
+}
+
+func (PacmanProvider) Query() ([]string, error) {
+	return PacmanQuery()
+}
+
+func (PacmanProvider) Install(pkgNames []string) error {
+	return PacmanInstall(pkgNames)
+}
+
+func (PacmanProvider) Remove(pkgNames []string) error {
+	return PacmanRemove(pkgNames)
+}
+
+func (PacmanProvider) Upgrade() error {
+	return PacmanSystemUpgrade()
+}
+
+func (PacmanProvider) IsInstalled(pkgName string) (bool, error) {
+	return PacmanIsInstalled(pkgName)
 }
\ No newline at end of file
@@ -0,0 +1,101 @@
+package modules
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+// TagPresets holds the tag presets and host mappings of the `tags` section, e.g.
+//
+//	tags {
+//	  preset laptop = +gui +wifi -server
+//	  host thinkpad = laptop
+//	}
+type TagPresets struct {
+	presets map[string][]string
+	hosts   map[string][]string
+}
+
+// NewTagPresets reads the presets and host mappings of a `tags` section, which can be nil.
+func NewTagPresets(section *parser.Section) *TagPresets {
+	p := &TagPresets{presets: make(map[string][]string), hosts: make(map[string][]string)}
+	if section == nil {
+		return p
+	}
+
+	for key, values := range section.Values {
+		kind, name, _ := strings.Cut(key, " ")
+		name = strings.TrimSpace(name)
+		if len(values) == 0 || name == "" {
+			continue
+		}
+
+		switch kind {
+		case "preset":
+			p.presets[name] = strings.Fields(values[0])
+		case "host":
+			p.hosts[name] = strings.Fields(values[0])
+		}
+	}
+	return p
+}
+
+// Presets returns the names of the presets, sorted.
+func (p *TagPresets) Presets() []string {
+	names := []string{}
+	for name := range p.presets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Expand replaces the preset names in a list of tags and presets with their tags, e.g. `laptop +dev` with `+gui +wifi -server +dev`.
+// Presets can include other presets.
+func (p *TagPresets) Expand(items []string) ([]string, error) {
+	return p.expand(items, nil)
+}
+
+func (p *TagPresets) expand(items []string, chain []string) ([]string, error) {
+	tags := []string{}
+	for _, item := range items {
+		if strings.HasPrefix(item, "+") || strings.HasPrefix(item, "-") {
+			if v := VerifyTag("+" + item[1:]); v != "" {
+				return nil, fmt.Errorf("%s", v)
+			} else if strings.HasPrefix(item[1:], "!") {
+				return nil, fmt.Errorf("tag '%s' can't be required here, write '%s' instead", item, item[:1]+item[2:])
+			}
+			tags = append(tags, item)
+			continue
+		}
+
+		preset, ok := p.presets[item]
+		if !ok {
+			return nil, fmt.Errorf("unknown tag preset '%s'", item)
+		} else if slices.Contains(chain, item) {
+			return nil, fmt.Errorf("tag preset cycle: %s", strings.Join(append(slices.Clone(chain), item), " -> "))
+		}
+
+		expanded, err := p.expand(preset, append(slices.Clone(chain), item))
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, expanded...)
+	}
+	return tags, nil
+}
+
+// HostTags returns the tags mapped to a machine, by hostname or machine ID, and the host entry that matched.
+// If both are mapped, the hostname is used.
+func (p *TagPresets) HostTags(hostname, machineID string) ([]string, string, error) {
+	for _, host := range []string{hostname, machineID} {
+		if items, ok := p.hosts[host]; ok && host != "" {
+			tags, err := p.Expand(items)
+			return tags, host, err
+		}
+	}
+	return nil, "", nil
+}
\ No newline at end of file
@@ -0,0 +1,94 @@
+package modules
+
+import (
+	"fmt"
+	"slices"
+)
+
+// RegisteredProvider is the part of a Provider that does not depend on its package type,
+// so that providers with different package types can be kept in one registry.
+type RegisteredProvider interface {
+	// Name returns the name of the provider, which is also the name of its section under `packages`.
+	Name() string
+	// Description describes the packages of the provider, e.g. "system packages via Pacman".
+	Description() string
+	// Upgrade upgrades all packages installed with the provider.
+	Upgrade() error
+}
+
+// Provider is a package manager that packages are installed with and removed from.
+// P is the type of the package values the provider works with.
+type Provider[P any] interface {
+	RegisteredProvider
+
+	// ID returns a string that uniquely identifies a package within the provider.
+	ID(pkg P) string
+	// Query returns all packages installed with the provider.
+	Query() ([]P, error)
+	// Install installs packages in one operation where possible.
+	Install(pkgs []P) error
+	// Remove removes packages in one operation where possible.
+	Remove(pkgs []P) error
+	// IsInstalled returns whether a package is installed.
+	IsInstalled(pkg P) (bool, error)
+}
+
+// NamedPackage is implemented by package values whose name differs from their ID,
+// such as Flatpak packages, which are identified by their installation and name.
+type NamedPackage interface {
+	PackageName() string
+}
+
+// PackageName returns the name of a package, which is its ID unless the package value implements NamedPackage.
+func PackageName[P any](provider Provider[P], pkg P) string {
+	if named, ok := any(pkg).(NamedPackage); ok {
+		return named.PackageName()
+	}
+	return provider.ID(pkg)
+}
+
+// UpgradeChecker is implemented by providers that can only upgrade packages in some configurations.
+type UpgradeChecker interface {
+	CanUpgrade() bool
+}
+
+var providers = []RegisteredProvider{}
+
+// RegisterProvider adds a provider to the registry.
+// A provider with the same name that is already registered is replaced, keeping its position.
+func RegisterProvider(provider RegisteredProvider) {
+	index := slices.IndexFunc(providers, func(p RegisteredProvider) bool {
+		return p.Name() == provider.Name()
+	})
+	if index == -1 {
+		providers = append(providers, provider)
+	} else {
+		providers[index] = provider
+	}
+}
+
+// Providers returns all registered providers in the order they were registered.
+func Providers() []RegisteredProvider {
+	return slices.Clone(providers)
+}
+
+// GetProvider returns the registered provider with the given name and package type.
+func GetProvider[P any](name string) (Provider[P], error) {
+	for _, provider := range providers {
+		if provider.Name() != name {
+			continue
+		}
+		if typed, ok := provider.(Provider[P]); ok {
+			return typed, nil
+		}
+		var pkg P
+		return nil, fmt.Errorf("provider '%s' does not handle packages of type %T", name, pkg)
+	}
+	return nil, fmt.Errorf("no provider named '%s' is registered", name)
+}
+
+func init() {
+	RegisterProvider(PacmanProvider{})
+	RegisterProvider(AURProvider{Helper: "makepkg"})
+	RegisterProvider(FlatpakProvider{})
+}
\ No newline at end of file
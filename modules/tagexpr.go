@@ -0,0 +1,272 @@
+package modules
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tagExprKind int
+
+const (
+	// tagList is a list of tags such as `+desktop +!bare`, matched like before tag expressions existed:
+	// `+tag` values are included by default or if the tag is selected, and `+!tag` values only if the tag is selected.
+	// Each tag of an expression is a list of one tag, so that it means the same in both forms.
+	tagList tagExprKind = iota
+	tagNot
+	tagAnd
+	tagOr
+)
+
+// TagExpr is the parsed tags of a value or section,
+// either a list like `+desktop +!bare` or an expression like `+laptop & !+server`.
+type TagExpr struct {
+	kind tagExprKind
+	// tags holds the tags of a list.
+	tags     []string
+	operands []*TagExpr
+}
+
+// ParseTags parses the tags of a value (the part after the first comma) or the `tags` key of a section.
+// Expressions combine tags with `!` (not), `&` (and), `|` (or) and parentheses, e.g. `(+!gui | +!dev) & !+!server`.
+// Tags without operators are a list, e.g. `+desktop +!bare`.
+// A tag matches the same way in both forms: `+gui` unless `gui` or `default` is deselected, and `+!gui` only if `gui` is selected.
+func ParseTags(tags string) (*TagExpr, error) {
+	if !isTagExpression(tags) {
+		expr := &TagExpr{kind: tagList}
+		for _, tag := range strings.Fields(tags) {
+			if v := VerifyTag(tag); v != "" {
+				return nil, fmt.Errorf("%s", v)
+			}
+			expr.tags = append(expr.tags, tag)
+		}
+		return expr, nil
+	}
+
+	p := &tagParser{tokens: tokenizeTags(tags), end: len(tags) + 1}
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if token, ok := p.peek(); ok {
+		if token.text == ")" {
+			return nil, fmt.Errorf("unexpected ')' at column %d", token.column)
+		}
+		return nil, fmt.Errorf("expected '&' or '|' before '%s' at column %d", token.text, token.column)
+	}
+	return expr, nil
+}
+
+// isTagExpression reports whether tags use operators, rather than being a list.
+func isTagExpression(tags string) bool {
+	if strings.ContainsAny(tags, "&|()") {
+		return true
+	}
+	for _, field := range strings.Fields(tags) {
+		if strings.HasPrefix(field, "!") {
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the names of the tags used, without '+' or '!', in the order they appear.
+func (expr *TagExpr) Names() []string {
+	names := []string{}
+	for _, tag := range expr.tags {
+		names = append(names, strings.TrimPrefix(strings.TrimPrefix(tag, "+"), "!"))
+	}
+	for _, operand := range expr.operands {
+		names = append(names, operand.Names()...)
+	}
+	return names
+}
+
+// Match reports whether the tags match a tag set.
+func (expr *TagExpr) Match(ts *TagSet) bool {
+	switch expr.kind {
+	case tagNot:
+		return !expr.operands[0].Match(ts)
+	case tagAnd:
+		for _, operand := range expr.operands {
+			if !operand.Match(ts) {
+				return false
+			}
+		}
+		return true
+	case tagOr:
+		for _, operand := range expr.operands {
+			if operand.Match(ts) {
+				return true
+			}
+		}
+		return false
+	}
+
+	included := true
+	if len(expr.tags) == 0 {
+		for _, tag := range ts.tags {
+			switch tag {
+			case "+default":
+				included = true
+			case "-default":
+				included = false
+			}
+		}
+		return included
+	}
+
+	for _, linkedTag := range expr.tags {
+		tagName := strings.TrimPrefix(linkedTag, "+")
+		isRequired := false
+		if strings.HasPrefix(tagName, "!") {
+			included = false
+			tagName = strings.TrimPrefix(tagName, "!")
+			isRequired = true
+		}
+
+		for _, tag := range ts.tags {
+			if tag == "+"+tagName || (!isRequired && tag == "+default") {
+				included = true
+			} else if tag == "-"+tagName || (!isRequired && tag == "-default") {
+				included = false
+			}
+		}
+	}
+	return included
+}
+
+type tagToken struct {
+	text string
+	// column is the one-based position of the token in the tags.
+	column int
+}
+
+func tokenizeTags(tags string) []tagToken {
+	tokens := []tagToken{}
+	for i := 0; i < len(tags); {
+		switch c := tags[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.IndexByte("&|()!", c) != -1:
+			tokens = append(tokens, tagToken{text: string(c), column: i + 1})
+			i++
+		default:
+			end := i
+			for end < len(tags) && strings.IndexByte(" \t&|()", tags[end]) == -1 && !(end > i && tags[end] == '!' && tags[end-1] != '+') {
+				end++
+			}
+			tokens = append(tokens, tagToken{text: tags[i:end], column: i + 1})
+			i = end
+		}
+	}
+	return tokens
+}
+
+// tagParser parses tag expressions with a recursive descent parser.
+// `!` binds tighter than `&`, which binds tighter than `|`.
+type tagParser struct {
+	tokens []tagToken
+	next   int
+	// end is the column after the last character, for errors at the end of the tags.
+	end int
+}
+
+func (p *tagParser) peek() (tagToken, bool) {
+	if p.next >= len(p.tokens) {
+		return tagToken{}, false
+	}
+	return p.tokens[p.next], true
+}
+
+func (p *tagParser) accept(text string) bool {
+	if token, ok := p.peek(); ok && token.text == text {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *tagParser) or() (*TagExpr, error) {
+	return p.binary("|", tagOr, p.and)
+}
+
+func (p *tagParser) and() (*TagExpr, error) {
+	return p.binary("&", tagAnd, p.unary)
+}
+
+func (p *tagParser) binary(operator string, kind tagExprKind, operand func() (*TagExpr, error)) (*TagExpr, error) {
+	expr, err := operand()
+	if err != nil {
+		return nil, err
+	}
+
+	operands := []*TagExpr{expr}
+	for p.accept(operator) {
+		expr, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		operands = append(operands, expr)
+	}
+
+	if len(operands) == 1 {
+		return operands[0], nil
+	}
+	return &TagExpr{kind: kind, operands: operands}, nil
+}
+
+func (p *tagParser) unary() (*TagExpr, error) {
+	token, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("expected a tag at column %d", p.end)
+	}
+	p.next++
+
+	switch token.text {
+	case "!":
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return &TagExpr{kind: tagNot, operands: []*TagExpr{operand}}, nil
+	case "(":
+		expr, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing ')' for the '(' at column %d", token.column)
+		}
+		return expr, nil
+	case "&", "|", ")":
+		return nil, fmt.Errorf("expected a tag before '%s' at column %d", token.text, token.column)
+	}
+
+	if v := VerifyTag(token.text); v != "" {
+		return nil, fmt.Errorf("%s at column %d", v, token.column)
+	}
+	return &TagExpr{kind: tagList, tags: []string{token.text}}, nil
+}
+
+// VerifyTag checks a tag such as `+desktop` or `+!bare`, and returns a description of the problem or an empty string.
+func VerifyTag(tag string) string {
+	if !strings.HasPrefix(tag, "+") {
+		return fmt.Sprintf("Tag '%s' must start with '+' character", tag)
+	}
+
+	tagName := strings.TrimPrefix(tag, "+")
+	tagName = strings.TrimPrefix(tagName, "!")
+
+	if len(tagName) == 0 {
+		return "Tag name cannot be empty"
+	}
+
+	for _, char := range tagName {
+		if !unicode.IsLetter(char) && !unicode.IsNumber(char) && char != '_' && char != '-' {
+			return fmt.Sprintf("Tag name '%s' contains invalid character '%c'", tagName, char)
+		}
+	}
+
+	return ""
+}
\ No newline at end of file
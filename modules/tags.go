@@ -29,6 +29,11 @@ func (ts *TagSet) AddTags(tags []string) {
 	ts.tags = append(ts.tags, tags...)
 }
 
+// Tags returns all tags in the TagSet in the order they were added
+func (ts *TagSet) Tags() []string {
+	return append([]string{}, ts.tags...)
+}
+
 // HasTag checks if the TagSet contains a specific tag
 func (ts *TagSet) HasTag(tag string) bool {
 	for _, t := range ts.tags {
@@ -39,62 +44,68 @@ func (ts *TagSet) HasTag(tag string) bool {
 	return false
 }
 
+// Selected reports whether a tag name (without '+' or '-') is selected, for `tag(name)` conditions.
+// Later tags override earlier ones, so `+gaming -gaming` doesn't select `gaming`.
+func (ts *TagSet) Selected(name string) bool {
+	selected := false
+	for _, tag := range ts.tags {
+		switch tag {
+		case "+" + name:
+			selected = true
+		case "-" + name:
+			selected = false
+		}
+	}
+	return selected
+}
+
 // GetAll returns all values for a key that match the current tag set
 // If an item has spaces, it will be split into multiple items.
 func (ts *TagSet) GetAll(section *parser.Section, key string) []string {
-	items := section.GetAll(key)
 	result := []string{}
-	for i := 0; i < len(items); i++ {
-		included := true
-
-		parts := strings.Split(items[i], ",")
-
-		// Check for tagging, e.g., "pkg, +!bare" etc.
-		if len(parts) > 1 {
-			tagPart := strings.TrimSpace(parts[1])
-			linkedTags := strings.Fields(tagPart)
-
-			for _, linkedTag := range linkedTags {
-				if !strings.HasPrefix(linkedTag, "+") {
-					color.Set(color.FgRed)
-					fmt.Printf("Invalid tag format in '%s': %s\n", key, linkedTag)
-					color.Unset()
-					continue
-				}
-
-				tagName := strings.TrimPrefix(linkedTag, "+")
-				isRequired := false
-				if strings.HasPrefix(tagName, "!") {
-					included = false
-					tagName = strings.TrimPrefix(tagName, "!")
-					isRequired = true
-				}
-
-				for _, tag := range ts.tags {
-					if tag == "+"+tagName || (!isRequired && tag == "+default") {
-						included = true
-					} else if tag == "-"+tagName || (!isRequired && tag == "-default") {
-						included = false
-					}
-				}
-			}
-		} else {
-			for _, tag := range ts.tags {
-				switch tag {
-				case "+default":
-					included = true
-				case "-default":
-					included = false
-				}
-			}
+	for _, item := range section.GetAll(key) {
+		valuesPart, tagPart, _ := strings.Cut(item, ",")
+
+		included, err := ts.Includes(tagPart)
+		if err != nil {
+			color.Set(color.FgRed)
+			fmt.Printf("Invalid tags in '%s': %v\n", key, err)
+			color.Unset()
+			continue
 		}
 
 		if included {
-			valuesPart := strings.TrimSpace(parts[0])
-			values := strings.Fields(valuesPart)
-
-			result = append(result, values...)
+			result = append(result, strings.Fields(valuesPart)...)
 		}
 	}
 	return result
+}
+
+// IncludesSection reports whether a section matches the current tag set.
+// Sections without a `tags` key are always included.
+func (ts *TagSet) IncludesSection(section *parser.Section) bool {
+	tags := section.GetFirst("tags", "")
+	if tags == "" {
+		return true
+	}
+
+	included, err := ts.Includes(tags)
+	if err != nil {
+		color.Set(color.FgRed)
+		fmt.Printf("Invalid tags in section: %v\n", err)
+		color.Unset()
+		return false
+	}
+	return included
+}
+
+// Includes reports whether the tags of a value, e.g. "+desktop +!bare" or "+!laptop & !+!server", match the current tag set.
+// A tag means the same in a list and in an expression: `+gui` is included unless `gui` or `default` is deselected,
+// and `+!gui` only if `gui` is selected, so `!+server` only matches when `server` is deselected.
+func (ts *TagSet) Includes(tags string) (bool, error) {
+	expr, err := ParseTags(tags)
+	if err != nil {
+		return false, err
+	}
+	return expr.Match(ts), nil
 }
\ No newline at end of file
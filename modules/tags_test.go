@@ -0,0 +1,146 @@
+package modules_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/parser"
+)
+
+func TestTagSet_Includes(t *testing.T) {
+	defaults := modules.NewTagSet("+default")
+	bare := modules.NewTagSet("+default", "-default", "+bare")
+	laptop := modules.NewTagSet("+default", "+laptop", "+gui")
+
+	tests := []struct {
+		tags                   string
+		defaults, bare, laptop bool
+	}{
+		{"", true, false, true},
+		{"+bare", true, true, true},
+		{"+!bare", false, true, false},
+		{"+desktop +!bare", false, true, false},
+		{"+!laptop & !+!server", false, false, true},
+		{"(+!gui | +!dev) & !+!bare", false, false, true},
+		{"!+!laptop", true, true, false},
+		{"!+laptop", false, true, false},
+		{"+default | +bare", true, true, true},
+	}
+	for _, test := range tests {
+		for _, c := range []struct {
+			tagSet   *modules.TagSet
+			expected bool
+		}{{defaults, test.defaults}, {bare, test.bare}, {laptop, test.laptop}} {
+			included, err := c.tagSet.Includes(test.tags)
+			assert.NoError(t, err, test.tags)
+			assert.Equal(t, c.expected, included, "%q with %v", test.tags, c.tagSet.Tags())
+		}
+	}
+}
+
+// A tag means the same whether it is written alone or in an expression.
+func TestTagSet_IncludesSameInBothForms(t *testing.T) {
+	tagSets := []*modules.TagSet{
+		modules.NewTagSet("+default"),
+		modules.NewTagSet("+default", "-gui"),
+		modules.NewTagSet("+default", "-default"),
+		modules.NewTagSet("+default", "-default", "+gui"),
+		modules.NewTagSet("+default", "+gui", "+wifi"),
+	}
+	for _, ts := range tagSets {
+		for _, tag := range []string{"+gui", "+!gui"} {
+			list, err := ts.Includes(tag)
+			assert.NoError(t, err)
+			expression, err := ts.Includes("(" + tag + ")")
+			assert.NoError(t, err)
+			assert.Equal(t, list, expression, "%s with %v", tag, ts.Tags())
+
+			both, err := ts.Includes(tag + " & " + tag)
+			assert.NoError(t, err)
+			assert.Equal(t, list, both, "%s with %v", tag, ts.Tags())
+		}
+
+		gui, _ := ts.Includes("+gui")
+		wifi, _ := ts.Includes("+wifi")
+		and, _ := ts.Includes("+gui & +wifi")
+		assert.Equal(t, gui && wifi, and, "+gui & +wifi with %v", ts.Tags())
+	}
+}
+
+func TestParseTags_Errors(t *testing.T) {
+	tests := map[string]string{
+		"desktop":    "Tag 'desktop' must start with '+' character",
+		"+a & ":      "expected a tag at column 6",
+		"+a & & +b":  "expected a tag before '&' at column 6",
+		"(+a | +b":   "missing ')' for the '(' at column 1",
+		"+a)":        "unexpected ')' at column 3",
+		"+a +b & +c": "expected '&' or '|' before '+b' at column 4",
+		"+a & +!":    "Tag name cannot be empty at column 6",
+		"+a & +b.c":  "Tag name 'b.c' contains invalid character '.' at column 6",
+		"!+":         "Tag name cannot be empty at column 2",
+	}
+	for tags, expected := range tests {
+		_, err := modules.ParseTags(tags)
+		assert.EqualError(t, err, expected, tags)
+	}
+}
+
+func TestTagSet_Sections(t *testing.T) {
+	section, err := parser.Parse("user {\n  username = a\n}\nuser {\n  username = b\n  tags = +!laptop & !+!server\n}\nuser {\n  username = c\n  tags = +!bare\n}\n")
+	assert.NoError(t, err)
+
+	included := func(ts *modules.TagSet) []string {
+		names := []string{}
+		for _, user := range section.Sections["user"] {
+			if ts.IncludesSection(user) {
+				names = append(names, user.GetFirst("username", ""))
+			}
+		}
+		return names
+	}
+	assert.Equal(t, []string{"a"}, included(modules.NewTagSet("+default")))
+	assert.Equal(t, []string{"a", "b"}, included(modules.NewTagSet("+default", "+laptop")))
+	assert.Equal(t, []string{"a", "c"}, included(modules.NewTagSet("+default", "-default", "+bare")))
+
+	values := mustParse(t, "package = zsh, +!laptop & !+!server\npackage = rustup\npackage = steam, !+laptop\n")
+	assert.Equal(t, []string{"zsh", "rustup"}, modules.NewTagSet("+default", "+laptop").GetAll(values, "package"))
+}
+
+func mustParse(t *testing.T, input string) *parser.Section {
+	section, err := parser.Parse(input)
+	assert.NoError(t, err)
+	return section
+}
+func TestTagPresets(t *testing.T) {
+	section := mustParse(t, "preset gui = +gui +wifi\npreset laptop = gui -server\npreset loop = again\npreset again = loop\nhost thinkpad = laptop +dev\nhost 0123abcd = +server\n")
+	presets := modules.NewTagPresets(section)
+	assert.Equal(t, []string{"again", "gui", "laptop", "loop"}, presets.Presets())
+
+	tags, err := presets.Expand([]string{"laptop", "+extra"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"+gui", "+wifi", "-server", "+extra"}, tags)
+
+	_, err = presets.Expand([]string{"loop"})
+	assert.EqualError(t, err, "tag preset cycle: loop -> again -> loop")
+	_, err = presets.Expand([]string{"desktop"})
+	assert.EqualError(t, err, "unknown tag preset 'desktop'")
+	_, err = presets.Expand([]string{"+!gui"})
+	assert.EqualError(t, err, "tag '+!gui' can't be required here, write '+gui' instead")
+
+	tags, host, err := presets.HostTags("thinkpad", "0123abcd")
+	assert.NoError(t, err)
+	assert.Equal(t, "thinkpad", host)
+	assert.Equal(t, []string{"+gui", "+wifi", "-server", "+dev"}, tags)
+
+	tags, host, err = presets.HostTags("server", "0123abcd")
+	assert.NoError(t, err)
+	assert.Equal(t, "0123abcd", host)
+	assert.Equal(t, []string{"+server"}, tags)
+
+	tags, host, err = presets.HostTags("unknown", "")
+	assert.NoError(t, err)
+	assert.Empty(t, host)
+	assert.Empty(t, tags)
+}
\ No newline at end of file
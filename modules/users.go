@@ -1,7 +1,10 @@
 package modules
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
 	"os/user"
 	"slices"
 	"strconv"
@@ -12,38 +15,19 @@ import (
 )
 
 type User struct {
-	Username   string
-	FullName   string
-	Shell      string
-	CreateHome bool
-	HomeDir    string
-	Groups     []string
+	Username   string   `declarch:"username,required"`
+	FullName   string   `declarch:"full_name"`
+	Shell      string   `declarch:"shell"`
+	CreateHome bool     `declarch:"create_home,default=true"`
+	HomeDir    string   `declarch:"home_dir"`
+	Groups     []string `declarch:"group"`
 }
 
 func UserFrom(section *parser.Section) (User, error) {
 	user := User{}
-
-	if username := section.GetFirst("username", ""); username != "" {
-		user.Username = username
-	} else {
-		return user, fmt.Errorf("user section is missing 'username' field")
-	}
-
-	user.FullName = section.GetFirst("full_name", "")
-	user.Shell = section.GetFirst("shell", "")
-
-	{
-		createHomeString := section.GetFirst("create_home", "true")
-		createHome, err := strconv.ParseBool(createHomeString)
-		if err != nil {
-			return user, fmt.Errorf("invalid value for 'create_home' field in user section '%s': %s", user.Username, createHomeString)
-		}
-		user.CreateHome = createHome
+	if err := section.Decode(&user); err != nil {
+		return user, fmt.Errorf("invalid user section: %w", err)
 	}
-	user.HomeDir = section.GetFirst("home_dir", "")
-
-	user.Groups = section.GetAll("group")
-
 	return user, nil
 }
 
@@ -139,4 +123,91 @@ func ModifyUser(previousUser, currentUser User) error {
 	args = append(args, currentUser.Username)
 
 	return utils.ExecCommand(args, "", "")
+}
+
+// LookupUser returns the current system state of a user, and whether the user exists.
+// The user's groups only include supplementary groups, not the primary group.
+func LookupUser(username string) (User, bool, error) {
+	user := User{}
+
+	output, err := utils.CommandOutput([]string{"getent", "passwd", username}, "")
+	if err != nil {
+		// getent exits with status 2 if the key could not be found
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 2 {
+			return user, false, nil
+		}
+		return user, false, err
+	}
+
+	fields := strings.Split(strings.TrimSpace(output), ":")
+	if len(fields) < 7 {
+		return user, false, fmt.Errorf("unexpected passwd entry for user %s: %s", username, strings.TrimSpace(output))
+	}
+
+	user.Username = fields[0]
+	user.FullName = strings.SplitN(fields[4], ",", 2)[0]
+	user.HomeDir = fields[5]
+	user.Shell = fields[6]
+
+	if _, err := os.Stat(user.HomeDir); err == nil {
+		user.CreateHome = true
+	}
+
+	output, err = utils.CommandOutput([]string{"getent", "group"}, "")
+	if err != nil {
+		return user, true, err
+	}
+
+	user.Groups = []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ":")
+		if len(fields) < 4 {
+			continue
+		}
+		if slices.Contains(strings.Split(fields[3], ","), username) {
+			user.Groups = append(user.Groups, fields[0])
+		}
+	}
+
+	return user, true, nil
+}
+
+// ListRegularUsers returns the usernames of all users that are not system users,
+// based on the UID_MIN and UID_MAX settings in /etc/login.defs.
+func ListRegularUsers() ([]string, error) {
+	uidMin, uidMax := 1000, 60000
+	if content, err := os.ReadFile("/etc/login.defs"); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			if value, err := strconv.Atoi(fields[1]); err == nil {
+				switch fields[0] {
+				case "UID_MIN":
+					uidMin = value
+				case "UID_MAX":
+					uidMax = value
+				}
+			}
+		}
+	}
+
+	output, err := utils.CommandOutput([]string{"getent", "passwd"}, "")
+	if err != nil {
+		return nil, err
+	}
+
+	usernames := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ":")
+		if len(fields) < 7 {
+			continue
+		}
+		if uid, err := strconv.Atoi(fields[2]); err == nil && uid >= uidMin && uid <= uidMax {
+			usernames = append(usernames, fields[0])
+		}
+	}
+	return usernames, nil
 }
\ No newline at end of file
@@ -0,0 +1,310 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ParseOptions controls how a configuration is parsed.
+type ParseOptions struct {
+	// HasTag reports whether a tag is selected, for `tag(name)` conditions.
+	// If nil, only the `default` tag is selected.
+	HasTag func(name string) bool
+	// Repeated reports whether the key or section at a path, e.g. `packages/pacman/package`, can be set several times.
+	// Layers add to repeated keys and sections, and replace the others. If nil, every key and section is repeated.
+	Repeated func(path string) bool
+}
+
+func (opts ParseOptions) repeated(path string) bool {
+	return opts.Repeated == nil || opts.Repeated(path)
+}
+
+func (opts ParseOptions) hasTag(name string) bool {
+	if opts.HasTag == nil {
+		return name == "default"
+	}
+	return opts.HasTag(name)
+}
+
+// ifBlock is an `if` block being parsed.
+type ifBlock struct {
+	pos Position
+	// depth is the number of open sections when the block was opened.
+	depth int
+	// taken is true once one of the branches of the block was included.
+	taken bool
+}
+
+// conditionHeader returns the keyword (`if`, `else` or `else if`) and the condition of a line opening a conditional block,
+// e.g. `if $hostname == "laptop" {` or `} else {`.
+func conditionHeader(text string) (keyword string, condition string, ok bool) {
+	if !strings.HasSuffix(text, "{") {
+		return "", "", false
+	}
+	text = strings.TrimSpace(strings.TrimSuffix(text, "{"))
+
+	if rest, found := strings.CutPrefix(text, "}"); found {
+		rest = strings.TrimSpace(rest)
+		if rest == "else" {
+			return "else", "", true
+		}
+		if condition, found := cutKeyword(rest, "else if"); found {
+			return "else if", condition, true
+		}
+		return "", "", false
+	}
+
+	if condition, found := cutKeyword(text, "if"); found {
+		return "if", condition, true
+	}
+	return "", "", false
+}
+
+// cutKeyword returns the text after a keyword, if the text starts with the keyword followed by a space or a parenthesis.
+func cutKeyword(text string, keyword string) (string, bool) {
+	rest, found := strings.CutPrefix(text, keyword)
+	if !found || rest == "" || (rest[0] != ' ' && rest[0] != '\t' && rest[0] != '(') {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+type conditionToken struct {
+	text string
+	// quoted is true for string literals, whose text is unquoted.
+	quoted bool
+}
+
+// tokenizeCondition splits a condition into string literals, operators, parentheses, brackets, commas and words.
+func tokenizeCondition(condition string) ([]conditionToken, error) {
+	tokens := []conditionToken{}
+	for i := 0; i < len(condition); {
+		c := condition[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			var sb strings.Builder
+			end := i + 1
+			for ; end < len(condition) && condition[end] != '"'; end++ {
+				// Only `\"` and `\\` are escapes, so that regular expressions can be written as is
+				if condition[end] == '\\' && end+1 < len(condition) && (condition[end+1] == '"' || condition[end+1] == '\\') {
+					end++
+				}
+				sb.WriteByte(condition[end])
+			}
+			if end == len(condition) {
+				return nil, fmt.Errorf("missing closing '\"'")
+			}
+			tokens = append(tokens, conditionToken{text: sb.String(), quoted: true})
+			i = end + 1
+		case strings.ContainsRune("()[],", rune(c)):
+			tokens = append(tokens, conditionToken{text: string(c)})
+			i++
+		default:
+			if op := matchOperator(condition[i:]); op != "" {
+				tokens = append(tokens, conditionToken{text: op})
+				i += len(op)
+				continue
+			}
+
+			end := i
+			for end < len(condition) && !strings.ContainsRune(" \t\"()[],", rune(condition[end])) && matchOperator(condition[end:]) == "" {
+				if condition[end] == '$' && end+1 < len(condition) && condition[end+1] == '{' {
+					if closing := closingBrace(condition, end+2); closing != -1 {
+						end = closing
+					}
+				}
+				end++
+			}
+			tokens = append(tokens, conditionToken{text: condition[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func matchOperator(s string) string {
+	for _, op := range []string{"&&", "||", "==", "!=", "=~", "!~", "!"} {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// conditionParser evaluates a condition with a recursive descent parser.
+type conditionParser struct {
+	tokens []conditionToken
+	next   int
+	// expand expands the variables of an operand.
+	expand func(operand string) string
+	opts   ParseOptions
+}
+
+// evaluateCondition reports whether a condition is true. Conditions can use:
+//   - `a == b`, `a != b`, `a =~ regex` and `a !~ regex` to compare strings, e.g. `$hostname == "laptop"`,
+//   - `a in [b, c]` for membership,
+//   - `tag(name)` to test whether a tag is selected,
+//   - `!`, `&&`, `||` and parentheses to combine them.
+//
+// Operands are words or quoted strings, in which variables are expanded.
+func evaluateCondition(condition string, expand func(operand string) string, opts ParseOptions) (bool, error) {
+	tokens, err := tokenizeCondition(condition)
+	if err != nil {
+		return false, err
+	} else if len(tokens) == 0 {
+		return false, fmt.Errorf("missing condition")
+	}
+
+	p := &conditionParser{tokens: tokens, expand: expand, opts: opts}
+	result, err := p.or()
+	if err != nil {
+		return false, err
+	} else if p.next < len(p.tokens) {
+		return false, fmt.Errorf("unexpected '%s'", p.tokens[p.next].text)
+	}
+	return result, nil
+}
+
+func (p *conditionParser) peek() (conditionToken, bool) {
+	if p.next >= len(p.tokens) {
+		return conditionToken{}, false
+	}
+	return p.tokens[p.next], true
+}
+
+// accept consumes the next token if it is the given unquoted text.
+func (p *conditionParser) accept(text string) bool {
+	if token, ok := p.peek(); ok && !token.quoted && token.text == text {
+		p.next++
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) expect(text string) error {
+	if !p.accept(text) {
+		if token, ok := p.peek(); ok {
+			return fmt.Errorf("expected '%s', got '%s'", text, token.text)
+		}
+		return fmt.Errorf("expected '%s'", text)
+	}
+	return nil
+}
+
+func (p *conditionParser) or() (bool, error) {
+	result, err := p.and()
+	for err == nil && p.accept("||") {
+		var right bool
+		right, err = p.and()
+		result = result || right
+	}
+	return result, err
+}
+
+func (p *conditionParser) and() (bool, error) {
+	result, err := p.unary()
+	for err == nil && p.accept("&&") {
+		var right bool
+		right, err = p.unary()
+		result = result && right
+	}
+	return result, err
+}
+
+func (p *conditionParser) unary() (bool, error) {
+	if p.accept("!") {
+		result, err := p.unary()
+		return !result, err
+	}
+
+	if p.accept("(") {
+		result, err := p.or()
+		if err != nil {
+			return false, err
+		}
+		return result, p.expect(")")
+	}
+
+	if token, ok := p.peek(); ok && !token.quoted && token.text == "tag" && p.next+1 < len(p.tokens) && p.tokens[p.next+1].text == "(" {
+		p.next += 2
+		name, err := p.operand()
+		if err != nil {
+			return false, err
+		}
+		if err := p.expect(")"); err != nil {
+			return false, err
+		}
+		return p.opts.hasTag(strings.TrimPrefix(name, "+")), nil
+	}
+
+	return p.comparison()
+}
+
+func (p *conditionParser) comparison() (bool, error) {
+	left, err := p.operand()
+	if err != nil {
+		return false, err
+	}
+
+	op, ok := p.peek()
+	if !ok || op.quoted {
+		return false, fmt.Errorf("expected an operator after '%s'", left)
+	}
+	p.next++
+
+	switch op.text {
+	case "==", "!=", "=~", "!~":
+		right, err := p.operand()
+		if err != nil {
+			return false, err
+		}
+
+		switch op.text {
+		case "==":
+			return left == right, nil
+		case "!=":
+			return left != right, nil
+		}
+
+		re, err := regexp.Compile(right)
+		if err != nil {
+			return false, fmt.Errorf("invalid regular expression '%s': %v", right, err)
+		}
+		return re.MatchString(left) == (op.text == "=~"), nil
+	case "in":
+		if err := p.expect("["); err != nil {
+			return false, err
+		}
+		items := []string{}
+		for !p.accept("]") {
+			if len(items) > 0 {
+				if err := p.expect(","); err != nil {
+					return false, err
+				}
+			}
+			item, err := p.operand()
+			if err != nil {
+				return false, err
+			}
+			items = append(items, item)
+		}
+		return slices.Contains(items, left), nil
+	}
+	return false, fmt.Errorf("expected an operator after '%s', got '%s'", left, op.text)
+}
+
+// operand returns the value of a word or string literal, with its variables expanded.
+func (p *conditionParser) operand() (string, error) {
+	token, ok := p.peek()
+	if !ok {
+		return "", fmt.Errorf("unexpected end of condition")
+	} else if !token.quoted && (strings.ContainsAny(token.text, "()[],") || matchOperator(token.text) != "") {
+		return "", fmt.Errorf("unexpected '%s'", token.text)
+	}
+	p.next++
+	return p.expand(token.text), nil
+}
\ No newline at end of file
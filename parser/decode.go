@@ -0,0 +1,183 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseBool parses a boolean value, as accepted by GetBool and Decode.
+func ParseBool(value string) (bool, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value '%s', expected true or false", value)
+	}
+	return b, nil
+}
+
+// ParseInt parses a number, as accepted by GetInt and Decode.
+func ParseInt(value string) (int, error) {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%s', expected a number", value)
+	}
+	return i, nil
+}
+
+// ParseDuration parses a duration such as `30s` or `1h30m`, as accepted by GetDuration and Decode.
+func ParseDuration(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%s', expected a duration such as 30s or 5m", value)
+	}
+	return d, nil
+}
+
+// valueError returns the error of an invalid value, at the location of the value and prefixed with the path of its key.
+func valueError(path string, pos Position, err error) error {
+	return &Error{Pos: pos, Message: fmt.Sprintf("%s: %v", path, err)}
+}
+
+// GetBool is like GetFirst, but parses the value as a boolean.
+// An empty value is false. The error includes the path and location of the value.
+func (section *Section) GetBool(path string, defaultValue string) (bool, error) {
+	value, pos := section.GetFirstPos(path, defaultValue)
+	if value == "" {
+		return false, nil
+	}
+	b, err := ParseBool(value)
+	if err != nil {
+		return false, valueError(path, pos, err)
+	}
+	return b, nil
+}
+
+// GetInt is like GetFirst, but parses the value as a number.
+// An empty value is 0. The error includes the path and location of the value.
+func (section *Section) GetInt(path string, defaultValue string) (int, error) {
+	value, pos := section.GetFirstPos(path, defaultValue)
+	if value == "" {
+		return 0, nil
+	}
+	i, err := ParseInt(value)
+	if err != nil {
+		return 0, valueError(path, pos, err)
+	}
+	return i, nil
+}
+
+// GetDuration is like GetFirst, but parses the value as a duration such as `30s`.
+// An empty value is 0. The error includes the path and location of the value.
+func (section *Section) GetDuration(path string, defaultValue string) (time.Duration, error) {
+	value, pos := section.GetFirstPos(path, defaultValue)
+	if value == "" {
+		return 0, nil
+	}
+	d, err := ParseDuration(value)
+	if err != nil {
+		return 0, valueError(path, pos, err)
+	}
+	return d, nil
+}
+
+// GetList returns the whitespace separated items of every value at a path, e.g. `neovim git` for `package = neovim git`,
+// or the items of the default value if the key is not set.
+func (section *Section) GetList(path string, defaultValue string) []string {
+	values := section.GetAll(path)
+	if len(values) == 0 {
+		return strings.Fields(defaultValue)
+	}
+
+	items := []string{}
+	for _, value := range values {
+		items = append(items, strings.Fields(value)...)
+	}
+	return items
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Decode sets the fields of the struct v points to from the values of the section.
+// Fields are mapped with a `declarch` tag holding the key and options, e.g.
+//
+//	UserInstallation bool `declarch:"user_installation,default=false"`
+//
+// The `default=` option is the value used if the key is not set, and `required` fields must be set and not empty.
+// Fields can be strings, booleans, ints, durations, or string slices, which hold the items of every value like GetList.
+// Fields without a tag, and fields whose key is not set and has no default, are left unchanged. The error includes the path and location of the first invalid value.
+func (section *Section) Decode(v any) error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Pointer || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode: expected a pointer to a struct, got %T", v)
+	}
+	target = target.Elem()
+
+	for i := 0; i < target.NumField(); i++ {
+		field := target.Type().Field(i)
+		tag, ok := field.Tag.Lookup("declarch")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		key, options, _ := strings.Cut(tag, ",")
+		defaultValue, hasDefault, required := "", false, false
+		for _, option := range strings.Split(options, ",") {
+			if value, found := strings.CutPrefix(option, "default="); found {
+				defaultValue, hasDefault = value, true
+			} else if option == "required" {
+				required = true
+			} else if option != "" {
+				return fmt.Errorf("decode: unknown option '%s' for field %s", option, field.Name)
+			}
+		}
+
+		if required && section.GetFirst(key, "") == "" {
+			return &Error{Pos: section.Pos, Message: fmt.Sprintf("missing required '%s' field", key)}
+		} else if !hasDefault && len(section.GetAll(key)) == 0 {
+			continue
+		}
+
+		if err := section.decodeField(target.Field(i), key, defaultValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (section *Section) decodeField(field reflect.Value, key string, defaultValue string) error {
+	if field.Type() == durationType {
+		d, err := section.GetDuration(key, defaultValue)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(section.GetFirst(key, defaultValue))
+	case reflect.Bool:
+		b, err := section.GetBool(key, defaultValue)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		i, err := section.GetInt(key, defaultValue)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(i))
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("decode: unsupported type %s for key '%s'", field.Type(), key)
+		}
+		field.Set(reflect.ValueOf(section.GetList(key, defaultValue)))
+	default:
+		return fmt.Errorf("decode: unsupported type %s for key '%s'", field.Type(), key)
+	}
+	return nil
+}
\ No newline at end of file
@@ -0,0 +1,76 @@
+package parser_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+func TestSection_Getters(t *testing.T) {
+	section, err := parser.ParseContent("flatpak {\n  auto_install = true\n  jobs = 4\n  timeout = 1m30s\n  protected = htop btop\n  protected = git\n  strict = maybe\n}\n", "declarch.conf")
+	assert.NoError(t, err)
+
+	b, err := section.GetBool("flatpak/auto_install", "false")
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	b, err = section.GetBool("flatpak/disable", "true")
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	i, err := section.GetInt("flatpak/jobs", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, i)
+
+	d, err := section.GetDuration("flatpak/timeout", "")
+	assert.NoError(t, err)
+	assert.Equal(t, 90*time.Second, d)
+
+	assert.Equal(t, []string{"htop", "btop", "git"}, section.GetList("flatpak/protected", ""))
+	assert.Equal(t, []string{"grub", "efibootmgr"}, section.GetList("flatpak/bootloader", "grub efibootmgr"))
+
+	_, err = section.GetBool("flatpak/strict", "false")
+	assert.EqualError(t, err, "declarch.conf:7:3: flatpak/strict: invalid value 'maybe', expected true or false")
+	_, err = section.GetInt("flatpak/timeout", "")
+	assert.EqualError(t, err, "declarch.conf:4:3: flatpak/timeout: invalid value '1m30s', expected a number")
+}
+
+type decoded struct {
+	Name     string        `declarch:"name,required"`
+	Enabled  bool          `declarch:"enabled,default=true"`
+	Retries  int           `declarch:"retries,default=3"`
+	Timeout  time.Duration `declarch:"timeout"`
+	Groups   []string      `declarch:"group"`
+	Kept     string        `declarch:"kept"`
+	Untagged string
+}
+
+func TestSection_Decode(t *testing.T) {
+	section, err := parser.ParseContent("name = alice\nretries = 5\ntimeout = 10s\ngroup = wheel audio\ngroup = video\n", "declarch.conf")
+	assert.NoError(t, err)
+
+	v := decoded{Kept: "kept", Untagged: "untagged"}
+	assert.NoError(t, section.Decode(&v))
+	assert.Equal(t, decoded{
+		Name:     "alice",
+		Enabled:  true,
+		Retries:  5,
+		Timeout:  10 * time.Second,
+		Groups:   []string{"wheel", "audio", "video"},
+		Kept:     "kept",
+		Untagged: "untagged",
+	}, v)
+}
+
+func TestSection_DecodeErrors(t *testing.T) {
+	section, err := parser.ParseContent("user {\n  enabled = yes please\n}\nuser {\n  name = bob\n  retries = many\n}\n", "declarch.conf")
+	assert.NoError(t, err)
+
+	users := section.Sections["user"]
+	assert.EqualError(t, users[0].Decode(&decoded{}), "declarch.conf:1:1: missing required 'name' field")
+	assert.EqualError(t, users[1].Decode(&decoded{}), "declarch.conf:6:3: retries: invalid value 'many', expected a number")
+	assert.EqualError(t, users[1].Decode(decoded{}), "decode: expected a pointer to a struct, got parser_test.decoded")
+}
\ No newline at end of file
@@ -0,0 +1,541 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+type NodeType int
+
+const (
+	NodeBlank NodeType = iota
+	NodeComment
+	NodeSection
+	NodeValue
+	NodeVariable
+	NodeSource
+	// NodeCondition is an `if`, `else if` or `else` block, whose lines belong to the enclosing section if it is included.
+	NodeCondition
+	// NodeUnset is an `unset path` line, whose Value is the path.
+	NodeUnset
+)
+
+// Node is a line of a configuration file, or a section with the lines between its braces.
+// Unlike Section, nodes keep comments, blank lines, variable definitions and `source` lines,
+// so that a file can be edited without changing its formatting.
+type Node struct {
+	Type NodeType
+	// Key is the key of a value, the name of a variable (without `$`), the name of a section,
+	// the key of a `source` or `overlay` line (e.g. `source?`), or the keyword of a condition (`if`, `else if` or `else`).
+	Key string
+	// Value is the value as written in the file, before variables are expanded, or the condition of a conditional block.
+	Value         string
+	InlineComment string
+	Children      []*Node
+	// Raw is the original line, or empty if the node was added or modified and must be formatted.
+	Raw string
+	// ClosingRaw is the original line closing a section.
+	ClosingRaw string
+	Indent     string
+	Pos        Position
+	// End is the location of the `}` closing a section, or an invalid position if it is missing.
+	End Position
+}
+
+// Document is a configuration file that can be edited while keeping its comments and formatting.
+// Sourced files are not read, so only the contents of the file itself can be edited.
+type Document struct {
+	Root *Node
+	// File is the path of the file, used for positions and by WriteFile.
+	File string
+	// IndentUnit is the indentation of one level, detected from the file.
+	IndentUnit string
+
+	trailingNewline bool
+}
+
+// ReadDocument reads a configuration file as a Document.
+func ReadDocument(path string) (*Document, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDocument(string(content), path)
+}
+
+// ParseDocument parses a configuration as a Document.
+// If the configuration is malformed, the document is returned along with an ErrorList of every problem found.
+func ParseDocument(input string, file string) (*Document, error) {
+	var errs ErrorList
+
+	doc := &Document{
+		Root:            &Node{Type: NodeSection, Pos: Position{File: file, Line: 1, Column: 1}},
+		File:            file,
+		trailingNewline: input == "" || strings.HasSuffix(input, "\n"),
+	}
+
+	current := doc.Root
+	var stack []*Node
+
+	lines := strings.Split(strings.TrimSuffix(input, "\n"), "\n")
+	if input == "" {
+		lines = nil
+	}
+	for i, raw := range lines {
+		indent := raw[:len(raw)-len(strings.TrimLeft(raw, " \t"))]
+		pos := Position{File: file, Line: i + 1, Column: len(indent) + 1}
+		if doc.IndentUnit == "" && indent != "" && len(stack) == 1 {
+			doc.IndentUnit = indent
+		}
+
+		node := &Node{Raw: raw, Indent: indent, Pos: pos}
+
+		// The inline comment includes the whitespace before it, so that it is kept when the value changes
+		text := strings.TrimRight(formatLine(raw), " \t")
+		if text != "" {
+			node.InlineComment = strings.TrimSpace(raw)[len(text):]
+		}
+
+		keyword, condition, isCondition := conditionHeader(text)
+		switch {
+		case strings.TrimSpace(raw) == "":
+			node.Type = NodeBlank
+		case text == "":
+			node.Type = NodeComment
+		case text == "}":
+			if len(stack) == 0 {
+				errs.Add(pos, "unexpected '}' without an open section")
+				node.Type = NodeComment
+				break
+			}
+			current.ClosingRaw = raw
+			current.End = pos
+			current = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			continue
+		case isCondition:
+			if keyword != "if" {
+				// `} else {` closes the previous branch
+				if len(stack) == 0 || current.Type != NodeCondition {
+					errs.Add(pos, "unexpected '%s' without 'if'", keyword)
+					node.Type = NodeComment
+					break
+				}
+				current.End = pos
+				current = stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+			}
+
+			node.Type = NodeCondition
+			node.Key, node.Value = keyword, condition
+			current.Children = append(current.Children, node)
+			stack = append(stack, current)
+			current = node
+			continue
+		case strings.HasSuffix(text, "{"):
+			node.Type = NodeSection
+			node.Key = strings.TrimSpace(strings.TrimSuffix(text, "{"))
+			if node.Key == "" {
+				errs.Add(pos, "missing section name before '{'")
+			}
+			current.Children = append(current.Children, node)
+			stack = append(stack, current)
+			current = node
+			continue
+		default:
+			if path, ok := unsetPath(text); ok {
+				node.Type = NodeUnset
+				node.Key, node.Value = "unset", path
+				break
+			}
+
+			key, value, found := strings.Cut(text, "=")
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if !found {
+				errs.Add(pos, "expected 'key = value', 'name {' or '}', got '%s'", text)
+				node.Type = NodeComment
+				break
+			} else if key == "" {
+				errs.Add(pos, "missing key before '='")
+			}
+
+			node.Key, node.Value = key, value
+			switch {
+			case strings.HasPrefix(key, "$"):
+				node.Type = NodeVariable
+				node.Key = strings.TrimPrefix(key, "$")
+			case key == "source" || key == "source?" || key == "overlay" || key == "overlay?":
+				node.Type = NodeSource
+			default:
+				node.Type = NodeValue
+			}
+		}
+
+		current.Children = append(current.Children, node)
+	}
+
+	for _, section := range append(stack, current)[1:] {
+		errs.Add(section.Pos, "section is never closed, expected '}'")
+	}
+
+	if doc.IndentUnit == "" {
+		doc.IndentUnit = "  "
+	}
+
+	errs.Sort()
+	return doc, errs.Err()
+}
+
+// String returns the contents of the document.
+// Lines that were not changed are returned exactly as they were read.
+func (doc *Document) String() string {
+	var builder strings.Builder
+	doc.write(&builder, doc.Root, 0)
+
+	output := builder.String()
+	if !doc.trailingNewline {
+		output = strings.TrimSuffix(output, "\n")
+	}
+	return output
+}
+
+func (doc *Document) write(builder *strings.Builder, node *Node, depth int) {
+	for i, child := range node.Children {
+		if child.Raw != "" || child.Type == NodeBlank {
+			builder.WriteString(child.Raw + "\n")
+		} else {
+			indent := child.Indent
+			if indent == "" {
+				indent = strings.Repeat(doc.IndentUnit, depth)
+			}
+
+			switch child.Type {
+			case NodeSection, NodeCondition:
+				builder.WriteString(indent + child.header())
+			case NodeVariable:
+				builder.WriteString(indent + "$" + child.Key + " = " + child.Value)
+			case NodeUnset:
+				builder.WriteString(indent + "unset " + child.Value)
+			default:
+				builder.WriteString(indent + child.Key + " = " + child.Value)
+			}
+			builder.WriteString(child.InlineComment + "\n")
+		}
+
+		if child.Type == NodeSection || child.Type == NodeCondition {
+			doc.write(builder, child, depth+1)
+			if i+1 < len(node.Children) && node.Children[i+1].isElse() {
+				// The next branch closes this one
+				continue
+			} else if child.ClosingRaw != "" {
+				builder.WriteString(child.ClosingRaw + "\n")
+			} else if child.Indent != "" {
+				builder.WriteString(child.Indent + "}\n")
+			} else {
+				builder.WriteString(strings.Repeat(doc.IndentUnit, depth) + "}\n")
+			}
+		}
+	}
+}
+
+// header returns the line opening a section or a conditional block, without indentation.
+func (node *Node) header() string {
+	switch {
+	case node.Type != NodeCondition:
+		return node.Key + " {"
+	case node.Key == "else":
+		return "} else {"
+	case node.isElse():
+		return "} " + node.Key + " " + node.Value + " {"
+	}
+	return node.Key + " " + node.Value + " {"
+}
+
+// isElse reports whether the node is an `else if` or `else` branch, whose first line closes the previous branch.
+func (node *Node) isElse() bool {
+	return node.Type == NodeCondition && node.Key != "if"
+}
+
+// WriteFile writes the document to the file it was read from.
+func (doc *Document) WriteFile() error {
+	return os.WriteFile(doc.File, []byte(doc.String()), 0o644)
+}
+
+// Parse parses the document into a Section, reading sourced files.
+func (doc *Document) Parse() (*Section, error) {
+	return parse(doc.String(), doc.File, ParseOptions{})
+}
+
+// Set sets the value of a key, e.g. "packages/aur/helper".
+// Like GetFirst, only the first value of the key is changed, and sections are created if they don't exist.
+// Path elements can have an index to choose between repeated sections or values, e.g. "packages/flatpak/remote[1]/url".
+func (doc *Document) Set(path string, value string) error {
+	sections, key, index, err := doc.resolve(path, true)
+	if err != nil {
+		return err
+	}
+	section := sections[0]
+
+	values := section.find(NodeValue, key, false)
+	if index < 0 {
+		index = 0
+	}
+	if index < len(values) {
+		values[index].SetValue(EscapeValue(value))
+		return nil
+	} else if index > len(values) {
+		return fmt.Errorf("%s: index %d is out of range, there are %d values", path, index, len(values))
+	}
+
+	section.insert(&Node{Type: NodeValue, Key: key, Value: EscapeValue(value)})
+	return nil
+}
+
+// Append adds a value to a key after its existing values, e.g. "packages/pacman/package".
+// Sections are created if they don't exist.
+func (doc *Document) Append(path string, value string) error {
+	sections, key, index, err := doc.resolve(path, true)
+	if err != nil {
+		return err
+	} else if index >= 0 {
+		return fmt.Errorf("%s: cannot append to a single value", path)
+	}
+
+	sections[0].insert(&Node{Type: NodeValue, Key: key, Value: EscapeValue(value)})
+	return nil
+}
+
+// Remove removes the values or sections at a path, and returns how many were removed.
+// Without an index in the last path element, every value or section with that name is removed.
+// With an index, it refers to the values of the key if there are any, and otherwise to its sections.
+func (doc *Document) Remove(path string) (int, error) {
+	sections, key, index, err := doc.resolve(path, false)
+	if err != nil {
+		return 0, err
+	} else if index < 0 {
+		return removeChildren(sections, key, func(child *Node) bool { return true }), nil
+	}
+
+	targets := findAll(sections, NodeValue, key)
+	if len(targets) == 0 {
+		targets = findAll(sections, NodeSection, key)
+	}
+	if index >= len(targets) {
+		return 0, nil
+	}
+	return removeChildren(sections, key, func(child *Node) bool { return child == targets[index] }), nil
+}
+
+// RemoveFunc removes the values or sections at a path for which remove returns true, and returns how many were removed.
+// The last path element can't have an index.
+func (doc *Document) RemoveFunc(path string, remove func(node *Node) bool) (int, error) {
+	sections, key, index, err := doc.resolve(path, false)
+	if err != nil {
+		return 0, err
+	} else if index >= 0 {
+		return 0, fmt.Errorf("%s: the last path element can't have an index", path)
+	}
+
+	return removeChildren(sections, key, remove), nil
+}
+
+// Values returns the value nodes of a key, e.g. "packages/pacman/package".
+// Like Query, the values of every section on the path are returned, including those in `if` and `else` blocks.
+func (doc *Document) Values(path string) []*Node {
+	sections, key, index, err := doc.resolve(path, false)
+	if err != nil {
+		return nil
+	}
+
+	values := findAll(sections, NodeValue, key)
+	if index >= 0 {
+		if index >= len(values) {
+			return nil
+		}
+		return values[index : index+1]
+	}
+	return values
+}
+
+// Sections returns the section nodes at a path, e.g. "packages/flatpak/package".
+// Like Values, sections written several times and `if` and `else` blocks are searched too.
+func (doc *Document) Sections(path string) []*Node {
+	parents, name, index, err := doc.resolve(path, false)
+	if err != nil {
+		return nil
+	}
+
+	sections := findAll(parents, NodeSection, name)
+	if index >= 0 {
+		if index >= len(sections) {
+			return nil
+		}
+		return sections[index : index+1]
+	}
+	return sections
+}
+
+// resolve finds the sections containing the last element of a path,
+// and returns them with the key and index (or -1) of the last element.
+// Path elements without an index match every section with that name, including those in `if` and `else` blocks,
+// and path elements with an index count these sections in the order they are written.
+// If create is true, a single section is returned, and missing sections are created outside of conditional blocks:
+// a path element without an index refers to the first section written directly in its parent.
+// Otherwise, no sections are returned if there are none.
+func (doc *Document) resolve(path string, create bool) ([]*Node, string, int, error) {
+	names := strings.Split(strings.Trim(path, "/"), "/")
+
+	sections := []*Node{doc.Root}
+	target := doc.Root
+	for _, element := range names[:len(names)-1] {
+		name, index, err := splitIndex(element)
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("%s: %w", path, err)
+		}
+
+		matches := findAll(sections, NodeSection, name)
+		switch {
+		case index < 0 && create:
+			if direct := target.find(NodeSection, name, false); len(direct) > 0 {
+				target = direct[0]
+			} else {
+				newSection := &Node{Type: NodeSection, Key: name}
+				target.insert(newSection)
+				target = newSection
+				matches = append(matches, newSection)
+			}
+			sections = matches
+		case index < 0:
+			sections = matches
+		case index < len(matches):
+			sections = matches[index : index+1]
+			target = matches[index]
+		case create && index == len(matches):
+			newSection := &Node{Type: NodeSection, Key: name}
+			target.insert(newSection)
+			sections = []*Node{newSection}
+			target = newSection
+		case create:
+			return nil, "", 0, fmt.Errorf("%s: index %d is out of range, there are %d '%s' sections", path, index, len(matches), name)
+		default:
+			return nil, "", 0, nil
+		}
+		if len(sections) == 0 {
+			return nil, "", 0, nil
+		}
+	}
+
+	key, index, err := splitIndex(names[len(names)-1])
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("%s: %w", path, err)
+	}
+	if create {
+		return []*Node{target}, key, index, nil
+	}
+	return sections, key, index, nil
+}
+
+// splitIndex splits a path element like "remote[1]" into its name and index, or -1 if it has no index.
+func splitIndex(element string) (string, int, error) {
+	name, indexString, found := strings.Cut(element, "[")
+	if !found {
+		return element, -1, nil
+	}
+
+	index, err := strconv.Atoi(strings.TrimSuffix(indexString, "]"))
+	if err != nil || !strings.HasSuffix(indexString, "]") || index < 0 {
+		return "", 0, fmt.Errorf("invalid index in '%s'", element)
+	}
+	return name, index, nil
+}
+
+// find returns the values or sub-sections of a section with the given key, in the order they are written.
+// If nested is true, those in the `if` and `else` blocks of the section are included too, whichever branch applies.
+func (node *Node) find(nodeType NodeType, key string, nested bool) []*Node {
+	found := []*Node{}
+	for _, child := range node.Children {
+		if child.Type == nodeType && child.Key == key {
+			found = append(found, child)
+		} else if nested && child.Type == NodeCondition {
+			found = append(found, child.find(nodeType, key, true)...)
+		}
+	}
+	return found
+}
+
+// findAll returns the values or sub-sections with the given key of several sections, including those in their conditional blocks.
+func findAll(sections []*Node, nodeType NodeType, key string) []*Node {
+	found := []*Node{}
+	for _, section := range sections {
+		found = append(found, section.find(nodeType, key, true)...)
+	}
+	return found
+}
+
+// SetValue changes the value of a node as written in the file, keeping its indentation and inline comment.
+func (node *Node) SetValue(value string) {
+	node.Value = value
+	node.Raw = ""
+}
+
+// insert adds a value or section to a section.
+// Values are added after the last value with the same key, or else after the last value or section,
+// so that they don't end up in trailing comments. Sections are separated from previous lines with a blank line.
+func (node *Node) insert(child *Node) {
+	index := -1
+	for i, existing := range node.Children {
+		if existing.Type == NodeValue && existing.Key == child.Key && child.Type == NodeValue {
+			index = i + 1
+		}
+	}
+	if index == -1 {
+		index = 0
+		for i, existing := range node.Children {
+			if existing.Type != NodeBlank && existing.Type != NodeComment {
+				index = i + 1
+			}
+		}
+	}
+
+	if child.Type == NodeSection && index > 0 && node.Children[index-1].Type != NodeBlank {
+		node.Children = append(node.Children[:index], append([]*Node{{Type: NodeBlank}}, node.Children[index:]...)...)
+		index++
+	}
+	node.Children = append(node.Children[:index], append([]*Node{child}, node.Children[index:]...)...)
+}
+
+// removeChildren removes the values or sections of several sections with the given key for which remove returns true,
+// including those in their conditional blocks, and returns how many were removed.
+func removeChildren(sections []*Node, key string, remove func(child *Node) bool) int {
+	removed := 0
+	for _, section := range sections {
+		removed += section.removeChildren(key, remove)
+	}
+	return removed
+}
+
+// removeChildren removes the values or sections with the given key for which remove returns true,
+// including those in conditional blocks, and returns how many were removed.
+func (node *Node) removeChildren(key string, remove func(child *Node) bool) int {
+	removed := 0
+	children := []*Node{}
+	for i, child := range node.Children {
+		if (child.Type == NodeValue || child.Type == NodeSection) && child.Key == key {
+			if remove(child) {
+				removed++
+				if len(children) > 0 && children[len(children)-1].Type == NodeBlank &&
+					(i+1 == len(node.Children) || node.Children[i+1].Type == NodeBlank) {
+					children = children[:len(children)-1]
+				}
+				continue
+			}
+		} else if child.Type == NodeCondition {
+			removed += child.removeChildren(key, remove)
+		}
+		children = append(children, child)
+	}
+	node.Children = children
+	return removed
+}
\ No newline at end of file
@@ -0,0 +1,169 @@
+package parser_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+const documentInput = `# My configuration
+$user = ghost
+
+essentials {
+    kernel = linux # the default kernel
+    kernel = linux-lts
+}
+
+packages {
+    source? = packages.conf
+
+    pacman {
+        package = neovim
+        package = bash
+
+        # hook {
+        #   package = rustup
+        # }
+    }
+}
+`
+
+func TestDocument_RoundTrip(t *testing.T) {
+	doc, err := parser.ParseDocument(documentInput, "")
+	assert.NoError(t, err)
+	assert.Equal(t, documentInput, doc.String())
+	assert.Equal(t, "    ", doc.IndentUnit)
+
+	doc, err = parser.ParseDocument("kernel = linux", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "kernel = linux", doc.String())
+}
+
+func TestDocument_Set(t *testing.T) {
+	doc, _ := parser.ParseDocument(documentInput, "")
+
+	assert.NoError(t, doc.Set("essentials/kernel", "linux-zen"))
+	assert.NoError(t, doc.Set("essentials/kernel[1]", "linux-hardened"))
+	assert.NoError(t, doc.Set("packages/aur/helper", "yay"))
+	assert.NoError(t, doc.Set("config_parser/replace_comments", "false"))
+	assert.Error(t, doc.Set("essentials/kernel[5]", "linux"))
+
+	assert.Equal(t, `# My configuration
+$user = ghost
+
+essentials {
+    kernel = linux-zen # the default kernel
+    kernel = linux-hardened
+}
+
+packages {
+    source? = packages.conf
+
+    pacman {
+        package = neovim
+        package = bash
+
+        # hook {
+        #   package = rustup
+        # }
+    }
+
+    aur {
+        helper = yay
+    }
+}
+
+config_parser {
+    replace_comments = false
+}
+`, doc.String())
+}
+
+func TestDocument_Append(t *testing.T) {
+	doc, _ := parser.ParseDocument(documentInput, "")
+
+	assert.NoError(t, doc.Append("packages/pacman/package", "git"))
+	assert.NoError(t, doc.Append("packages/pacman/server", "https://example.com/$repo"))
+
+	values := []string{}
+	for _, node := range doc.Values("packages/pacman/package") {
+		values = append(values, node.Value)
+	}
+	assert.Equal(t, []string{"neovim", "bash", "git"}, values)
+	assert.Contains(t, doc.String(), "        package = bash\n        package = git\n        server = https://example.com/$$repo\n\n        # hook {")
+}
+
+func TestDocument_Remove(t *testing.T) {
+	doc, _ := parser.ParseDocument(documentInput, "")
+
+	removed, err := doc.Remove("essentials/kernel[1]")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	removed, err = doc.RemoveFunc("packages/pacman/package", func(node *parser.Node) bool { return node.Value == "bash" })
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	removed, err = doc.Remove("packages/aur/package")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, removed)
+
+	removed, err = doc.Remove("essentials")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, removed)
+
+	assert.Equal(t, `# My configuration
+$user = ghost
+
+packages {
+    source? = packages.conf
+
+    pacman {
+        package = neovim
+
+        # hook {
+        #   package = rustup
+        # }
+    }
+}
+`, doc.String())
+}
+
+func TestDocument_Errors(t *testing.T) {
+	doc, err := parser.ParseDocument("essentials {\n  stray line\n}\n}\npackages {\n", "declarch.conf")
+	assert.EqualError(t, err, "declarch.conf:2:3: expected 'key = value', 'name {' or '}', got 'stray line'\n"+
+		"declarch.conf:4:1: unexpected '}' without an open section\n"+
+		"declarch.conf:5:1: section is never closed, expected '}'")
+	assert.Equal(t, "essentials {\n  stray line\n}\n}\npackages {\n}\n", doc.String())
+}
+
+func TestDocument_RoundTripDefaultConfig(t *testing.T) {
+	doc, err := parser.ReadDocument("../default_declarch.conf")
+	assert.NoError(t, err)
+
+	content, _ := os.ReadFile("../default_declarch.conf")
+	assert.Equal(t, string(content), doc.String())
+}
+func TestDocument_Conditions(t *testing.T) {
+	doc, err := parser.ParseDocument("if tag(gaming) {\n  package = steam\n} else {\n  package = tlp\n}\n", "")
+	assert.NoError(t, err)
+
+	branches := doc.Root.Children
+	assert.Len(t, branches, 2)
+	assert.Equal(t, parser.NodeCondition, branches[0].Type)
+	assert.Equal(t, "if", branches[0].Key)
+	assert.Equal(t, "tag(gaming)", branches[0].Value)
+	assert.Equal(t, 3, branches[0].End.Line)
+	assert.Equal(t, "else", branches[1].Key)
+	assert.Equal(t, 5, branches[1].End.Line)
+
+	branches[1].Children[0].SetValue("powertop")
+	branches[1].Raw = ""
+	assert.Equal(t, "if tag(gaming) {\n  package = steam\n} else {\n  package = powertop\n}\n", doc.String())
+
+	_, err = parser.ParseDocument("} else {\n", "")
+	assert.EqualError(t, err, "1:1: unexpected 'else' without 'if'")
+}
\ No newline at end of file
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// FactNames lists the built-in variables, in the order they are documented.
+var FactNames = []string{"hostname", "arch", "cpu_vendor", "kernel_release", "machine_id"}
+
+// Facts returns the built-in read-only variables describing the machine, e.g. `$hostname` and `$arch`.
+// Facts that can't be determined are empty. They are computed once, on first use.
+var Facts = sync.OnceValue(func() map[string]string {
+	hostname, _ := os.Hostname()
+	return map[string]string{
+		"hostname":       hostname,
+		"arch":           arch(),
+		"cpu_vendor":     cpuVendor(),
+		"kernel_release": readTrimmed("/proc/sys/kernel/osrelease"),
+		"machine_id":     readTrimmed("/etc/machine-id"),
+	}
+})
+
+// arch returns the machine architecture as named by `uname -m` and pacman, e.g. `x86_64`.
+func arch() string {
+	switch runtime.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386":
+		return "i686"
+	case "riscv64":
+		return "riscv64"
+	}
+	return runtime.GOARCH
+}
+
+// cpuVendor returns `intel` or `amd` from /proc/cpuinfo, or the raw vendor ID for other vendors.
+func cpuVendor() string {
+	file, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(key) != "vendor_id" {
+			continue
+		}
+
+		switch value = strings.TrimSpace(value); value {
+		case "GenuineIntel":
+			return "intel"
+		case "AuthenticAMD":
+			return "amd"
+		}
+		return value
+	}
+	return ""
+}
+
+func readTrimmed(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
\ No newline at end of file
@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+)
+
+// FormatOptions controls how Format rewrites a document.
+type FormatOptions struct {
+	// Tagged reports whether the values of a key can have tags, e.g. "packages/pacman/package".
+	// The tags of these values are normalized to `name, +tag +!tag`.
+	Tagged func(path string) bool
+	// Sortable reports whether the order of the values of a key doesn't matter.
+	// If it returns true, consecutive values of the key and the items of each value are sorted.
+	Sortable func(path string) bool
+}
+
+// Format rewrites the document in the canonical style:
+// two spaces of indentation per level, `=` and inline comments aligned in consecutive values, single blank lines between blocks,
+// no blank lines at the start or end of sections, and normalized tags. Comments are kept.
+func (doc *Document) Format(opts FormatOptions) {
+	if opts.Tagged == nil {
+		opts.Tagged = func(string) bool { return false }
+	}
+	if opts.Sortable == nil {
+		opts.Sortable = func(string) bool { return false }
+	}
+
+	doc.IndentUnit = "  "
+	doc.trailingNewline = true
+	doc.format(doc.Root, "", 0, opts)
+}
+
+func (doc *Document) format(node *Node, path string, depth int, opts FormatOptions) {
+	indent := strings.Repeat(doc.IndentUnit, depth)
+
+	// Collapse blank lines, and remove them at the start and end of the section
+	children := []*Node{}
+	for _, child := range node.Children {
+		if child.Type == NodeBlank && (len(children) == 0 || children[len(children)-1].Type == NodeBlank) {
+			continue
+		}
+		children = append(children, child)
+	}
+	for len(children) > 0 && children[len(children)-1].Type == NodeBlank {
+		children = children[:len(children)-1]
+	}
+	node.Children = children
+
+	sortValues(node, path, opts.Sortable)
+
+	for start := 0; start < len(node.Children); {
+		// Values between blank lines and sections are aligned together
+		end := start
+		width := 0
+		for ; end < len(node.Children) && node.Children[end].Type != NodeBlank && node.Children[end].Type != NodeSection && node.Children[end].Type != NodeCondition; end++ {
+			if key := formattedKey(node.Children[end]); key != "" {
+				width = max(width, len(key))
+			}
+		}
+
+		lineWidth := 0
+		for _, child := range node.Children[start:end] {
+			child.Indent = indent
+			if child.Type == NodeComment {
+				child.Raw = indent + strings.TrimSpace(child.Raw)
+				continue
+			}
+
+			if child.Type == NodeValue && opts.Tagged(joinPath(path, child.Key)) {
+				child.Value = formatTags(child.Value)
+			}
+			if child.Type == NodeUnset {
+				child.Raw = indent + "unset " + strings.TrimSpace(child.Value)
+			} else {
+				child.Raw = indent + padRight(formattedKey(child), width) + " = " + child.Value
+			}
+			if child.InlineComment != "" {
+				lineWidth = max(lineWidth, len(child.Raw))
+			}
+		}
+
+		// Inline comments are aligned too
+		for _, child := range node.Children[start:end] {
+			if child.Type != NodeComment && child.InlineComment != "" {
+				child.Raw = padRight(child.Raw, lineWidth) + formatInlineComment(child.InlineComment)
+			}
+		}
+
+		if end < len(node.Children) {
+			child := node.Children[end]
+			child.Indent = indent
+			switch child.Type {
+			case NodeBlank:
+				child.Raw = ""
+			case NodeCondition:
+				// The lines of a conditional block belong to the enclosing section
+				child.Value = strings.TrimSpace(child.Value)
+				child.Raw = indent + child.header() + formatInlineComment(child.InlineComment)
+				child.ClosingRaw = indent + "}"
+				doc.format(child, path, depth+1, opts)
+			default:
+				child.Raw = indent + strings.Join(strings.Fields(child.Key), " ") + " {" + formatInlineComment(child.InlineComment)
+				child.ClosingRaw = indent + "}"
+				doc.format(child, joinPath(path, child.Key), depth+1, opts)
+			}
+			end++
+		}
+		start = end
+	}
+}
+
+// formattedKey returns the key of a value, variable or `source` line as written in the canonical style,
+// or an empty string for other nodes.
+func formattedKey(node *Node) string {
+	switch node.Type {
+	case NodeVariable:
+		return "$" + node.Key
+	case NodeValue, NodeSource:
+		return node.Key
+	}
+	return ""
+}
+
+func formatInlineComment(comment string) string {
+	if comment == "" {
+		return ""
+	}
+	return " " + strings.TrimSpace(comment)
+}
+
+// formatTags normalizes the tags of a value to `name, +tag +!tag`.
+func formatTags(value string) string {
+	names, tags, found := strings.Cut(value, ",")
+	names = strings.Join(strings.Fields(names), " ")
+	if !found {
+		return names
+	}
+
+	tagList := strings.Fields(strings.ReplaceAll(tags, ",", " "))
+	if len(tagList) == 0 {
+		return names
+	}
+	return names + ", " + strings.Join(tagList, " ")
+}
+
+// sortValues sorts the items of the sortable values of a section, and runs of consecutive sortable values with the same key.
+// Values with inline comments are sorted with their comments, and comments between values end a run.
+func sortValues(node *Node, path string, sortable func(path string) bool) {
+	for _, child := range node.Children {
+		if child.Type == NodeValue && sortable(joinPath(path, child.Key)) {
+			names, tags, found := strings.Cut(child.Value, ",")
+			items := strings.Fields(names)
+			slices.Sort(items)
+			child.Value = strings.Join(items, " ")
+			if found {
+				child.Value += "," + tags
+			}
+		}
+	}
+
+	for start := 0; start < len(node.Children); start++ {
+		first := node.Children[start]
+		if first.Type != NodeValue || !sortable(joinPath(path, first.Key)) {
+			continue
+		}
+
+		end := start + 1
+		for end < len(node.Children) && node.Children[end].Type == NodeValue && node.Children[end].Key == first.Key {
+			end++
+		}
+		slices.SortStableFunc(node.Children[start:end], func(a, b *Node) int {
+			return strings.Compare(a.Value, b.Value)
+		})
+		start = end - 1
+	}
+}
+
+func padRight(s string, width int) string {
+	if len(s) >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-len(s))
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "/" + key
+}
\ No newline at end of file
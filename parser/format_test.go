@@ -0,0 +1,73 @@
+package parser_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+func TestDocument_Format(t *testing.T) {
+	input := "# top comment\n$user=ghost\n\n\nessentials {\n\n\tkernel = linux    # default\n     kernel = linux-lts,+bare\n\tbootloader=grub efibootmgr\n}\n" +
+		"packages {\n    pacman {\n      package = zsh   bash,+bare   +!gui\n      package = neovim\n      hook {\n          run = echo \"a   b\"\n      }\n\n    }\n}"
+
+	doc, err := parser.ParseDocument(input, "")
+	assert.NoError(t, err)
+
+	doc.Format(parser.FormatOptions{
+		Tagged: func(path string) bool { return strings.HasSuffix(path, "/kernel") || strings.HasSuffix(path, "/package") },
+	})
+	assert.Equal(t, `# top comment
+$user = ghost
+
+essentials {
+  kernel     = linux # default
+  kernel     = linux-lts, +bare
+  bootloader = grub efibootmgr
+}
+packages {
+  pacman {
+    package = zsh bash, +bare +!gui
+    package = neovim
+    hook {
+      run = echo "a   b"
+    }
+  }
+}
+`, doc.String())
+}
+
+func TestDocument_FormatSort(t *testing.T) {
+	doc, err := parser.ParseDocument("kernel = linux-lts\nkernel = linux\n\npackage = zsh bash, +bare\npackage = neovim # editor\n# browsers\npackage = firefox\npackage = chromium\n", "")
+	assert.NoError(t, err)
+
+	doc.Format(parser.FormatOptions{
+		Sortable: func(path string) bool { return path == "package" },
+	})
+	assert.Equal(t, "kernel = linux-lts\nkernel = linux\n\npackage = bash zsh, +bare\npackage = neovim # editor\n# browsers\npackage = chromium\npackage = firefox\n", doc.String())
+}
+
+func TestDocument_FormatIdempotent(t *testing.T) {
+	doc, err := parser.ReadDocument("../default_declarch.conf")
+	assert.NoError(t, err)
+
+	doc.Format(parser.FormatOptions{})
+	formatted := doc.String()
+	doc.Format(parser.FormatOptions{})
+	assert.Equal(t, formatted, doc.String())
+}
+
+func TestDocument_FormatConditions(t *testing.T) {
+	input := "pacman {\nif   $hostname == \"a  b\"   {\npackage=steam,+gaming\n}   else if tag(work) {\n\npackage = slack\n} else {\n  package=tlp\n}\n}\n"
+
+	doc, err := parser.ParseDocument(input, "")
+	assert.NoError(t, err)
+	assert.Equal(t, input, doc.String())
+
+	doc.Format(parser.FormatOptions{
+		Tagged: func(path string) bool { return path == "pacman/package" },
+	})
+	assert.Equal(t, "pacman {\n  if $hostname == \"a  b\" {\n    package = steam, +gaming\n  } else if tag(work) {\n    package = slack\n  } else {\n    package = tlp\n  }\n}\n", doc.String())
+}
\ No newline at end of file
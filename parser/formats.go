@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseJSON reads a configuration written as JSON, e.g. by `declarch export --format json`.
+// Objects are sections, arrays are repeated keys or sections, and other values are converted to strings.
+// Values are used as is: there are no variables, sources or conditions to resolve.
+// An exported document is read from its `config` member.
+func ParseJSON(data []byte, file string) (*Section, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var tree map[string]any
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, &Error{Pos: Position{File: file}, Message: err.Error()}
+	}
+	return sectionFromTree(tree, file)
+}
+
+// ParseYAML reads a configuration written as YAML, like ParseJSON.
+func ParseYAML(data []byte, file string) (*Section, error) {
+	var tree map[string]any
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, &Error{Pos: Position{File: file}, Message: err.Error()}
+	}
+	return sectionFromTree(tree, file)
+}
+
+// ParseTOML reads a configuration written as TOML, like ParseJSON.
+func ParseTOML(data []byte, file string) (*Section, error) {
+	var tree map[string]any
+	if err := toml.Unmarshal(data, &tree); err != nil {
+		return nil, &Error{Pos: Position{File: file}, Message: err.Error()}
+	}
+	return sectionFromTree(tree, file)
+}
+
+// readers maps the extensions of the configuration files that aren't written in the configuration syntax to their readers.
+var readers = map[string]func(data []byte, file string) (*Section, error){
+	".json": ParseJSON,
+	".yaml": ParseYAML,
+	".yml":  ParseYAML,
+	".toml": ParseTOML,
+}
+
+// parseStructuredFile reads a configuration file with the reader for its extension, if there is one.
+func parseStructuredFile(path string) (*Section, bool, error) {
+	reader, ok := readers[filepath.Ext(path)]
+	if !ok {
+		return nil, false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, true, err
+	}
+	section, err := reader(data, path)
+	return section, true, err
+}
+
+// sectionFromTree converts a decoded document to a section.
+func sectionFromTree(tree map[string]any, file string) (*Section, error) {
+	if config, ok := tree["config"].(map[string]any); ok {
+		tree = config
+	}
+
+	section := newSection(Position{File: file})
+	errs := ErrorList{}
+	section.addTree(tree, "", file, &errs)
+	if err := errs.Err(); err != nil {
+		return nil, err
+	}
+	return section, nil
+}
+
+// addTree adds the members of an object to the section, sorted by key since the decoded objects are unordered.
+func (section *Section) addTree(tree map[string]any, path string, file string, errs *ErrorList) {
+	for _, key := range slices.Sorted(maps.Keys(tree)) {
+		keyPath := key
+		if path != "" {
+			keyPath = path + "/" + key
+		}
+
+		items, isArray := tree[key].([]any)
+		if tables, ok := tree[key].([]map[string]any); ok {
+			for _, table := range tables {
+				items = append(items, table)
+			}
+			isArray = true
+		}
+		if !isArray {
+			items = []any{tree[key]}
+		}
+
+		for _, item := range items {
+			if object, ok := item.(map[string]any); ok {
+				subSection := newSection(Position{File: file})
+				subSection.addTree(object, keyPath, file, errs)
+				section.AddSection(key, subSection)
+				continue
+			}
+
+			value, err := treeValue(item)
+			if err != nil {
+				errs.Add(Position{File: file}, "%s: %v", keyPath, err)
+				continue
+			}
+			section.AddValue(key, value, Position{File: file})
+		}
+	}
+}
+
+// treeValue converts a decoded value to the string it would be written as in the configuration syntax.
+func treeValue(item any) (string, error) {
+	switch v := item.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case json.Number:
+		return v.String(), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case nil:
+		return "", fmt.Errorf("null values are not supported")
+	case []any:
+		return "", fmt.Errorf("nested arrays are not supported")
+	}
+	return fmt.Sprint(item), nil
+}
\ No newline at end of file
@@ -0,0 +1,44 @@
+package parser_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+func TestParseFormats(t *testing.T) {
+	documents := map[string]string{
+		"declarch.json": `{"config": {"packages": {"pacman": {"color": true, "parallel_downloads": 5, "package": ["neovim git", "$HOME"],
+			"repository": [{"name": "core"}, {"name": "extra"}]}}}, "packages": {"pacman": ["neovim", "git"]}}`,
+		"declarch.yaml": "packages:\n  pacman:\n    color: true\n    parallel_downloads: 5\n    package:\n      - neovim git\n      - $HOME\n    repository:\n      - name: core\n      - name: extra\n",
+		"declarch.toml": "[packages.pacman]\ncolor = true\nparallel_downloads = 5\npackage = [\"neovim git\", \"$HOME\"]\n\n[[packages.pacman.repository]]\nname = \"core\"\n\n[[packages.pacman.repository]]\nname = \"extra\"\n",
+	}
+
+	dir := t.TempDir()
+	for name, content := range documents {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o644)
+
+		section, err := parser.ParseFile(path)
+		assert.NoError(t, err, name)
+		assert.Equal(t, "true", section.GetFirst("packages/pacman/color", ""), name)
+		assert.Equal(t, "5", section.GetFirst("packages/pacman/parallel_downloads", ""), name)
+		assert.Equal(t, []string{"neovim git", "$HOME"}, section.GetAll("packages/pacman/package"), name)
+		assert.Equal(t, []string{"core", "extra"}, section.GetAll("packages/pacman/repository/name"), name)
+		assert.Equal(t, "packages {\n  pacman {\n    color = true\n    package = neovim git\n    package = $$HOME\n    parallel_downloads = 5\n"+
+			"    repository {\n      name = core\n    }\n    repository {\n      name = extra\n    }\n  }\n}\n", section.Marshal(0), name)
+	}
+}
+
+func TestParseFormats_Errors(t *testing.T) {
+	_, err := parser.ParseJSON([]byte(`{"users": {"primary_user": null, "user": [{"group": [["wheel"]]}]}}`), "declarch.json")
+	assert.EqualError(t, err, "declarch.json: users/primary_user: null values are not supported\n"+
+		"declarch.json: users/user/group: nested arrays are not supported")
+
+	_, err = parser.ParseYAML([]byte("packages: [\n"), "declarch.yaml")
+	assert.Error(t, err)
+}
\ No newline at end of file
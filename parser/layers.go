@@ -0,0 +1,224 @@
+package parser
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Removal is a `-key = value` line, which removes the whitespace separated items of the value
+// from the values of the key inherited from the layers below, e.g. `-package = firefox`.
+type Removal struct {
+	Key   string
+	Value string
+	Pos   Position
+}
+
+// Unset is an `unset path` line, which removes the keys or sections a query selects
+// from the layers below, e.g. `unset users/primary_user` or `unset packages/pacman/repository[name=testing]`.
+type Unset struct {
+	Path string
+	Pos  Position
+}
+
+// unsetPath returns the path of an `unset path` line.
+// Lines like `unset = value` are values, but `=` can be used in the filters of the path.
+func unsetPath(text string) (string, bool) {
+	path, found := cutKeyword(text, "unset")
+	if !found || path == "" {
+		return "", false
+	}
+	if i := strings.Index(text, "="); i != -1 && !strings.Contains(text[:i], "[") {
+		return "", false
+	}
+	return path, true
+}
+
+// ParseLayers parses configuration files and layers them in order, each overriding the previous ones like `overlay` does.
+func ParseLayers(paths []string, opts ParseOptions) (*Section, error) {
+	var errs ErrorList
+	var section *Section
+	for _, path := range paths {
+		layer, err := ParseFileWith(path, opts)
+		if err != nil {
+			var layerErrs ErrorList
+			if !errors.As(err, &layerErrs) {
+				return nil, err
+			}
+			errs = append(errs, layerErrs...)
+		}
+
+		if section == nil {
+			section = layer
+		} else if layer != nil {
+			section.Overlay(layer, opts)
+		}
+	}
+
+	errs.Sort()
+	return section, errs.Err()
+}
+
+// overlayFiles layers the files an `overlay` line refers to over the section, in lexical order for glob patterns.
+// Paths are resolved like sourced files, and if optional is true (`overlay? = path`), missing files are skipped.
+func overlayFiles(section *Section, overlayPath string, optional bool, file string, pos Position, chain []string, opts ParseOptions, errs *ErrorList) {
+	if file != "" && !filepath.IsAbs(overlayPath) {
+		overlayPath = filepath.Join(filepath.Dir(file), overlayPath)
+	}
+
+	paths := []string{overlayPath}
+	if strings.ContainsAny(overlayPath, "*?[") {
+		matches, err := filepath.Glob(overlayPath)
+		if err != nil {
+			errs.Add(pos, "invalid overlay pattern %s: %v", overlayPath, err)
+			return
+		}
+		if len(matches) == 0 && !optional {
+			errs.Add(pos, "no files match overlay pattern %s", overlayPath)
+			return
+		}
+		paths = matches
+	}
+
+	for _, path := range paths {
+		absPath, _ := filepath.Abs(path)
+		if slices.Contains(chain, absPath) {
+			errs.Add(pos, "overlay cycle: %s", strings.Join(append(slices.Clone(chain), absPath), " -> "))
+			continue
+		}
+
+		layer, err := parseLayerFile(path, append(slices.Clip(chain), absPath), opts, errs)
+		if err != nil {
+			if optional && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			errs.Add(pos, "cannot read overlay %s: %v", path, errors.Unwrap(err))
+			continue
+		}
+		section.Overlay(layer, opts)
+	}
+}
+
+// Overlay layers another configuration over the section, which is modified:
+//   - the `unset` and `-key = value` lines of the layer remove what they select from the section,
+//   - the values of the layer replace those of the same keys in the section, unless the key is repeated,
+//   - the sections of the layer are merged into those with the same name, unless the section is repeated.
+//
+// opts.Repeated tells which keys and sections are repeated, and every key and section is if it is nil,
+// so that the layer is appended like a sourced file.
+func (section *Section) Overlay(layer *Section, opts ParseOptions) {
+	overlaySections([]*Section{section}, layer, "", opts)
+}
+
+// overlaySections layers a section over the sections at the same path below it, into which its entries are added.
+func overlaySections(bases []*Section, layer *Section, path string, opts ParseOptions) {
+	target := bases[0]
+
+	for _, unset := range layer.Unsets {
+		if q, err := ParseQuery(unset.Path); err == nil {
+			for _, base := range bases {
+				q.unset(base)
+			}
+		}
+	}
+	for _, removal := range layer.Removals {
+		for _, base := range bases {
+			base.removeItems(removal.Key, strings.Fields(removal.Value))
+		}
+	}
+	target.Unsets = append(target.Unsets, layer.Unsets...)
+	target.Removals = append(target.Removals, layer.Removals...)
+
+	for name, value := range layer.Variables {
+		target.Variables[name] = value
+		target.VariablePositions[name] = layer.VariablePositions[name]
+	}
+
+	overridden := map[string]bool{}
+	for _, entry := range layer.Entries {
+		entryPath := joinPath(path, entry.Key)
+		if !entry.IsSection {
+			if !overridden[entry.Key] && !opts.repeated(entryPath) {
+				for _, base := range bases {
+					base.removeEntries(entry.Key, false, func(int) bool { return true })
+				}
+				overridden[entry.Key] = true
+			}
+			target.AddValue(entry.Key, layer.Values[entry.Key][entry.Index], layer.ValuePos(entry.Key, entry.Index))
+			continue
+		}
+
+		subSection := layer.Sections[entry.Key][entry.Index]
+		subBases := []*Section{}
+		for _, base := range bases {
+			subBases = append(subBases, base.Sections[entry.Key]...)
+		}
+		if opts.repeated(entryPath) || len(subBases) == 0 {
+			target.AddSection(entry.Key, subSection)
+		} else {
+			overlaySections(subBases, subSection, entryPath, opts)
+		}
+	}
+}
+
+// removeItems removes whitespace separated items from the values of a key, keeping their tags,
+// and removes the values that have no items left.
+func (section *Section) removeItems(key string, items []string) {
+	for i, value := range section.Values[key] {
+		valuePart, tags, tagged := strings.Cut(value, ",")
+		kept := slices.DeleteFunc(strings.Fields(valuePart), func(item string) bool {
+			return slices.Contains(items, item)
+		})
+		value = strings.Join(kept, " ")
+		if tagged {
+			value += "," + tags
+		}
+		section.Values[key][i] = value
+	}
+
+	section.removeEntries(key, false, func(i int) bool {
+		valuePart, _, _ := strings.Cut(section.Values[key][i], ",")
+		return strings.TrimSpace(valuePart) == ""
+	})
+}
+
+// removeEntries removes the values, or sub-sections if isSection is true, of a key for which remove returns true,
+// given their index, keeping Entries in sync.
+func (section *Section) removeEntries(key string, isSection bool, remove func(index int) bool) {
+	entries := []Entry{}
+	values, positions, sections := []string{}, []Position{}, []*Section{}
+	for _, entry := range section.Entries {
+		if entry.Key != key || entry.IsSection != isSection {
+			entries = append(entries, entry)
+			continue
+		} else if remove(entry.Index) {
+			continue
+		}
+
+		if isSection {
+			sections = append(sections, section.Sections[key][entry.Index])
+			entry.Index = len(sections) - 1
+		} else {
+			values = append(values, section.Values[key][entry.Index])
+			positions = append(positions, section.ValuePos(key, entry.Index))
+			entry.Index = len(values) - 1
+		}
+		entries = append(entries, entry)
+	}
+	section.Entries = entries
+
+	switch {
+	case isSection && len(sections) == 0:
+		delete(section.Sections, key)
+	case isSection:
+		section.Sections[key] = sections
+	case len(values) == 0:
+		delete(section.Values, key)
+		delete(section.ValuePositions, key)
+	default:
+		section.Values[key] = values
+		section.ValuePositions[key] = positions
+	}
+}
\ No newline at end of file
@@ -0,0 +1,71 @@
+package parser_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+var layerOptions = parser.ParseOptions{Repeated: func(path string) bool {
+	return path == "pacman/package" || path == "pacman/repository"
+}}
+
+func TestParseLayers(t *testing.T) {
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "base.conf")
+	hostPath := filepath.Join(dir, "host.conf")
+	os.WriteFile(basePath, []byte("user = alice\npacman {\n  color = true\n  package = firefox neovim, +desktop\n  package = htop\n"+
+		"  repository {\n    name = core\n  }\n}\n"), 0o644)
+	os.WriteFile(hostPath, []byte("pacman {\n  color = false\n  -package = firefox htop\n  package = git\n"+
+		"  repository {\n    name = extra\n  }\n}\nunset user\n"), 0o644)
+
+	section, err := parser.ParseLayers([]string{basePath, hostPath}, layerOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, "pacman {\n  package = neovim, +desktop\n  repository {\n    name = core\n  }\n"+
+		"  color = false\n  package = git\n  repository {\n    name = extra\n  }\n}\n", section.Marshal(0))
+	assert.Equal(t, []parser.Unset{{Path: "user", Pos: parser.Position{File: hostPath, Line: 9, Column: 1}}}, section.Unsets)
+	assert.Equal(t, "package", section.Sections["pacman"][0].Removals[0].Key)
+}
+
+func TestParse_Overlay(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "declarch.conf"), []byte("pacman {\n  package = vim git\n"+
+		"  repository {\n    name = testing\n  }\n  repository {\n    name = core\n  }\n}\n"+
+		"overlay = host.conf\noverlay? = missing.conf\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "host.conf"), []byte("pacman {\n  -package = vim\n}\nunset pacman/repository[name=testing]\n"), 0o644)
+
+	section, err := parser.ParseFileWith(filepath.Join(dir, "declarch.conf"), layerOptions)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"git"}, section.GetAll("pacman/package"))
+	assert.Equal(t, []string{"core"}, section.GetAll("pacman/repository/name"))
+}
+
+func TestParse_OverlayErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "declarch.conf")
+	os.WriteFile(path, []byte("overlay = declarch.conf\noverlay = missing.conf\noverlay? = hosts.d/*.conf\noverlay = hosts.d/*.conf\npacman {\n  overlay = host.conf\n  - = vim\n}\nunset pacman[\n"), 0o644)
+
+	_, err := parser.ParseFile(path)
+	assert.EqualError(t, err, path+":1:1: overlay cycle: "+path+" -> "+path+"\n"+
+		path+":2:1: cannot read overlay "+filepath.Join(dir, "missing.conf")+": no such file or directory\n"+
+		path+":4:1: no files match overlay pattern "+filepath.Join(dir, "hosts.d", "*.conf")+"\n"+
+		path+":6:3: 'overlay' must be at the top level of the file\n"+
+		path+":7:3: missing key after '-'\n"+
+		path+":9:1: invalid path in 'unset pacman[': missing ']' for the '[' at column 7")
+}
+
+func TestDocument_Unset(t *testing.T) {
+	input := "pacman {\n    unset   repository[name=testing]\n  -package=vim\n}\n"
+
+	doc, err := parser.ParseDocument(input, "")
+	assert.NoError(t, err)
+	assert.Equal(t, input, doc.String())
+	assert.Equal(t, parser.NodeUnset, doc.Root.Children[0].Children[0].Type)
+
+	doc.Format(parser.FormatOptions{})
+	assert.Equal(t, "pacman {\n  unset repository[name=testing]\n  -package = vim\n}\n", doc.String())
+}
\ No newline at end of file
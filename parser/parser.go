@@ -1,8 +1,12 @@
 package parser
 
 import (
+	"errors"
+	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
@@ -10,6 +14,79 @@ type Section struct {
 	Values    map[string][]string
 	Sections  map[string][]*Section
 	Variables map[string]string
+
+	// Pos is the location of the section's header, or of the start of the file for the global section.
+	Pos Position
+	// ValuePositions holds the location of each value, in the same order as Values.
+	ValuePositions map[string][]Position
+	// VariablePositions holds the location of each variable definition.
+	VariablePositions map[string]Position
+	// Entries lists the values and sub-sections in declaration order.
+	Entries []Entry
+	// Removals and Unsets hold the `-key = value` and `unset path` lines,
+	// which remove values and sections inherited from the layers below. See Overlay.
+	Removals []Removal
+	Unsets   []Unset
+}
+
+// Entry refers to a value or sub-section of a section.
+type Entry struct {
+	Key string
+	// Index is the index of the value in Values[Key], or of the sub-section in Sections[Key].
+	Index     int
+	IsSection bool
+}
+
+func newSection(pos Position) *Section {
+	return &Section{
+		Values:            make(map[string][]string),
+		Sections:          make(map[string][]*Section),
+		Variables:         make(map[string]string),
+		Pos:               pos,
+		ValuePositions:    make(map[string][]Position),
+		VariablePositions: make(map[string]Position),
+	}
+}
+
+// ValuePos returns the location of the value of a key at the given index,
+// or an invalid position if the value was not parsed from a file.
+func (section *Section) ValuePos(key string, index int) Position {
+	positions := section.ValuePositions[key]
+	if index < 0 || index >= len(positions) {
+		return Position{}
+	}
+	return positions[index]
+}
+
+// AddValue appends a value to a key, keeping the declaration order.
+func (section *Section) AddValue(key, value string, pos Position) {
+	section.Entries = append(section.Entries, Entry{Key: key, Index: len(section.Values[key])})
+	section.Values[key] = append(section.Values[key], value)
+	section.ValuePositions[key] = append(section.ValuePositions[key], pos)
+}
+
+// AddSection appends a sub-section, keeping the declaration order.
+func (section *Section) AddSection(name string, subSection *Section) {
+	section.Entries = append(section.Entries, Entry{Key: name, Index: len(section.Sections[name]), IsSection: true})
+	section.Sections[name] = append(section.Sections[name], subSection)
+}
+
+// line is a line of a configuration file, with comments and surrounding whitespace removed.
+type line struct {
+	text string
+	pos  Position
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isAlphaNum(s[i]) {
+			return false
+		}
+	}
+	return true
 }
 
 func isAlphaNum(c byte) bool {
@@ -34,215 +111,578 @@ func formatLine(line string) string {
 	return line
 }
 
-func getLines(input string) ([]string, error) {
-	lines := strings.Split(input, "\n")
-	var processedLines []string
+// getLines splits the input into lines, replacing `source` lines with the lines of the sourced files.
+// chain holds the absolute paths of the files currently being read, starting with the main configuration, to detect cycles.
+// Sourced files that can't be read are added to errs.
+func getLines(input string, file string, chain []string, errs *ErrorList) []line {
+	var processedLines []line
 
-	for _, line := range lines {
-		line = formatLine(line)
-		if line == "" {
+	for i, text := range strings.Split(input, "\n") {
+		pos := Position{File: file, Line: i + 1, Column: len(text) - len(strings.TrimLeft(text, " \t")) + 1}
+
+		text = formatLine(text)
+		if text == "" {
 			continue
 		}
 
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 && strings.TrimSpace(parts[0]) == "source" {
-			sourcePath := strings.TrimSpace(parts[1])
-			sourcePath, _ = filepath.Abs(sourcePath)
+		parts := strings.SplitN(text, "=", 2)
+		if key := strings.TrimSpace(parts[0]); len(parts) == 2 && (key == "source" || key == "source?") {
+			processedLines = append(processedLines, sourceLines(strings.TrimSpace(parts[1]), key == "source?", file, pos, chain, errs)...)
+		} else {
+			processedLines = append(processedLines, line{text: text, pos: pos})
+		}
+	}
+
+	return processedLines
+}
 
-			sourcedContent, err := os.ReadFile(sourcePath)
-			if err != nil {
-				return nil, err
-			}
+// sourceLines returns the lines of the files a `source` line refers to.
+// Relative paths are resolved from the directory of the including file, and glob patterns are expanded in lexical order.
+// If optional is true (`source? = path`), missing files are skipped.
+func sourceLines(sourcePath string, optional bool, file string, pos Position, chain []string, errs *ErrorList) []line {
+	if file != "" && !filepath.IsAbs(sourcePath) {
+		sourcePath = filepath.Join(filepath.Dir(file), sourcePath)
+	}
 
-			sourcedLines, err := getLines(string(sourcedContent))
-			if err != nil {
-				return nil, err
-			}
-			processedLines = append(processedLines, sourcedLines...)
-		} else {
-			processedLines = append(processedLines, line)
+	paths := []string{sourcePath}
+	if strings.ContainsAny(sourcePath, "*?[") {
+		matches, err := filepath.Glob(sourcePath)
+		if err != nil {
+			errs.Add(pos, "invalid source pattern %s: %v", sourcePath, err)
+			return nil
+		}
+		if len(matches) == 0 && !optional {
+			errs.Add(pos, "no files match source pattern %s", sourcePath)
+			return nil
 		}
+		paths = matches
 	}
 
-	return processedLines, nil
+	var lines []line
+	for _, path := range paths {
+		absPath, _ := filepath.Abs(path)
+		if slices.Contains(chain, absPath) {
+			errs.Add(pos, "source cycle: %s", strings.Join(append(slices.Clone(chain), absPath), " -> "))
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			if optional && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			errs.Add(pos, "cannot read sourced file %s: %v", path, errors.Unwrap(err))
+			continue
+		}
+
+		lines = append(lines, getLines(string(content), path, append(slices.Clip(chain), absPath), errs)...)
+	}
+	return lines
 }
 
+// ParseFile parses a configuration file.
+// Files ending in .json, .yaml, .yml or .toml are read with ParseJSON, ParseYAML or ParseTOML.
 func ParseFile(path string) (*Section, error) {
+	return ParseFileWith(path, ParseOptions{})
+}
+
+// ParseFileWith is like ParseFile, but evaluates `if` conditions with the given options, e.g. the selected tags.
+func ParseFileWith(path string, opts ParseOptions) (*Section, error) {
+	if section, ok, err := parseStructuredFile(path); ok {
+		return section, err
+	}
+
 	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
-	return Parse(string(content))
+	return parse(string(content), path, opts)
+}
+
+// ParseContent parses the content of a configuration file, resolving its sources relative to the file.
+// It is used for files that are not saved yet, e.g. by editors.
+func ParseContent(input string, file string) (*Section, error) {
+	return ParseContentWith(input, file, ParseOptions{})
 }
 
+// ParseContentWith is like ParseContent, but with the given options, like ParseFileWith.
+func ParseContentWith(input string, file string, opts ParseOptions) (*Section, error) {
+	return parse(input, file, opts)
+}
+
+// Parse parses a configuration.
+// If the configuration is malformed, the section is returned along with an ErrorList of every problem found.
 func Parse(input string) (*Section, error) {
-	globalSection := &Section{
-		Values:    make(map[string][]string),
-		Sections:  make(map[string][]*Section),
-		Variables: make(map[string]string),
+	return parse(input, "", ParseOptions{})
+}
+
+func parse(input string, file string, opts ParseOptions) (*Section, error) {
+	var errs ErrorList
+
+	var chain []string
+	if file != "" {
+		absPath, _ := filepath.Abs(file)
+		chain = []string{absPath}
 	}
 
-	var currentSection *Section
-	var sectionStack []*Section
+	section := parseLayer(input, file, chain, opts, &errs)
+	errs.Sort()
+	return section, errs.Err()
+}
 
-	lines, err := getLines(input)
+// parseLayerFile parses a file layered over another one with `overlay`.
+// Errors in the file are added to errs, and the returned error is only set if the file can't be read.
+func parseLayerFile(path string, chain []string, opts ParseOptions, errs *ErrorList) (*Section, error) {
+	if section, ok, err := parseStructuredFile(path); ok {
+		var e *Error
+		if errors.As(err, &e) {
+			*errs = append(*errs, e)
+			return nil, nil
+		}
+		return section, err
+	}
+
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	for _, line := range lines {
-		line = formatLine(line)
-		if line == "" {
-			continue
+	return parseLayer(string(content), path, chain, opts, errs), nil
+}
+
+// parseLayer parses a configuration file and the files it sources, then layers its overlays over it.
+// chain holds the absolute paths of the files being read, starting with this one, to detect cycles.
+func parseLayer(input string, file string, chain []string, opts ParseOptions, errs *ErrorList) *Section {
+	globalSection := newSection(Position{File: file, Line: 1, Column: 1})
+
+	var currentSection *Section
+	var sectionStack []*Section
+
+	var ifStack []ifBlock
+	// skipDepth is the number of sections opened in a branch that is being skipped, or -1 if no branch is skipped.
+	skipDepth := -1
+
+	for _, line := range getLines(input, file, chain, errs) {
+		section := currentSection
+		if section == nil {
+			section = globalSection
 		}
 
-		if strings.HasPrefix(line, "$") {
-			// Variable
-			parts := strings.SplitN(line, "=", 2)
-			if len(parts) == 2 {
-				varName := strings.TrimPrefix(strings.TrimSpace(parts[0]), "$")
-				varValue := strings.TrimSpace(parts[1])
-				if currentSection == nil {
-					globalSection.Variables[varName] = varValue
-				} else {
-					currentSection.Variables[varName] = varValue
+		// Conditions can use the variables defined before them in the open sections
+		evaluate := func(condition string) bool {
+			s := (*scope)(nil)
+			for _, open := range append(slices.Clone(sectionStack), currentSection) {
+				if open == nil {
+					open = globalSection
 				}
+				s = newScope(s, open)
 			}
-		} else if strings.HasSuffix(line, "{") {
-			// New section
-			sectionName := strings.TrimSpace(strings.TrimSuffix(line, "{"))
-			newSection := &Section{
-				Values:    make(map[string][]string),
-				Sections:  make(map[string][]*Section),
-				Variables: make(map[string]string),
+
+			var ignored ErrorList
+			result, err := evaluateCondition(condition, func(operand string) string {
+				return expandVariables(operand, func(name string) (string, bool) {
+					return s.lookup(name, &ignored)
+				}, line.pos, errs)
+			}, opts)
+			if err != nil {
+				errs.Add(line.pos, "invalid condition '%s': %v", condition, err)
 			}
+			return result
+		}
+
+		keyword, condition, isCondition := conditionHeader(line.text)
+		if skipDepth >= 0 {
+			// Skip the lines of a branch that isn't included, until its `}` or the next branch
+			switch {
+			case line.text == "}" && skipDepth == 0:
+				ifStack = ifStack[:len(ifStack)-1]
+				skipDepth = -1
+			case line.text == "}":
+				skipDepth--
+			case isCondition && keyword != "if" && skipDepth == 0:
+				block := &ifStack[len(ifStack)-1]
+				if !block.taken && (keyword == "else" || evaluate(condition)) {
+					block.taken = true
+					skipDepth = -1
+				}
+			case strings.HasSuffix(line.text, "{") && !strings.HasPrefix(line.text, "}"):
+				skipDepth++
+			}
+			continue
+		}
 
-			if currentSection == nil {
-				globalSection.Sections[sectionName] = append(globalSection.Sections[sectionName], newSection)
+		if isCondition {
+			if keyword == "if" {
+				taken := evaluate(condition)
+				ifStack = append(ifStack, ifBlock{pos: line.pos, depth: len(sectionStack), taken: taken})
+				if !taken {
+					skipDepth = 0
+				}
+			} else if len(ifStack) == 0 || ifStack[len(ifStack)-1].depth != len(sectionStack) {
+				errs.Add(line.pos, "unexpected '%s' without 'if'", keyword)
 			} else {
-				currentSection.Sections[sectionName] = append(currentSection.Sections[sectionName], newSection)
+				// The previous branch was included, so the others are skipped
+				skipDepth = 0
+			}
+			continue
+		}
+
+		if line.text == "}" && len(ifStack) > 0 && ifStack[len(ifStack)-1].depth == len(sectionStack) {
+			ifStack = ifStack[:len(ifStack)-1]
+			continue
+		}
+
+		if strings.HasPrefix(line.text, "$") {
+			// Variable
+			parts := strings.SplitN(line.text, "=", 2)
+			if len(parts) != 2 {
+				errs.Add(line.pos, "expected '=' after variable %s", line.text)
+				continue
 			}
+
+			varName := strings.TrimPrefix(strings.TrimSpace(parts[0]), "$")
+			if !isIdentifier(varName) {
+				errs.Add(line.pos, "invalid variable name $%s", varName)
+				continue
+			} else if _, ok := Facts()[varName]; ok {
+				errs.Add(line.pos, "cannot redefine built-in variable $%s", varName)
+				continue
+			}
+			section.Variables[varName] = strings.TrimSpace(parts[1])
+			section.VariablePositions[varName] = line.pos
+		} else if strings.HasSuffix(line.text, "{") {
+			// New section
+			sectionName := strings.TrimSpace(strings.TrimSuffix(line.text, "{"))
+			if sectionName == "" {
+				errs.Add(line.pos, "missing section name before '{'")
+			}
+			newSection := newSection(line.pos)
+
+			section.AddSection(sectionName, newSection)
 			sectionStack = append(sectionStack, currentSection)
 			currentSection = newSection
-		} else if line == "}" {
+		} else if line.text == "}" {
 			// End of section
-			if len(sectionStack) > 0 {
-				currentSection = sectionStack[len(sectionStack)-1]
-				sectionStack = sectionStack[:len(sectionStack)-1]
+			if len(sectionStack) == 0 {
+				errs.Add(line.pos, "unexpected '}' without an open section")
+				continue
+			}
+			currentSection = sectionStack[len(sectionStack)-1]
+			sectionStack = sectionStack[:len(sectionStack)-1]
+		} else if path, ok := unsetPath(line.text); ok {
+			// Removal of inherited keys and sections
+			if _, err := ParseQuery(path); err != nil {
+				errs.Add(line.pos, "invalid path in 'unset %s': %v", path, err)
+				continue
 			}
+			section.Unsets = append(section.Unsets, Unset{Path: path, Pos: line.pos})
 		} else {
 			// Key-value pair
-			parts := strings.SplitN(line, "=", 2)
-			if len(parts) == 2 {
-				key := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
-				if currentSection == nil {
-					globalSection.Values[key] = append(globalSection.Values[key], value)
-				} else {
-					currentSection.Values[key] = append(currentSection.Values[key], value)
+			parts := strings.SplitN(line.text, "=", 2)
+			key := strings.TrimSpace(parts[0])
+			if len(parts) != 2 {
+				errs.Add(line.pos, "expected 'key = value', 'name {' or '}', got '%s'", line.text)
+				continue
+			} else if key == "" {
+				errs.Add(line.pos, "missing key before '='")
+				continue
+			}
+
+			if removedKey, found := strings.CutPrefix(key, "-"); found {
+				if removedKey == "" {
+					errs.Add(line.pos, "missing key after '-'")
+					continue
 				}
+				section.Removals = append(section.Removals, Removal{Key: removedKey, Value: strings.TrimSpace(parts[1]), Pos: line.pos})
+				continue
+			} else if (key == "overlay" || key == "overlay?") && section != globalSection {
+				errs.Add(line.pos, "'%s' must be at the top level of the file", key)
+				continue
 			}
+
+			section.AddValue(key, strings.TrimSpace(parts[1]), line.pos)
+		}
+	}
+
+	// Report sections and blocks that are never closed
+	for _, block := range ifStack {
+		errs.Add(block.pos, "if block is never closed, expected '}'")
+	}
+	if len(sectionStack) > 0 {
+		for _, section := range append(sectionStack[1:], currentSection) {
+			errs.Add(section.Pos, "section is never closed, expected '}'")
 		}
 	}
 
-	globalSection.substituteVariables(make(map[string]string))
+	globalSection.substituteVariables(nil, errs)
 
-	return globalSection, nil
+	// Overlays are layered in order, once the file is fully parsed
+	type overlay struct {
+		path     string
+		optional bool
+		pos      Position
+	}
+	overlays := []overlay{}
+	for _, entry := range globalSection.Entries {
+		if !entry.IsSection && (entry.Key == "overlay" || entry.Key == "overlay?") {
+			overlays = append(overlays, overlay{globalSection.Values[entry.Key][entry.Index], entry.Key == "overlay?", globalSection.ValuePos(entry.Key, entry.Index)})
+		}
+	}
+	for _, key := range []string{"overlay", "overlay?"} {
+		globalSection.removeEntries(key, false, func(int) bool { return true })
+	}
+	for _, o := range overlays {
+		overlayFiles(globalSection, o.path, o.optional, file, o.pos, chain, opts, errs)
+	}
+
+	return globalSection
+}
+
+// scope holds the variables visible in a section: its own, those of its parent sections, and the facts.
+type scope struct {
+	parent    *scope
+	section   *Section
+	resolved  map[string]string
+	resolving map[string]bool
+	// chain is the list of variables being resolved, shared by every scope, to report cycles.
+	chain *[]string
 }
 
-func (section *Section) substituteVariables(parentVariables map[string]string) {
-	// Merge parent variables with current section variables
-	variables := make(map[string]string)
-	for k, v := range parentVariables {
-		variables[k] = v
+func newScope(parent *scope, section *Section) *scope {
+	s := &scope{parent: parent, section: section, resolved: make(map[string]string), resolving: make(map[string]bool)}
+	if parent != nil {
+		s.chain = parent.chain
+	} else {
+		s.chain = &[]string{}
 	}
-	for k, v := range section.Variables {
-		variables[k] = v
+	return s
+}
+
+func (section *Section) substituteVariables(parent *scope, errs *ErrorList) {
+	s := newScope(parent, section)
+
+	// Resolve every variable, so that errors in unused variables are reported too
+	for _, name := range slices.Sorted(maps.Keys(section.Variables)) {
+		s.lookup(name, errs)
 	}
 
 	// Replace variables in values
 	for k, v := range section.Values {
 		for i, value := range v {
-			for varName, varValue := range variables {
-				wordToReplace := "$" + varName
-				index := strings.Index(value, wordToReplace)
-				for index != -1 {
-					beforeOk := index == 0 || !isAlphaNum(value[index-1])
-					afterPos := index + len(wordToReplace)
-					afterOk := afterPos == len(value) || !isAlphaNum(value[afterPos])
-					if beforeOk && afterOk {
-						value = value[:index] + varValue + value[afterPos:]
-						index = strings.Index(value, wordToReplace)
-					} else {
-						nextIndex := strings.Index(value[index+1:], wordToReplace)
-						if nextIndex == -1 {
-							break
-						}
-						index += 1 + nextIndex
-					}
-				}
-			}
-			section.Values[k][i] = value
+			section.Values[k][i] = expandVariables(value, func(name string) (string, bool) {
+				return s.lookup(name, errs)
+			}, section.ValuePos(k, i), errs)
 		}
 	}
 
+	for i, removal := range section.Removals {
+		section.Removals[i].Value = expandVariables(removal.Value, func(name string) (string, bool) {
+			return s.lookup(name, errs)
+		}, removal.Pos, errs)
+	}
+
 	// Replace variables in sub-sections
 	for _, v := range section.Sections {
 		for _, subSection := range v {
-			subSection.substituteVariables(variables)
+			subSection.substituteVariables(s, errs)
 		}
 	}
 }
 
-func (section *Section) GetFirst(path string, defaultValue string) string {
-	parts := strings.Split(path, "/")
-	if len(parts) == 0 {
-		return defaultValue
-	}
+// lookup returns the value of a variable visible in the scope, expanding the variables it references.
+// A variable that references itself, e.g. `$path = $path/sub`, refers to the definition in a parent section.
+func (s *scope) lookup(name string, errs *ErrorList) (string, bool) {
+	for current := s; current != nil; current = current.parent {
+		if value, ok := current.resolved[name]; ok {
+			return value, true
+		}
+		raw, ok := current.section.Variables[name]
+		if !ok {
+			continue
+		}
 
-	if len(parts) == 1 {
-		if values, ok := section.Values[parts[0]]; ok && len(values) > 0 {
-			return values[0]
+		pos := current.section.VariablePositions[name]
+		if current.resolving[name] {
+			cycle := slices.Concat((*s.chain)[slices.Index(*s.chain, name):], []string{name})
+			errs.Add(pos, "variable cycle: $%s", strings.Join(cycle, " -> $"))
+			return "", true
 		}
-		return defaultValue
+
+		current.resolving[name] = true
+		*s.chain = append(*s.chain, name)
+		value := expandVariables(raw, func(ref string) (string, bool) {
+			if ref == name {
+				if current.parent == nil {
+					value, ok := Facts()[ref]
+					return value, ok
+				}
+				return current.parent.lookup(ref, errs)
+			}
+			return current.lookup(ref, errs)
+		}, pos, errs)
+		*s.chain = (*s.chain)[:len(*s.chain)-1]
+		delete(current.resolving, name)
+
+		current.resolved[name] = value
+		return value, true
 	}
 
-	subSectionName := parts[0]
-	subSectionPath := strings.TrimPrefix(path, subSectionName+"/")
-	if subSections, ok := section.Sections[subSectionName]; ok && len(subSections) > 0 {
-		for _, subSection := range subSections {
-			if value := subSection.GetFirst(subSectionPath, ""); value != "" {
-				return value
+	value, ok := Facts()[name]
+	return value, ok
+}
+
+// expandVariables replaces every `$name` or `${name}` in the value with the value of the variable returned by lookup,
+// and every `${env:NAME}` with the value of the environment variable.
+// `${name:-default}` and `${env:NAME:-default}` use the default if the variable is not set or empty.
+// `$$` stands for a literal `$`, and a `$` that isn't followed by a name is kept as is.
+// Undefined variables are added to errs and left in the value.
+func expandVariables(value string, lookup func(name string) (string, bool), pos Position, errs *ErrorList) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' {
+			sb.WriteByte(value[i])
+			continue
+		}
+		if i+1 < len(value) && value[i+1] == '$' {
+			sb.WriteByte('$')
+			i++
+			continue
+		}
+
+		if i+1 < len(value) && value[i+1] == '{' {
+			end := closingBrace(value, i+2)
+			if end == -1 {
+				errs.Add(pos, "missing '}' after '${'")
+				sb.WriteString(value[i:])
+				break
 			}
+			sb.WriteString(expandBraced(value[i+2:end], lookup, pos, errs))
+			i = end
+			continue
+		}
+
+		end := i + 1
+		for end < len(value) && isAlphaNum(value[end]) {
+			end++
+		}
+		varName := value[i+1 : end]
+		if varName == "" {
+			sb.WriteByte('$')
+			continue
 		}
-	}
 
-	return defaultValue
+		if varValue, ok := lookup(varName); ok {
+			sb.WriteString(varValue)
+		} else {
+			errs.Add(pos, "undefined variable $%s", varName)
+			sb.WriteString(value[i:end])
+		}
+		i = end - 1
+	}
+	return sb.String()
 }
 
-func (section *Section) GetAll(path string) []string {
-	parts := strings.Split(path, "/")
-	if len(parts) == 0 {
-		return []string{}
+// closingBrace returns the index of the `}` closing a `${` whose content starts at start, or -1.
+func closingBrace(value string, start int) int {
+	depth := 1
+	for i := start; i < len(value); i++ {
+		switch value[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
 	}
+	return -1
+}
+
+// expandBraced returns the value of the content of `${...}`.
+func expandBraced(content string, lookup func(name string) (string, bool), pos Position, errs *ErrorList) string {
+	name, defaultValue, hasDefault := strings.Cut(content, ":-")
 
-	if len(parts) == 1 {
-		if values, ok := section.Values[parts[0]]; ok {
-			return values
+	var varValue string
+	var ok bool
+	if envName, isEnv := strings.CutPrefix(name, "env:"); isEnv {
+		if envName == "" {
+			errs.Add(pos, "missing environment variable name in '${%s}'", content)
+			return "${" + content + "}"
+		}
+		varValue, ok = os.LookupEnv(envName)
+		if !ok && !hasDefault {
+			errs.Add(pos, "environment variable %s is not set", envName)
+			return "${" + content + "}"
+		}
+	} else {
+		if !isIdentifier(name) {
+			errs.Add(pos, "invalid variable name in '${%s}'", content)
+			return "${" + content + "}"
+		}
+		varValue, ok = lookup(name)
+		if !ok && !hasDefault {
+			errs.Add(pos, "undefined variable $%s", name)
+			return "${" + content + "}"
 		}
-		return []string{}
 	}
 
-	subSectionName := parts[0]
-	subSectionPath := strings.TrimPrefix(path, subSectionName+"/")
+	if hasDefault && varValue == "" {
+		return expandVariables(defaultValue, lookup, pos, errs)
+	}
+	return varValue
+}
 
-	values := []string{}
-	if subSections, ok := section.Sections[subSectionName]; ok {
-		for _, subSection := range subSections {
-			values = append(values, subSection.GetAll(subSectionPath)...)
+// EscapeValue escapes a value so that it is parsed back unchanged, by doubling every `$`.
+func EscapeValue(value string) string {
+	return strings.ReplaceAll(value, "$", "$$")
+}
+
+// GetFirst returns the first non-empty value at a path, which can be a query such as `packages/pacman/repository[name=core]/server`,
+// or the default value if there is none or the query is invalid.
+// A key of the section itself that is set to an empty value returns the empty value.
+func (section *Section) GetFirst(path string, defaultValue string) string {
+	value, _ := section.GetFirstPos(path, defaultValue)
+	return value
+}
+
+// GetFirstPos is like GetFirst, but also returns the location of the value.
+// The location is invalid if the default value is returned.
+func (section *Section) GetFirstPos(path string, defaultValue string) (string, Position) {
+	matches, err := section.Query(path)
+	if err != nil || len(matches) == 0 {
+		return defaultValue, Position{}
+	}
+
+	for _, match := range matches {
+		if match.Value != "" {
+			return match.Value, match.Pos
 		}
 	}
+	if _, ok := section.Values[path]; ok {
+		return matches[0].Value, matches[0].Pos
+	}
+	return defaultValue, Position{}
+}
 
+// GetAllPos returns the locations of the values GetAll returns, in the same order.
+func (section *Section) GetAllPos(path string) []Position {
+	matches, _ := section.Query(path)
+	positions := make([]Position, len(matches))
+	for i, match := range matches {
+		positions[i] = match.Pos
+	}
+	return positions
+}
+
+// GetAll returns all the values at a path, which can be a query such as `users/user[*]/group`,
+// or no values if the query is invalid.
+func (section *Section) GetAll(path string) []string {
+	matches, _ := section.Query(path)
+	values := make([]string, len(matches))
+	for i, match := range matches {
+		values[i] = match.Value
+	}
 	return values
 }
 
@@ -254,19 +694,43 @@ func SplitValues(value string) []string {
 	return values
 }
 
+// Marshal returns the section in configuration syntax, with its values and sub-sections in declaration order.
+// Values and sub-sections added to the maps directly follow in key order, so the output is always the same.
 func (section *Section) Marshal(indent int) string {
 	output := ""
 	indentStr := strings.Repeat("  ", indent)
-	for k, v := range section.Values {
-		for _, value := range v {
-			output += indentStr + k + " = " + value + "\n"
+
+	marshalValue := func(key, value string) {
+		output += indentStr + key + " = " + EscapeValue(value) + "\n"
+	}
+	marshalSection := func(key string, subSection *Section) {
+		output += indentStr + key + " {\n"
+		output += subSection.Marshal(indent + 1)
+		output += indentStr + "}\n"
+	}
+
+	valueCounts := map[string]int{}
+	sectionCounts := map[string]int{}
+	for _, entry := range section.Entries {
+		if entry.IsSection {
+			if entry.Index < len(section.Sections[entry.Key]) {
+				marshalSection(entry.Key, section.Sections[entry.Key][entry.Index])
+				sectionCounts[entry.Key]++
+			}
+		} else if entry.Index < len(section.Values[entry.Key]) {
+			marshalValue(entry.Key, section.Values[entry.Key][entry.Index])
+			valueCounts[entry.Key]++
 		}
 	}
-	for k, v := range section.Sections {
-		for _, subSection := range v {
-			output += indentStr + k + " {\n"
-			output += subSection.Marshal(indent + 1)
-			output += indentStr + "}\n"
+
+	for _, key := range slices.Sorted(maps.Keys(section.Values)) {
+		for _, value := range section.Values[key][min(valueCounts[key], len(section.Values[key])):] {
+			marshalValue(key, value)
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(section.Sections)) {
+		for _, subSection := range section.Sections[key][min(sectionCounts[key], len(section.Sections[key])):] {
+			marshalSection(key, subSection)
 		}
 	}
 	return output
@@ -0,0 +1,232 @@
+package parser_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+func TestParse_Positions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "declarch.conf")
+	os.WriteFile(path, []byte("essentials {\n  kernel = linux\n\n  kernel = linux-lts\n}\n"), 0o644)
+
+	section, err := parser.ParseFile(path)
+	assert.NoError(t, err)
+
+	essentials := section.Sections["essentials"][0]
+	assert.Equal(t, parser.Position{File: path, Line: 1, Column: 1}, essentials.Pos)
+	assert.Equal(t, parser.Position{File: path, Line: 4, Column: 3}, essentials.ValuePos("kernel", 1))
+	assert.False(t, essentials.ValuePos("kernel", 2).IsValid())
+}
+
+func TestParse_SourcePositions(t *testing.T) {
+	dir := t.TempDir()
+	sourcedPath := filepath.Join(dir, "packages.conf")
+	os.WriteFile(sourcedPath, []byte("package = neovim\n"), 0o644)
+
+	section, err := parser.Parse("pacman {\n  source = " + sourcedPath + "\n}")
+	assert.NoError(t, err)
+	assert.Equal(t, parser.Position{File: sourcedPath, Line: 1, Column: 1}, section.Sections["pacman"][0].ValuePos("package", 0))
+}
+
+func TestParseFile_RelativeSources(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "hosts.d"), 0o755)
+	os.WriteFile(filepath.Join(dir, "hosts.d", "b.conf"), []byte("package = git\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "hosts.d", "a.conf"), []byte("package = neovim\nsource = ../common.conf\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "common.conf"), []byte("package = bash\n"), 0o644)
+
+	path := filepath.Join(dir, "declarch.conf")
+	os.WriteFile(path, []byte("pacman {\n  source = hosts.d/*.conf\n  source? = missing.conf\n}\n"), 0o644)
+
+	section, err := parser.ParseFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"neovim", "bash", "git"}, section.GetAll("pacman/package"))
+}
+
+func TestParseFile_SourceGlobWithoutMatches(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "declarch.conf")
+	os.WriteFile(path, []byte("source? = host.d/*.conf\nsource = hosts.d/*.conf\n"), 0o644)
+
+	_, err := parser.ParseFile(path)
+	assert.EqualError(t, err, path+":2:1: no files match source pattern "+filepath.Join(dir, "hosts.d", "*.conf"))
+}
+
+func TestParseFile_SourceCycle(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "declarch.conf")
+	os.WriteFile(path, []byte("source = a.conf\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "a.conf"), []byte("package = neovim\nsource = declarch.conf\n"), 0o644)
+
+	_, err := parser.ParseFile(path)
+	assert.EqualError(t, err, filepath.Join(dir, "a.conf")+":2:1: source cycle: "+
+		path+" -> "+filepath.Join(dir, "a.conf")+" -> "+path)
+}
+
+func TestParse_Errors(t *testing.T) {
+	input := "essentials {\n  kernel = linux $FOO\n  stray line\n}\n}\npackages {\n  source = /nonexistent/declarch.conf\n"
+
+	section, err := parser.Parse(input)
+	assert.NotNil(t, section)
+
+	var errs parser.ErrorList
+	assert.ErrorAs(t, err, &errs)
+
+	messages := []string{}
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+	assert.Equal(t, []string{
+		"2:3: undefined variable $FOO",
+		"3:3: expected 'key = value', 'name {' or '}', got 'stray line'",
+		"5:1: unexpected '}' without an open section",
+		"6:1: section is never closed, expected '}'",
+		"7:3: cannot read sourced file /nonexistent/declarch.conf: no such file or directory",
+	}, messages)
+}
+
+func TestParse_Variables(t *testing.T) {
+	section, err := parser.Parse("$repo = core\nserver = https://example.com/$repo/$$arch\nrun = sed 's/ALL$/x/'")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/core/$arch", section.GetFirst("server", ""))
+	assert.Equal(t, "sed 's/ALL$/x/'", section.GetFirst("run", ""))
+
+	reparsed, err := parser.Parse(section.Marshal(0))
+	assert.NoError(t, err)
+	assert.Equal(t, section.Values, reparsed.Values)
+}
+
+func TestMarshal_Order(t *testing.T) {
+	input := "essentials {\n  kernel = linux-lts\n  bootloader = grub\n  kernel = linux\n}\npackages {\n  pacman {\n    repository {\n      name = extra\n    }\n    package = neovim\n    repository {\n      name = core\n    }\n  }\n}\n"
+
+	section, err := parser.Parse(input)
+	assert.NoError(t, err)
+	assert.Equal(t, input, section.Marshal(0))
+
+	section.Sections["essentials"][0].Values["shell"] = []string{"bash"}
+	section.Sections["essentials"][0].Values["editor"] = []string{"nano"}
+	assert.Equal(t, "kernel = linux-lts\nbootloader = grub\nkernel = linux\neditor = nano\nshell = bash\n",
+		section.Sections["essentials"][0].Marshal(0))
+}
+func TestParse_NestedVariables(t *testing.T) {
+	input := "$base = /srv\n$data = $base/data\nfirst = ${data}1\nsection {\n  $data = $data/sub\n  path = $data\n}\n"
+
+	section, err := parser.Parse(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "/srv/data1", section.GetFirst("first", ""))
+	assert.Equal(t, "/srv/data/sub", section.GetFirst("section/path", ""))
+
+	_, err = parser.Parse("$a = $b\n$b = ${a}\nkey = $a\n")
+	var errs parser.ErrorList
+	assert.ErrorAs(t, err, &errs)
+	assert.Len(t, errs, 1)
+	assert.Equal(t, "1:1: variable cycle: $a -> $b -> $a", errs[0].Error())
+}
+
+func TestParse_Facts(t *testing.T) {
+	section, err := parser.Parse("arch = $arch\nhost = ${hostname}\nvendor = ${cpu_vendor:-unknown}\n")
+	assert.NoError(t, err)
+	assert.Equal(t, parser.Facts()["arch"], section.GetFirst("arch", ""))
+	assert.NotEmpty(t, section.GetFirst("arch", ""))
+	assert.Equal(t, parser.Facts()["hostname"], section.GetFirst("host", ""))
+	assert.NotEmpty(t, section.GetFirst("vendor", ""))
+
+	_, err = parser.Parse("$hostname = laptop\n")
+	assert.EqualError(t, err, "1:1: cannot redefine built-in variable $hostname")
+}
+
+func TestParse_Environment(t *testing.T) {
+	t.Setenv("DECLARCH_TEST_HOME", "/home/ghost")
+	t.Setenv("DECLARCH_TEST_EMPTY", "")
+
+	section, err := parser.Parse("$fallback = /root\nhome = ${env:DECLARCH_TEST_HOME}/.config\nempty = ${env:DECLARCH_TEST_EMPTY:-${fallback}}\nunset = ${env:DECLARCH_TEST_UNSET:-none}\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "/home/ghost/.config", section.GetFirst("home", ""))
+	assert.Equal(t, "/root", section.GetFirst("empty", ""))
+	assert.Equal(t, "none", section.GetFirst("unset", ""))
+
+	_, err = parser.Parse("home = ${env:DECLARCH_TEST_UNSET}\nbad = ${not a name}\nopen = ${env:HOME\n")
+	var errs parser.ErrorList
+	assert.ErrorAs(t, err, &errs)
+	messages := []string{}
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+	assert.Equal(t, []string{
+		"1:1: environment variable DECLARCH_TEST_UNSET is not set",
+		"2:1: invalid variable name in '${not a name}'",
+		"3:1: missing '}' after '${'",
+	}, messages)
+}
+func TestParse_Conditions(t *testing.T) {
+	input := `$role = workstation
+if $role == "workstation" {
+  essentials {
+    kernel = linux-zen
+  }
+} else {
+  essentials {
+    kernel = linux-lts
+  }
+}
+packages {
+  pacman {
+    package = neovim
+    if tag(gaming) && $role =~ "^work" {
+      package = steam
+    } else if $role in ["laptop", workstation] {
+      package = tlp
+      if tag(+default) {
+        package = powertop
+      }
+    } else {
+      package = never
+    }
+    if !(tag(gaming) || $role != workstation) {
+      hook {
+        package = rustup
+      }
+    }
+  }
+}
+`
+
+	section, err := parser.Parse(input)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"linux-zen"}, section.GetAll("essentials/kernel"))
+	assert.Equal(t, []string{"neovim", "tlp", "powertop"}, section.GetAll("packages/pacman/package"))
+	assert.Equal(t, []string{"rustup"}, section.GetAll("packages/pacman/hook/package"))
+
+	path := filepath.Join(t.TempDir(), "declarch.conf")
+	os.WriteFile(path, []byte(input), 0o644)
+
+	hasTag := func(name string) bool { return name == "gaming" }
+	section, err = parser.ParseFileWith(path, parser.ParseOptions{HasTag: hasTag})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"neovim", "steam"}, section.GetAll("packages/pacman/package"))
+	assert.Empty(t, section.GetAll("packages/pacman/hook/package"))
+}
+
+func TestParse_ConditionErrors(t *testing.T) {
+	input := "if $missing == a {\n}\nif a = b {\n}\n} else {\nif tag(x) {\n"
+
+	_, err := parser.Parse(input)
+	var errs parser.ErrorList
+	assert.ErrorAs(t, err, &errs)
+
+	messages := []string{}
+	for _, e := range errs {
+		messages = append(messages, e.Error())
+	}
+	assert.Equal(t, []string{
+		"1:1: undefined variable $missing",
+		"3:1: invalid condition 'a = b': expected an operator after 'a', got '='",
+		"5:1: unexpected 'else' without 'if'",
+		"6:1: if block is never closed, expected '}'",
+	}, messages)
+}
\ No newline at end of file
@@ -0,0 +1,265 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Query is a parsed path query, such as `packages/pacman/repository[name=core]/server`.
+// Each step of the path selects the sub-sections with a name, or the values of a key for the last step,
+// and can be followed by filters between brackets:
+//   - `[N]` keeps the match at a zero-based index, counted across all the matches of the step,
+//   - `[*]` keeps every match, like no filter,
+//   - `[key=value]` and `[key!=value]` keep the sections where a value of the key is (or isn't) the value.
+//
+// Values in filters can be quoted, e.g. `[name="core"]`, to use brackets or slashes.
+type Query struct {
+	steps []queryStep
+}
+
+type queryStep struct {
+	name    string
+	filters []queryFilter
+}
+
+type queryFilter struct {
+	// index is the index to keep, or -1 for filters that don't select by index.
+	index int
+	// key is the key compared by `[key=value]` filters, and is empty for `[N]` and `[*]`.
+	key    string
+	value  string
+	negate bool
+	// column is the one-based position of the filter in the query, for errors.
+	column int
+}
+
+// ParseQuery parses a path query.
+func ParseQuery(query string) (*Query, error) {
+	q := &Query{}
+	for i := 0; ; {
+		start := i
+		for i < len(query) && query[i] != '/' && query[i] != '[' && query[i] != ']' {
+			i++
+		}
+		step := queryStep{name: strings.TrimSpace(query[start:i])}
+		if step.name == "" {
+			return nil, fmt.Errorf("expected a name at column %d", start+1)
+		}
+
+		for i < len(query) && query[i] == '[' {
+			filter, end, err := parseQueryFilter(query, i)
+			if err != nil {
+				return nil, err
+			}
+			step.filters = append(step.filters, filter)
+			i = end
+		}
+		q.steps = append(q.steps, step)
+
+		if i == len(query) {
+			return q, nil
+		} else if query[i] != '/' {
+			return nil, fmt.Errorf("unexpected '%c' at column %d", query[i], i+1)
+		}
+		i++
+	}
+}
+
+// parseQueryFilter parses the filter between the brackets starting at start, and returns the index after the closing bracket.
+func parseQueryFilter(query string, start int) (queryFilter, int, error) {
+	filter := queryFilter{index: -1, column: start + 1}
+
+	var sb strings.Builder
+	quoted := false
+	end := start + 1
+	for ; end < len(query) && (quoted || query[end] != ']'); end++ {
+		if query[end] == '"' {
+			quoted = !quoted
+		} else if quoted && query[end] == '\\' && end+1 < len(query) && (query[end+1] == '"' || query[end+1] == '\\') {
+			end++
+		}
+		sb.WriteByte(query[end])
+	}
+	if end == len(query) {
+		return filter, end, fmt.Errorf("missing ']' for the '[' at column %d", start+1)
+	}
+
+	content := strings.TrimSpace(sb.String())
+	if content == "*" {
+		return filter, end + 1, nil
+	}
+	if index, err := strconv.Atoi(content); err == nil && index >= 0 {
+		filter.index = index
+		return filter, end + 1, nil
+	}
+
+	key, value, found := strings.Cut(content, "=")
+	if !found || key == "" || strings.ContainsRune(key, '"') {
+		return filter, end, fmt.Errorf("expected an index, '*' or 'key=value' in '[%s]' at column %d", content, start+1)
+	}
+	if strings.HasSuffix(key, "!") {
+		key, filter.negate = strings.TrimSuffix(key, "!"), true
+	}
+	filter.key = strings.TrimSpace(key)
+	filter.value = unquoteQueryValue(strings.TrimSpace(value))
+	return filter, end + 1, nil
+}
+
+// unquoteQueryValue removes the quotes around a filter value. Its escapes were already removed by parseQueryFilter.
+func unquoteQueryValue(value string) string {
+	if len(value) < 2 || !strings.HasPrefix(value, "\"") || !strings.HasSuffix(value, "\"") {
+		return value
+	}
+	return value[1 : len(value)-1]
+}
+
+// Path returns the path of the query without its filters, e.g. `packages/pacman/repository/server`.
+func (q *Query) Path() string {
+	names := make([]string, len(q.steps))
+	for i, step := range q.steps {
+		names[i] = step.name
+	}
+	return strings.Join(names, "/")
+}
+
+// Match is a value selected by a query.
+type Match struct {
+	Value string
+	Pos   Position
+}
+
+// Values returns the values selected by the query, in declaration order.
+// include is called for the sections of each step, and the sections for which it returns false are skipped with their sub-sections.
+// It can be nil to include every section.
+func (q *Query) Values(section *Section, include func(section *Section) bool) ([]Match, error) {
+	last := q.steps[len(q.steps)-1]
+	sections := q.sections(section, q.steps[:len(q.steps)-1], include)
+
+	matches := []Match{}
+	for _, s := range sections {
+		for i, value := range s.Values[last.name] {
+			matches = append(matches, Match{Value: value, Pos: s.ValuePos(last.name, i)})
+		}
+	}
+	for _, filter := range last.filters {
+		if filter.key != "" {
+			return nil, fmt.Errorf("'%s' is a key, so '[%s=...]' at column %d can't filter it", last.name, filter.key, filter.column)
+		}
+		matches = filterIndex(matches, filter)
+	}
+	return matches, nil
+}
+
+// Sections returns the sections selected by the query, in declaration order.
+// include works like for Values.
+func (q *Query) Sections(section *Section, include func(section *Section) bool) []*Section {
+	return q.sections(section, q.steps, include)
+}
+
+func (q *Query) sections(section *Section, steps []queryStep, include func(section *Section) bool) []*Section {
+	current := []*Section{section}
+	for _, step := range steps {
+		next := []*Section{}
+		for _, s := range current {
+			next = append(next, s.Sections[step.name]...)
+		}
+
+		// Filters are applied before include, so that indexes don't depend on the selected tags
+		for _, filter := range step.filters {
+			if filter.key == "" {
+				next = filterIndex(next, filter)
+				continue
+			}
+			next = slices.DeleteFunc(next, func(s *Section) bool {
+				return slices.Contains(s.GetAll(filter.key), filter.value) == filter.negate
+			})
+		}
+
+		if include != nil {
+			next = slices.DeleteFunc(next, func(s *Section) bool {
+				return !include(s)
+			})
+		}
+		current = next
+	}
+	return current
+}
+
+// unset removes the values and sections the query selects from their sections.
+// Filters like `[name=core]` only select sections, and indexes count the values and the sections separately.
+func (q *Query) unset(section *Section) {
+	last := q.steps[len(q.steps)-1]
+
+	type entry struct {
+		parent *Section
+		index  int
+	}
+	values, sections := []entry{}, []entry{}
+	for _, parent := range q.sections(section, q.steps[:len(q.steps)-1], nil) {
+		for i := range parent.Values[last.name] {
+			values = append(values, entry{parent, i})
+		}
+		for i := range parent.Sections[last.name] {
+			sections = append(sections, entry{parent, i})
+		}
+	}
+
+	for _, filter := range last.filters {
+		if filter.key == "" {
+			values, sections = filterIndex(values, filter), filterIndex(sections, filter)
+			continue
+		}
+		values = nil
+		sections = slices.DeleteFunc(sections, func(e entry) bool {
+			return slices.Contains(e.parent.Sections[last.name][e.index].GetAll(filter.key), filter.value) == filter.negate
+		})
+	}
+
+	// Each section is changed once, since removing entries changes the indexes of the others
+	remove := func(entries []entry, isSection bool) {
+		parents := []*Section{}
+		for _, e := range entries {
+			if !slices.Contains(parents, e.parent) {
+				parents = append(parents, e.parent)
+			}
+		}
+		for _, parent := range parents {
+			parent.removeEntries(last.name, isSection, func(i int) bool {
+				return slices.Contains(entries, entry{parent, i})
+			})
+		}
+	}
+	remove(values, false)
+	remove(sections, true)
+}
+
+// filterIndex applies an `[N]` or `[*]` filter.
+func filterIndex[T any](matches []T, filter queryFilter) []T {
+	if filter.index < 0 {
+		return matches
+	} else if filter.index >= len(matches) {
+		return []T{}
+	}
+	return []T{matches[filter.index]}
+}
+
+// Query returns the values selected by a path query, such as `users/user[*]/username`. See Query for the syntax.
+func (section *Section) Query(query string) ([]Match, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Values(section, nil)
+}
+
+// QuerySections returns the sections selected by a path query, such as `packages/pacman/repository[name=core]`.
+// Sections for which include returns false are skipped with their sub-sections, and include can be nil.
+func (section *Section) QuerySections(query string, include func(section *Section) bool) ([]*Section, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return q.Sections(section, include), nil
+}
\ No newline at end of file
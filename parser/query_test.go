@@ -0,0 +1,94 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/parser"
+)
+
+const queryConfig = `packages {
+  pacman {
+    repository {
+      name = core
+      server = https://a/core
+    }
+    repository {
+      name = extra [testing]
+      server = https://a/extra
+      server = https://b/extra
+    }
+  }
+}
+packages {
+  pacman {
+    repository {
+      name = multilib
+      server = https://a/multilib
+    }
+  }
+}
+`
+
+func TestSection_Query(t *testing.T) {
+	section, err := parser.ParseContent(queryConfig, "declarch.conf")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		query  string
+		values []string
+	}{
+		{"packages/pacman/repository/name", []string{"core", "extra [testing]", "multilib"}},
+		{"packages/pacman/repository[*]/name", []string{"core", "extra [testing]", "multilib"}},
+		{"packages/pacman/repository[2]/name", []string{"multilib"}},
+		{"packages/pacman/repository[3]/name", []string{}},
+		{"packages[1]/pacman/repository/name", []string{"multilib"}},
+		{"packages/pacman/repository[name=core]/server", []string{"https://a/core"}},
+		{`packages/pacman/repository[name="extra [testing]"]/server[1]`, []string{"https://b/extra"}},
+		{"packages/pacman/repository[name!=core][0]/name", []string{"extra [testing]"}},
+		{"packages/pacman/repository/server[2]", []string{"https://b/extra"}},
+		{"packages/pacman/repository[server=https://a/multilib]/name", []string{"multilib"}},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.values, section.GetAll(test.query), test.query)
+	}
+
+	value, pos := section.GetFirstPos("packages/pacman/repository[name=multilib]/server", "")
+	assert.Equal(t, "https://a/multilib", value)
+	assert.Equal(t, parser.Position{File: "declarch.conf", Line: 18, Column: 7}, pos)
+
+	sections, err := section.QuerySections("packages/pacman/repository[name=core]", nil)
+	assert.NoError(t, err)
+	assert.Len(t, sections, 1)
+	assert.Equal(t, 3, sections[0].Pos.Line)
+
+	sections, err = section.QuerySections("packages/pacman/repository", func(s *parser.Section) bool {
+		return s.GetFirst("name", "") != "core"
+	})
+	assert.NoError(t, err)
+	assert.Len(t, sections, 2)
+}
+
+func TestParseQuery_Errors(t *testing.T) {
+	tests := map[string]string{
+		"":                        "expected a name at column 1",
+		"packages//pacman":        "expected a name at column 10",
+		"users/user[0":            "missing ']' for the '[' at column 11",
+		"users/user[-1]":          "expected an index, '*' or 'key=value' in '[-1]' at column 11",
+		"users/user[name]":        "expected an index, '*' or 'key=value' in '[name]' at column 11",
+		"users/user[0]x":          "unexpected 'x' at column 14",
+		"users/user]":             "unexpected ']' at column 11",
+		`users/user[name="a]`:     "missing ']' for the '[' at column 11",
+		"users/user[0]/username/": "expected a name at column 24",
+	}
+	for query, message := range tests {
+		_, err := parser.ParseQuery(query)
+		assert.EqualError(t, err, message, query)
+	}
+
+	section, err := parser.Parse("user {\n  username = alice\n}\n")
+	assert.NoError(t, err)
+	_, err = section.Query("user/username[name=alice]")
+	assert.EqualError(t, err, "'username' is a key, so '[name=...]' at column 14 can't filter it")
+}
\ No newline at end of file
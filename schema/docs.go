@@ -0,0 +1,155 @@
+package schema
+
+import (
+	"io"
+	"strings"
+)
+
+// Walk calls fn for every key under this key, depth first and in schema order, with the path of the key.
+func (k *Key) Walk(fn func(path string, key *Key)) {
+	k.walk("", fn)
+}
+
+func (k *Key) walk(prefix string, fn func(path string, key *Key)) {
+	for _, child := range k.Keys {
+		path := child.Name
+		if prefix != "" {
+			path = prefix + "/" + child.Name
+		}
+		fn(path, child)
+		child.walk(path, fn)
+	}
+}
+
+// Attributes returns short descriptions of the type and constraints of the key, e.g. "default: sudo".
+func (k *Key) Attributes() []string {
+	attributes := []string{"type: " + string(k.Type)}
+	if k.Type != Section && len(k.Keys) > 0 {
+		attributes[0] += " or section"
+	}
+	if k.Default != "" {
+		attributes = append(attributes, "default: "+k.Default)
+	}
+	if len(k.Allowed) > 0 {
+		attributes = append(attributes, "allowed: "+strings.Join(k.Allowed, ", "))
+	}
+	if k.Required {
+		attributes = append(attributes, "required")
+	}
+	if k.Repeated {
+		attributes = append(attributes, "repeatable")
+	}
+	if k.Tagged {
+		attributes = append(attributes, "accepts tags")
+	}
+	if k.Argument != "" {
+		attributes = append(attributes, "written as: "+k.Name+" <"+k.Argument+"> = value")
+	}
+	return attributes
+}
+
+// Snippet returns a configuration snippet that sets the key at path to value,
+// e.g. "packages {\n  aur {\n    helper = yay\n  }\n}".
+func Snippet(path, value string) string {
+	names := strings.Split(path, "/")
+
+	var builder strings.Builder
+	for i, name := range names[:len(names)-1] {
+		builder.WriteString(strings.Repeat("  ", i) + name + " {\n")
+	}
+	builder.WriteString(strings.Repeat("  ", len(names)-1) + names[len(names)-1] + " = " + value + "\n")
+	for i := len(names) - 2; i >= 0; i-- {
+		builder.WriteString(strings.Repeat("  ", i) + "}\n")
+	}
+	return builder.String()
+}
+
+// ExampleSnippet returns a configuration snippet of the example of the key at path, with the example argument if it has one.
+func (k *Key) ExampleSnippet(path string) string {
+	if k.Argument != "" {
+		path += " " + k.ArgumentExample
+	}
+	return Snippet(path, k.Example)
+}
+
+// WriteMarkdown writes a Markdown reference of every key under root.
+func WriteMarkdown(w io.Writer, root *Key) error {
+	var builder strings.Builder
+	builder.WriteString("# DeclArch configuration reference\n")
+
+	root.Walk(func(path string, key *Key) {
+		if strings.Contains(path, "/") {
+			builder.WriteString("\n### `" + path + "`\n\n")
+		} else {
+			builder.WriteString("\n## `" + path + "`\n\n")
+		}
+		builder.WriteString(key.Markdown(path))
+	})
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// Markdown returns the description, attributes and example of the key at path as Markdown.
+func (k *Key) Markdown(path string) string {
+	var builder strings.Builder
+	builder.WriteString(k.Description + "\n\n")
+
+	for _, attribute := range k.Attributes() {
+		builder.WriteString("- " + markdownAttribute(attribute) + "\n")
+	}
+
+	if k.Example != "" {
+		builder.WriteString("\nExample:\n\n```\n" + k.ExampleSnippet(path) + "```\n")
+	}
+	return builder.String()
+}
+
+// markdownAttribute capitalizes an attribute and formats its value as code, e.g. "Default: `sudo`".
+func markdownAttribute(attribute string) string {
+	name, value, found := strings.Cut(attribute, ": ")
+	name = strings.ToUpper(name[:1]) + name[1:]
+	if !found {
+		return name
+	}
+	return name + ": `" + strings.ReplaceAll(value, ", ", "`, `") + "`"
+}
+
+// WriteMan writes a declarch.conf(5) man page of every key under root.
+func WriteMan(w io.Writer, root *Key) error {
+	var builder strings.Builder
+	builder.WriteString(".TH DECLARCH.CONF 5 \"\" \"DeclArch\" \"DeclArch Manual\"\n")
+	builder.WriteString(".SH NAME\ndeclarch.conf \\- DeclArch configuration file\n")
+	builder.WriteString(".SH DESCRIPTION\n")
+	builder.WriteString("The configuration consists of sections written as \\fIname\\fR { ... } and keys written as \\fIname\\fR = \\fIvalue\\fR.\n")
+	builder.WriteString("Keys are referred to by their path, e.g. \\fBpackages/aur/helper\\fR.\n")
+
+	root.Walk(func(path string, key *Key) {
+		if !strings.Contains(path, "/") {
+			builder.WriteString(".SH " + strings.ToUpper(manEscape(path)) + "\n")
+			builder.WriteString(manEscape(key.Description) + "\n")
+			return
+		}
+
+		builder.WriteString(".TP\n.B " + manEscape(path) + "\n")
+		builder.WriteString(manEscape(key.Description) + "\n")
+		builder.WriteString(".br\n" + manEscape(strings.Join(key.Attributes(), "; ")) + "\n")
+		if key.Example != "" {
+			builder.WriteString(".br\nExample: \\fB" + manEscape(key.Example) + "\\fR\n")
+		}
+	})
+
+	builder.WriteString(".SH SEE ALSO\n.BR declarch (1)\n")
+
+	_, err := io.WriteString(w, builder.String())
+	return err
+}
+
+// manEscape escapes text for roff, so that backslashes and leading dots are printed literally.
+func manEscape(text string) string {
+	text = strings.ReplaceAll(text, "\\", "\\e")
+	if strings.HasPrefix(text, ".") || strings.HasPrefix(text, "'") {
+		text = "\\&" + text
+	}
+	return text
+}
\ No newline at end of file
@@ -0,0 +1,40 @@
+package schema_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/schema"
+)
+
+func TestRoot_Documented(t *testing.T) {
+	schema.Root.Walk(func(path string, key *schema.Key) {
+		assert.NotEmpty(t, key.Description, "%s has no description", path)
+		if key.IsValue() {
+			assert.NotEmpty(t, key.Example, "%s has no example", path)
+		}
+	})
+}
+
+func TestSnippet(t *testing.T) {
+	assert.Equal(t, "packages {\n  aur {\n    helper = yay\n  }\n}\n", schema.Snippet("packages/aur/helper", "yay"))
+	assert.Equal(t, "kernel = linux\n", schema.Snippet("kernel", "linux"))
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, schema.WriteMarkdown(&buffer, schema.Root))
+
+	assert.Contains(t, buffer.String(), "### `packages/flatpak/remote/default_branch`\n")
+	assert.Contains(t, buffer.String(), "- Default: `makepkg`\n")
+}
+
+func TestWriteMan(t *testing.T) {
+	var buffer bytes.Buffer
+	assert.NoError(t, schema.WriteMan(&buffer, schema.Root))
+
+	assert.Contains(t, buffer.String(), ".B config_parser/replace_comments\n")
+	assert.Contains(t, buffer.String(), "Example: \\fBhttps://example.com/$$repo/os/$$arch\\fR\n")
+}
\ No newline at end of file
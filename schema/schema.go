@@ -0,0 +1,302 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Type is the type of the value of a key.
+type Type string
+
+const (
+	String Type = "string"
+	Bool   Type = "bool"
+	Int    Type = "int"
+	// List values hold several whitespace separated items, e.g. `package = neovim git`.
+	List Type = "list"
+	// Tags values are tag expressions, e.g. `tags = +!laptop & !+!server`.
+	Tags Type = "tags"
+	// Section keys hold sub-keys between braces instead of a value.
+	Section Type = "section"
+)
+
+// Key describes a key or section of the configuration.
+type Key struct {
+	Name        string
+	Type        Type
+	Description string
+	// Default is the value used if the key is not set.
+	Default string
+	// Allowed lists the only values the key can have, if it is not empty.
+	Allowed []string
+	// Repeated keys can be set several times, and all of their values are used.
+	Repeated bool
+	Required bool
+	// Tagged values can be followed by tags, e.g. `package = firefox, +desktop`.
+	Tagged bool
+	// Ordered keys have values whose order matters, so they are never sorted.
+	Ordered bool
+	// Argument names the argument written after the name of the key, e.g. "name" for `preset <name> = value`.
+	// Each argument is a separate key, like `preset laptop` and `preset desktop`.
+	Argument string
+	// Example is an example value, shown in the documentation.
+	Example string
+	// ArgumentExample is an example argument, shown in the documentation.
+	ArgumentExample string
+	// Keys are the sub-keys of a section.
+	// A value key with sub-keys can also be written as a section, e.g. Flatpak packages.
+	Keys []*Key
+}
+
+// IsSection reports whether the key can be written as a section.
+func (k *Key) IsSection() bool {
+	return k.Type == Section || len(k.Keys) > 0
+}
+
+// IsValue reports whether the key can be written as a value.
+func (k *Key) IsValue() bool {
+	return k.Type != Section
+}
+
+// Child returns the sub-key with the given name, or nil if there is none.
+// Keys with an argument also match the name followed by an argument, e.g. "preset laptop".
+func (k *Key) Child(name string) *Key {
+	for _, child := range k.Keys {
+		if child.Name == name {
+			return child
+		}
+		if before, _, found := strings.Cut(name, " "); found && child.Argument != "" && child.Name == before {
+			return child
+		}
+	}
+	return nil
+}
+
+// Lookup returns the key at a path relative to this key, or nil if there is none.
+// Indexes in the path are ignored, e.g. "packages/flatpak/remote[2]/url".
+func (k *Key) Lookup(path string) *Key {
+	key := k
+	for _, name := range strings.Split(path, "/") {
+		if index := strings.Index(name, "["); index != -1 {
+			name = name[:index]
+		}
+		if key = key.Child(name); key == nil {
+			return nil
+		}
+	}
+	return key
+}
+
+// DefaultOf returns the default value of the key at a path relative to this key.
+// It panics if the path is not in the schema, since that is a mistake in the code reading the configuration.
+func (k *Key) DefaultOf(path string) string {
+	key := k.Lookup(path)
+	if key == nil {
+		panic(fmt.Sprintf("schema: unknown configuration path '%s'", path))
+	}
+	return key.Default
+}
+
+// Lookup returns the key at a path of the configuration, or nil if there is none.
+func Lookup(path string) *Key {
+	return Root.Lookup(path)
+}
+
+// Default returns the default value of the key at a path of the configuration, e.g. "packages/aur/helper".
+func Default(path string) string {
+	return Root.DefaultOf(path)
+}
+
+// Repeated reports whether the key or section at a path of the configuration can be written several times,
+// for parser.ParseOptions.Repeated.
+func Repeated(path string) bool {
+	key := Lookup(path)
+	return key != nil && key.Repeated
+}
+
+// tagsKey returns the `tags` key of a section that is only applied for some tags.
+func tagsKey() *Key {
+	return &Key{Name: "tags", Type: Tags, Example: "+!laptop & !+!server",
+		Description: "The tags the section is applied for, as a list like `+desktop +!bare` or an expression like `(+!gui | +!dev) & !+!server`. Sections without tags are always applied."}
+}
+
+// Hook returns the keys of a hook section,
+// whose `for` field is either additionTerm (the default) or removalTerm.
+func Hook(additionTerm, removalTerm string) *Key {
+	return &Key{
+		Name: "hook", Type: Section, Repeated: true,
+		Description: "A command to run when a resource is " + pastTense(additionTerm) + " or " + pastTense(removalTerm) + ".",
+		Keys: []*Key{
+			{Name: "for", Type: String, Default: additionTerm, Example: removalTerm, Allowed: []string{additionTerm, removalTerm},
+				Description: "Whether to run the command when the resource is " + pastTense(additionTerm) + " or " + pastTense(removalTerm) + "."},
+			{Name: "when", Type: String, Default: "after", Example: "before", Allowed: []string{"before", "after"},
+				Description: "Whether to run the command before or after the change."},
+			{Name: "as", Type: String, Example: "root",
+				Description: "The user to run the command as. Defaults to the primary user."},
+			{Name: "run", Type: String, Required: true, Example: "echo done",
+				Description: "The shell command to run."},
+			tagsKey(),
+		},
+	}
+}
+
+func pastTense(verb string) string {
+	if strings.HasSuffix(verb, "e") {
+		return verb + "d"
+	}
+	return verb + "ed"
+}
+
+// packageHook returns the keys of a hook section of a package section.
+func packageHook() *Key {
+	hook := Hook("install", "remove")
+	hook.Keys = append([]*Key{{Name: "package", Type: String, Required: true, Example: "rustup",
+		Description: "The package the hook runs for."}}, hook.Keys...)
+	return hook
+}
+
+// strictKeys returns the strict mode keys of a package section.
+func strictKeys(actions ...string) []*Key {
+	return []*Key{
+		{Name: "strict", Type: Bool, Default: "false", Example: "true",
+			Description: "Whether explicitly installed packages that are not declared are removed."},
+		{Name: "strict_action", Type: String, Default: "remove", Allowed: actions, Example: actions[len(actions)-1],
+			Description: "What to do with undeclared packages in strict mode."},
+		{Name: "protected", Type: List, Repeated: true, Example: "htop btop",
+			Description: "Packages that are never removed in strict mode."},
+	}
+}
+
+// Root is the schema of the whole configuration.
+var Root = &Key{
+	Type: Section,
+	Keys: []*Key{
+		{Name: "config_parser", Type: Section, Description: "Settings for updating system configuration files.", Keys: []*Key{
+			{Name: "replace_comments", Type: Bool, Default: "true", Example: "false",
+				Description: "Whether to replace commented out defaults when updating configuration files."},
+		}},
+		{Name: "essentials", Type: Section, Description: "The packages and tools every system needs.", Keys: []*Key{
+			{Name: "privilege_escalation", Type: String, Default: "sudo", Allowed: []string{"sudo", "doas", "pkexec", "su"}, Example: "doas",
+				Description: "The command to use for privilege escalation."},
+			{Name: "kernel", Type: List, Repeated: true, Tagged: true, Ordered: true, Example: "linux-lts, +bare",
+				Description: "The kernels to install. The top kernel is the default, and the last kernel is treated as if it has the `+bare` tag."},
+			{Name: "network_handler", Type: List, Default: "networkmanager", Example: "iwd",
+				Description: "The network handler packages to install."},
+			{Name: "bootloader", Type: List, Default: "grub efibootmgr", Example: "grub efibootmgr os-prober",
+				Description: "The bootloader packages to install."},
+		}},
+		{Name: "users", Type: Section, Description: "The users of the system.", Keys: []*Key{
+			{Name: "primary_user", Type: String, Default: "nobody", Example: "myuser",
+				Description: "The user that runs hooks, AUR helpers and Flatpak."},
+			{Name: "user", Type: Section, Repeated: true, Description: "A user account.", Keys: []*Key{
+				{Name: "username", Type: String, Required: true, Example: "myuser", Description: "The name of the user."},
+				{Name: "full_name", Type: String, Example: "My User", Description: "The full name of the user."},
+				{Name: "shell", Type: String, Example: "zsh", Description: "The login shell of the user."},
+				{Name: "create_home", Type: Bool, Default: "true", Example: "false", Description: "Whether to create the home directory of the user."},
+				{Name: "home_dir", Type: String, Example: "/home/myuser", Description: "The home directory of the user."},
+				{Name: "group", Type: List, Repeated: true, Example: "wheel", Description: "The supplementary groups of the user."},
+				tagsKey(),
+			}},
+			func() *Key {
+				hook := Hook("create", "delete")
+				hook.Keys = append([]*Key{{Name: "user", Type: String, Required: true, Example: "myuser",
+					Description: "The user the hook runs for."}}, hook.Keys...)
+				return hook
+			}(),
+		}},
+		{Name: "packages", Type: Section, Description: "The packages to install.", Keys: []*Key{
+			{Name: "pacman", Type: Section, Description: "Packages from the sync repositories.", Keys: append([]*Key{
+				{Name: "color", Type: Bool, Default: "false", Example: "true", Description: "Whether to enable Pacman's `Color` option."},
+				{Name: "parallel_downloads", Type: Int, Example: "10", Description: "The number of packages Pacman downloads in parallel."},
+				{Name: "verbose_pkg_lists", Type: Bool, Default: "false", Example: "true", Description: "Whether to enable Pacman's `VerbosePkgLists` option."},
+				{Name: "i_love_candy", Type: Bool, Default: "false", Example: "true", Description: "Whether to enable Pacman's `ILoveCandy` option."},
+				{Name: "repository", Type: Section, Repeated: true, Description: "A Pacman repository.", Keys: []*Key{
+					{Name: "name", Type: String, Required: true, Example: "multilib", Description: "The name of the repository."},
+					{Name: "include", Type: String, Example: "/etc/pacman.d/mirrorlist", Description: "The file to include servers from. Official repositories default to the mirrorlist."},
+					{Name: "server", Type: String, Example: "https://example.com/$$repo/os/$$arch", Description: "The URL of the repository."},
+					tagsKey(),
+				}},
+				{Name: "package", Type: List, Repeated: true, Tagged: true, Example: "neovim git, +desktop", Description: "Packages to install."},
+				packageHook(),
+			}, strictKeys("remove", "mark_dependency")...)},
+			{Name: "aur", Type: Section, Description: "Packages from the AUR.", Keys: append([]*Key{
+				{Name: "helper", Type: String, Default: "makepkg", Example: "yay", Description: "The AUR helper to install packages with, or `makepkg`."},
+				{Name: "package", Type: List, Repeated: true, Tagged: true, Example: "visual-studio-code-bin, +desktop", Description: "Packages to install."},
+				packageHook(),
+			}, strictKeys("remove", "mark_dependency")...)},
+			{Name: "flatpak", Type: Section, Description: "Flatpak applications and runtimes.", Keys: append([]*Key{
+				{Name: "auto_install", Type: Bool, Default: "true", Example: "false", Description: "Whether to install Flatpak if any Flatpak packages are declared."},
+				{Name: "remote", Type: Section, Repeated: true, Description: "A Flatpak remote.", Keys: []*Key{
+					{Name: "name", Type: String, Required: true, Example: "flathub", Description: "The name of the remote."},
+					{Name: "url", Type: String, Required: true, Example: "https://dl.flathub.org/repo/flathub.flatpakrepo", Description: "The URL of the remote."},
+					{Name: "user_installation", Type: Bool, Default: "false", Example: "true", Description: "Whether to add the remote to the user installation."},
+					{Name: "installation", Type: String, Example: "steam", Description: "The system-wide installation to add the remote to."},
+					{Name: "disable", Type: Bool, Default: "false", Example: "true", Description: "Whether the remote is disabled."},
+					{Name: "title", Type: String, Example: "Flathub", Description: "The title of the remote."},
+					{Name: "comment", Type: String, Example: "Central repository of Flatpak applications", Description: "A one-line comment about the remote."},
+					{Name: "description", Type: String, Example: "Central repository of Flatpak applications", Description: "A description of the remote."},
+					{Name: "homepage", Type: String, Example: "https://flathub.org/", Description: "The homepage of the remote."},
+					{Name: "icon", Type: String, Example: "https://dl.flathub.org/repo/logo.svg", Description: "The icon of the remote."},
+					{Name: "default_branch", Type: String, Example: "stable", Description: "The default branch of the remote."},
+					tagsKey(),
+				}},
+				{Name: "package", Type: List, Repeated: true, Tagged: true, Example: "com.github.tchx84.Flatseal",
+					Description: "Packages to install. Write a section to set where and how a package is installed.", Keys: []*Key{
+						{Name: "name", Type: List, Repeated: true, Tagged: true, Required: true, Example: "com.github.tchx84.Flatseal", Description: "The application IDs of the packages."},
+						{Name: "remote", Type: String, Example: "flathub", Description: "The remote to install the packages from."},
+						{Name: "user_installation", Type: Bool, Default: "false", Example: "true", Description: "Whether to install the packages to the user installation."},
+						{Name: "installation", Type: String, Example: "steam", Description: "The system-wide installation to install the packages to."},
+						{Name: "architecture", Type: String, Example: "x86_64", Description: "The architecture to install."},
+						{Name: "subpath", Type: String, Example: "/docs", Description: "Only install this subpath."},
+						tagsKey(),
+					}},
+				packageHook(),
+			}, strictKeys("remove")...)},
+		}},
+		{Name: "tags", Type: Section, Description: "Tag presets, and the tags selected automatically on each machine.", Keys: []*Key{
+			{Name: "preset", Type: List, Argument: "name", ArgumentExample: "laptop", Example: "+gui +wifi -server",
+				Description: "A named set of tags, which can be used in `--tags` and `host` mappings instead of the tags. Presets can include other presets."},
+			{Name: "host", Type: List, Argument: "hostname", ArgumentExample: "thinkpad", Example: "laptop +dev",
+				Description: "The tags and presets selected on the machine with this hostname or machine ID, before the tags given with `--tags`."},
+		}},
+		{Name: "applications", Type: Section, Description: "The default applications, as known names (like `neovim`) or executables.", Keys: []*Key{
+			{Name: "display_manager", Type: String, Example: "sddm", Description: "The display manager."},
+			{Name: "terminal", Type: String, Example: "kitty", Description: "The terminal emulator."},
+			{Name: "terminal_text_editor", Type: String, Example: "neovim", Description: "The text editor used in terminals."},
+			{Name: "graphical_text_editor", Type: String, Example: "code", Description: "The graphical text editor."},
+			{Name: "browser", Type: String, Example: "firefox", Description: "The web browser."},
+		}},
+	},
+}
+
+// Suggest returns the candidate that is closest to a misspelled name,
+// or an empty string if none of them is close enough.
+func Suggest(name string, candidates []string) string {
+	best, bestDistance := "", len(name)/3+2
+	for _, candidate := range candidates {
+		if distance := levenshtein(name, candidate); distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
\ No newline at end of file
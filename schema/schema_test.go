@@ -0,0 +1,54 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/schema"
+)
+
+func TestLookup(t *testing.T) {
+	key := schema.Lookup("packages/flatpak/remote[1]/url")
+	if assert.NotNil(t, key) {
+		assert.Equal(t, "url", key.Name)
+		assert.True(t, key.Required)
+	}
+
+	assert.Nil(t, schema.Lookup("packages/pacman/unknown"))
+	assert.Nil(t, schema.Lookup("essentials/kernel/name"))
+
+	// Keys with an argument are looked up with or without it
+	assert.Equal(t, schema.Lookup("tags/preset"), schema.Lookup("tags/preset laptop"))
+	assert.Nil(t, schema.Lookup("essentials/kernel linux"))
+}
+
+func TestLookup_ValueSection(t *testing.T) {
+	key := schema.Lookup("packages/flatpak/package")
+	assert.True(t, key.IsValue())
+	assert.True(t, key.IsSection())
+
+	key = schema.Lookup("packages/pacman/package")
+	assert.True(t, key.IsValue())
+	assert.False(t, key.IsSection())
+
+	key = schema.Lookup("users/user")
+	assert.False(t, key.IsValue())
+	assert.True(t, key.IsSection())
+}
+
+func TestDefault(t *testing.T) {
+	assert.Equal(t, "makepkg", schema.Default("packages/aur/helper"))
+	assert.Equal(t, "install", schema.Default("packages/pacman/hook/for"))
+	assert.Equal(t, "create", schema.Default("users/hook/for"))
+	assert.Equal(t, "", schema.Default("packages/pacman/parallel_downloads"))
+	assert.Panics(t, func() { schema.Default("packages/pacman/unknown") })
+}
+
+func TestSuggest(t *testing.T) {
+	candidates := []string{"privilege_escalation", "kernel", "network_handler", "bootloader"}
+
+	assert.Equal(t, "privilege_escalation", schema.Suggest("privilige_escalation", candidates))
+	assert.Equal(t, "kernel", schema.Suggest("kernal", candidates))
+	assert.Equal(t, "", schema.Suggest("shell", candidates))
+}
\ No newline at end of file
@@ -0,0 +1,158 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DevReaper0/declarch/utils"
+)
+
+// DefaultGenerationsDir is the default directory generations are stored in.
+const DefaultGenerationsDir = "/var/lib/declarch/generations"
+
+// Generation is a snapshot of a successfully applied configuration.
+type Generation struct {
+	Number int       `json:"number"`
+	Time   time.Time `json:"time"`
+
+	// ConfigPath is the path of the configuration file that was applied.
+	ConfigPath string `json:"config_path"`
+	// Config is the parsed configuration, with all sources and variables resolved.
+	Config string `json:"config"`
+	// Tags are the tags the configuration was applied with.
+	Tags []string `json:"tags"`
+
+	// Resources are the resolved packages, users and Flatpak resources of the configuration.
+	Resources *State `json:"resources"`
+}
+
+// SaveGeneration stores a new generation in the given directory.
+// The generation is numbered one higher than the newest existing generation.
+func SaveGeneration(dir string, gen *Generation) error {
+	numbers, err := generationNumbers(dir)
+	if err != nil {
+		return err
+	}
+
+	gen.Number = 1
+	if len(numbers) > 0 {
+		gen.Number = numbers[len(numbers)-1] + 1
+	}
+
+	content, err := json.MarshalIndent(gen, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(generationPath(dir, gen.Number), content, 0o644)
+}
+
+// LoadGeneration reads the generation with the given number from the given directory.
+func LoadGeneration(dir string, number int) (*Generation, error) {
+	content, err := os.ReadFile(generationPath(dir, number))
+	if err != nil {
+		return nil, err
+	}
+
+	gen := &Generation{}
+	if err := json.Unmarshal(content, gen); err != nil {
+		return nil, fmt.Errorf("invalid generation file %s: %w", generationPath(dir, number), err)
+	}
+	if gen.Resources == nil {
+		gen.Resources = New("")
+	}
+	return gen, nil
+}
+
+// ListGenerations reads all generations in the given directory, ordered from oldest to newest.
+func ListGenerations(dir string) ([]*Generation, error) {
+	numbers, err := generationNumbers(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	generations := make([]*Generation, 0, len(numbers))
+	for _, number := range numbers {
+		gen, err := LoadGeneration(dir, number)
+		if err != nil {
+			return nil, err
+		}
+		generations = append(generations, gen)
+	}
+	return generations, nil
+}
+
+// Summary describes the changes of the generation's resources compared to a previous generation.
+// If previous is nil, the resources are compared against an empty state.
+func (g *Generation) Summary(previous *Generation) string {
+	previousResources := New("")
+	if previous != nil {
+		previousResources = previous.Resources
+	}
+
+	changes := []string{}
+	addChange := func(name string, current, previous []string) {
+		added, removed := utils.GetDifferences(current, previous)
+		if len(added) > 0 || len(removed) > 0 {
+			changes = append(changes, fmt.Sprintf("%s +%d -%d", name, len(added), len(removed)))
+		}
+	}
+
+	kinds := []string{}
+	for kind := range g.Resources.Packages {
+		kinds = append(kinds, kind)
+	}
+	for kind := range previousResources.Packages {
+		if !slices.Contains(kinds, kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	sort.Strings(kinds)
+
+	for _, kind := range kinds {
+		addChange(kind, g.Resources.Packages[kind], previousResources.Packages[kind])
+	}
+	addChange("users", g.Resources.Usernames(), previousResources.Usernames())
+	addChange("flatpak_remotes", g.Resources.FlatpakRemoteIdentifiers(), previousResources.FlatpakRemoteIdentifiers())
+
+	if len(changes) == 0 {
+		return "no changes"
+	}
+	return strings.Join(changes, ", ")
+}
+
+func generationPath(dir string, number int) string {
+	return filepath.Join(dir, strconv.Itoa(number)+".json")
+}
+
+func generationNumbers(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return []int{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	numbers := []int{}
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		if number, err := strconv.Atoi(name); err == nil {
+			numbers = append(numbers, number)
+		}
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
\ No newline at end of file
@@ -0,0 +1,45 @@
+package state_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/state"
+)
+
+func TestGenerations_SaveAndList(t *testing.T) {
+	dir := t.TempDir()
+
+	first := state.New("")
+	first.Packages["pacman"] = []string{"neovim", "nano"}
+	assert.NoError(t, state.SaveGeneration(dir, &state.Generation{Time: time.Now(), Config: "a = b\n", Resources: first}))
+
+	second := first.Clone()
+	second.Packages["pacman"] = []string{"neovim", "firefox", "bash"}
+	second.Users["myuser"] = modules.User{Username: "myuser"}
+	assert.NoError(t, state.SaveGeneration(dir, &state.Generation{Time: time.Now(), Tags: []string{"+default"}, Resources: second}))
+
+	generations, err := state.ListGenerations(dir)
+	assert.NoError(t, err)
+	assert.Len(t, generations, 2)
+	assert.Equal(t, 1, generations[0].Number)
+	assert.Equal(t, 2, generations[1].Number)
+	assert.Equal(t, "a = b\n", generations[0].Config)
+	assert.Equal(t, []string{"+default"}, generations[1].Tags)
+
+	assert.Equal(t, "pacman +2 -0", generations[0].Summary(nil))
+	assert.Equal(t, "pacman +2 -1, users +1 -0", generations[1].Summary(generations[0]))
+	assert.Equal(t, "no changes", generations[1].Summary(generations[1]))
+
+	// The clone must not share packages with the original state
+	assert.Equal(t, []string{"neovim", "nano"}, first.Packages["pacman"])
+}
+
+func TestGenerations_ListMissingDir(t *testing.T) {
+	generations, err := state.ListGenerations(t.TempDir() + "/missing")
+	assert.NoError(t, err)
+	assert.Empty(t, generations)
+}
\ No newline at end of file
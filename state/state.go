@@ -0,0 +1,222 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/DevReaper0/declarch/modules"
+)
+
+// Version is the current version of the state file format.
+const Version = 1
+
+// DefaultPath is the default location of the state file.
+const DefaultPath = "/var/lib/declarch/state.json"
+
+// State records every resource that has been applied to the system.
+// It is used as the previous side of every diff when applying a configuration,
+// and is saved after every change so that a failed apply never loses track of what was already done.
+type State struct {
+	Version int `json:"version"`
+	// Generation is the number of the generation that was applied last, or 0 if there is none.
+	Generation int `json:"generation"`
+
+	// Packages maps a package kind (e.g. "pacman", "aur", "kernel" or "flatpak") to the IDs of the applied packages.
+	Packages       map[string][]string              `json:"packages"`
+	Users          map[string]modules.User          `json:"users"`
+	FlatpakRemotes map[string]modules.FlatpakRemote `json:"flatpak_remotes"`
+	// FlatpakPackages holds the full values of Flatpak packages by their identifiers,
+	// so that removed packages are uninstalled from the installation they were installed to.
+	FlatpakPackages map[string]modules.FlatpakPackage `json:"flatpak_packages"`
+
+	// Path is the file the state is saved to.
+	// If Path is empty, the state is only kept in memory.
+	Path string `json:"-"`
+}
+
+// New creates an empty state that is saved to the given path.
+func New(path string) *State {
+	return &State{
+		Version:         Version,
+		Packages:        make(map[string][]string),
+		Users:           make(map[string]modules.User),
+		FlatpakRemotes:  make(map[string]modules.FlatpakRemote),
+		FlatpakPackages: make(map[string]modules.FlatpakPackage),
+		Path:            path,
+	}
+}
+
+// Load reads the state from the given path.
+// If the file does not exist, an empty state is returned, and exists is false.
+func Load(path string) (*State, bool, error) {
+	state := New(path)
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, true, fmt.Errorf("invalid state file %s: %w", path, err)
+	}
+	if state.Version > Version {
+		return nil, true, fmt.Errorf("unsupported state file version %d (expected at most %d)", state.Version, Version)
+	}
+	state.Version = Version
+
+	// Maps are nil if they were missing from the file
+	if state.Packages == nil {
+		state.Packages = make(map[string][]string)
+	}
+	if state.Users == nil {
+		state.Users = make(map[string]modules.User)
+	}
+	if state.FlatpakRemotes == nil {
+		state.FlatpakRemotes = make(map[string]modules.FlatpakRemote)
+	}
+	if state.FlatpakPackages == nil {
+		state.FlatpakPackages = make(map[string]modules.FlatpakPackage)
+	}
+
+	return state, true, nil
+}
+
+// Save writes the state to its path.
+// The file is replaced atomically, so an interrupted save never leaves a partially written state.
+func (s *State) Save() error {
+	if s.Path == "" {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return err
+	}
+
+	tempPath := s.Path + ".tmp"
+	if err := os.WriteFile(tempPath, content, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, s.Path)
+}
+
+// Clone returns a deep copy of the state that is only kept in memory.
+func (s *State) Clone() *State {
+	clone := New("")
+	clone.Generation = s.Generation
+	for kind, names := range s.Packages {
+		clone.Packages[kind] = slices.Clone(names)
+	}
+	for username, user := range s.Users {
+		user.Groups = slices.Clone(user.Groups)
+		clone.Users[username] = user
+	}
+	for identifier, remote := range s.FlatpakRemotes {
+		clone.FlatpakRemotes[identifier] = remote
+	}
+	for identifier, pkg := range s.FlatpakPackages {
+		clone.FlatpakPackages[identifier] = pkg
+	}
+	return clone
+}
+
+// GetPackages returns the names of the applied packages of the given kind.
+func (s *State) GetPackages(kind string) []string {
+	return slices.Clone(s.Packages[kind])
+}
+
+// SetPackages replaces the applied packages of the given kind, and saves the state.
+func (s *State) SetPackages(kind string, names []string) error {
+	s.Packages[kind] = slices.Clone(names)
+	return s.Save()
+}
+
+// RemovePackages removes packages of the given kind, and saves the state.
+func (s *State) RemovePackages(kind string, names []string) error {
+	s.Packages[kind] = slices.DeleteFunc(s.Packages[kind], func(name string) bool {
+		return slices.Contains(names, name)
+	})
+	return s.Save()
+}
+
+// SetUser records an applied user, and saves the state.
+func (s *State) SetUser(user modules.User) error {
+	s.Users[user.Username] = user
+	return s.Save()
+}
+
+// RemoveUser removes a user, and saves the state.
+func (s *State) RemoveUser(username string) error {
+	delete(s.Users, username)
+	return s.Save()
+}
+
+// SetFlatpakRemote records an applied Flatpak remote by its identifier, and saves the state.
+func (s *State) SetFlatpakRemote(identifier string, remote modules.FlatpakRemote) error {
+	s.FlatpakRemotes[identifier] = remote
+	return s.Save()
+}
+
+// RemoveFlatpakRemote removes a Flatpak remote by its identifier, and saves the state.
+func (s *State) RemoveFlatpakRemote(identifier string) error {
+	delete(s.FlatpakRemotes, identifier)
+	return s.Save()
+}
+
+// SetFlatpakPackageValues records the values of Flatpak packages that are about to be applied, and saves the state.
+// Values of packages that are neither given nor applied are dropped.
+func (s *State) SetFlatpakPackageValues(pkgs []modules.FlatpakPackage) error {
+	identifiers := slices.Clone(s.Packages["flatpak"])
+	for _, pkg := range pkgs {
+		s.FlatpakPackages[pkg.Identifier()] = pkg
+		identifiers = append(identifiers, pkg.Identifier())
+	}
+	for identifier := range s.FlatpakPackages {
+		if !slices.Contains(identifiers, identifier) {
+			delete(s.FlatpakPackages, identifier)
+		}
+	}
+	return s.Save()
+}
+
+// Usernames returns the usernames of all applied users in sorted order.
+func (s *State) Usernames() []string {
+	return sortedKeys(s.Users)
+}
+
+// FlatpakRemoteIdentifiers returns the identifiers of all applied Flatpak remotes in sorted order.
+func (s *State) FlatpakRemoteIdentifiers() []string {
+	return sortedKeys(s.FlatpakRemotes)
+}
+
+// FlatpakPackageList returns the values of all applied Flatpak packages, in the order they were applied.
+func (s *State) FlatpakPackageList() []modules.FlatpakPackage {
+	pkgs := make([]modules.FlatpakPackage, 0, len(s.Packages["flatpak"]))
+	for _, identifier := range s.Packages["flatpak"] {
+		if pkg, ok := s.FlatpakPackages[identifier]; ok {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	return pkgs
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
\ No newline at end of file
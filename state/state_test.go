@@ -0,0 +1,74 @@
+package state_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DevReaper0/declarch/modules"
+	"github.com/DevReaper0/declarch/state"
+)
+
+func TestState_LoadMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	st, exists, err := state.Load(path)
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Empty(t, st.GetPackages("pacman"))
+	assert.Empty(t, st.Usernames())
+
+	_, err = os.Stat(path)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestState_SaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	st := state.New(path)
+
+	assert.NoError(t, st.SetPackages("pacman", []string{"neovim", "bash", "firefox"}))
+	assert.NoError(t, st.RemovePackages("pacman", []string{"bash"}))
+	assert.NoError(t, st.SetUser(modules.User{Username: "myuser", Shell: "bash", Groups: []string{"wheel"}}))
+	assert.NoError(t, st.SetFlatpakPackageValues([]modules.FlatpakPackage{{Name: "com.github.tchx84.Flatseal"}, {Name: "org.gimp.GIMP"}}))
+	assert.NoError(t, st.SetPackages("flatpak", []string{"default:com.github.tchx84.Flatseal"}))
+	assert.NoError(t, st.SetFlatpakRemote(":flathub", modules.FlatpakRemote{Name: "flathub", UserInstallation: true}))
+	assert.NoError(t, st.RemoveFlatpakRemote(":flathub"))
+
+	loaded, exists, err := state.Load(path)
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, state.Version, loaded.Version)
+	assert.Equal(t, []string{"neovim", "firefox"}, loaded.GetPackages("pacman"))
+	assert.Equal(t, []string{"myuser"}, loaded.Usernames())
+	assert.Equal(t, []string{"wheel"}, loaded.Users["myuser"].Groups)
+	assert.Equal(t, []modules.FlatpakPackage{{Name: "com.github.tchx84.Flatseal"}}, loaded.FlatpakPackageList())
+	assert.Empty(t, loaded.FlatpakRemoteIdentifiers())
+}
+
+func TestState_InMemory(t *testing.T) {
+	st := state.New("")
+	assert.NoError(t, st.SetPackages("aur", []string{"yay"}))
+	assert.Equal(t, []string{"yay"}, st.GetPackages("aur"))
+}
+
+func TestState_UnsupportedVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+	os.WriteFile(path, []byte(`{"version": 999}`), 0o644)
+
+	_, _, err := state.Load(path)
+	assert.Error(t, err)
+}
+func TestState_SetFlatpakPackageValues(t *testing.T) {
+	st := state.New("")
+	assert.NoError(t, st.SetPackages("flatpak", []string{"default:org.gimp.GIMP", "default:org.gnome.Boxes", "default:org.gnome.Maps"}))
+	assert.NoError(t, st.RemovePackages("flatpak", []string{"default:org.gnome.Maps"}))
+	applied := st.Packages["flatpak"]
+
+	// The applied packages have spare capacity after the removal, which must not be written to
+	assert.NoError(t, st.SetFlatpakPackageValues([]modules.FlatpakPackage{{Name: "com.spotify.Client"}}))
+	assert.Equal(t, []string{"default:org.gimp.GIMP", "default:org.gnome.Boxes"}, st.GetPackages("flatpak"))
+	assert.Equal(t, "", applied[:cap(applied)][2])
+	assert.Contains(t, st.FlatpakPackages, modules.FlatpakPackage{Name: "com.spotify.Client"}.Identifier())
+}
\ No newline at end of file
@@ -31,4 +31,54 @@ func GetDifferences(current []string, previous []string) ([]string, []string) {
 	}
 
 	return added, removed
+}
+
+// DiffLine is a single line of a line-based diff.
+// Op is ' ' for unchanged lines, '-' for removed lines and '+' for added lines.
+type DiffLine struct {
+	Op   byte
+	Text string
+}
+
+// DiffLines computes an ordered line diff between the previous and current lines
+// using the longest common subsequence of both.
+func DiffLines(current []string, previous []string) []DiffLine {
+	lcs := make([][]int, len(previous)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(current)+1)
+	}
+	for i := len(previous) - 1; i >= 0; i-- {
+		for j := len(current) - 1; j >= 0; j-- {
+			if previous[i] == current[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	diff := []DiffLine{}
+	i, j := 0, 0
+	for i < len(previous) && j < len(current) {
+		switch {
+		case previous[i] == current[j]:
+			diff = append(diff, DiffLine{' ', previous[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, DiffLine{'-', previous[i]})
+			i++
+		default:
+			diff = append(diff, DiffLine{'+', current[j]})
+			j++
+		}
+	}
+	for ; i < len(previous); i++ {
+		diff = append(diff, DiffLine{'-', previous[i]})
+	}
+	for ; j < len(current); j++ {
+		diff = append(diff, DiffLine{'+', current[j]})
+	}
+
+	return diff
 }
\ No newline at end of file
@@ -13,6 +13,9 @@ import (
 	"github.com/fatih/color"
 )
 
+// DryRun makes ExecCommand print the commands it would run instead of running them.
+var DryRun = false
+
 func GetApplicationPath(name string) string {
 	switch name {
 	case "bash":
@@ -32,14 +35,27 @@ func ExecCommand(command []string, dir string, username string) error {
 		return ExecCommand(command[1:], dir, username)
 	}
 
+	if DryRun {
+		color.Set(color.FgYellow)
+		fmt.Print("Would run command: ")
+		color.Set(color.Bold)
+		fmt.Print(formatCommand(command))
+		color.Set(color.ResetBold)
+		if username != "" && username != "root" {
+			fmt.Print(" (as " + username + ")")
+		}
+		if dir != "" {
+			fmt.Print(" (in " + dir + ")")
+		}
+		fmt.Println()
+		color.Unset()
+		return nil
+	}
+
 	color.Set(color.FgCyan)
 	fmt.Print("\nRunning command: ")
 	color.Set(color.Bold)
-	if len(command) > 1 && command[0] == "sh" && command[1] == "-c" {
-		fmt.Printf("sh -c %q\n", strings.Join(command[2:], " "))
-	} else {
-		fmt.Println(strings.Join(command, " "))
-	}
+	fmt.Println(formatCommand(command))
 	color.Unset()
 
 	cmd := exec.Command(command[0], command[1:]...)
@@ -51,29 +67,8 @@ func ExecCommand(command []string, dir string, username string) error {
 		cmd.Dir = absDir
 	}
 
-	if username != "" && username != "root" {
-		userInfo, err := user.Lookup(username)
-		if err != nil {
-			return fmt.Errorf("failed to get user info for %s: %w", username, err)
-		}
-
-		uid, err := strconv.Atoi(userInfo.Uid)
-		if err != nil {
-			return fmt.Errorf("failed to convert uid to int: %w", err)
-		}
-		gid, err := strconv.Atoi(userInfo.Gid)
-		if err != nil {
-			return fmt.Errorf("failed to convert gid to int: %w", err)
-		}
-
-		cmd.Env = append(os.Environ(), "USER="+userInfo.Username, "HOME="+userInfo.HomeDir)
-
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			Credential: &syscall.Credential{
-				Uid: uint32(uid),
-				Gid: uint32(gid),
-			},
-		}
+	if err := setCommandUser(cmd, username); err != nil {
+		return err
 	}
 
 	cmd.Stdin = os.Stdin
@@ -88,5 +83,67 @@ func ExecCommand(command []string, dir string, username string) error {
 		return fmt.Errorf("command failed: %w", err)
 	}
 
+	return nil
+}
+
+// formatCommand returns a command as it is printed, quoting the script of `sh -c` commands.
+func formatCommand(command []string) string {
+	if len(command) > 1 && command[0] == "sh" && command[1] == "-c" {
+		return fmt.Sprintf("sh -c %q", strings.Join(command[2:], " "))
+	}
+	return strings.Join(command, " ")
+}
+
+// CommandOutput runs a command that only reads the system state and returns its standard output.
+// Unlike ExecCommand, the command is also run when DryRun is set.
+// If the current process is not running as root, the command is run as the current user instead.
+func CommandOutput(command []string, username string) (string, error) {
+	if len(command) == 0 {
+		return "", fmt.Errorf("no command provided")
+	}
+
+	cmd := exec.Command(command[0], command[1:]...)
+	if os.Geteuid() == 0 {
+		if err := setCommandUser(cmd, username); err != nil {
+			return "", err
+		}
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("command '%s' failed: %w", strings.Join(command, " "), err)
+	}
+
+	return string(output), nil
+}
+
+func setCommandUser(cmd *exec.Cmd, username string) error {
+	if username == "" || username == "root" {
+		return nil
+	}
+
+	userInfo, err := user.Lookup(username)
+	if err != nil {
+		return fmt.Errorf("failed to get user info for %s: %w", username, err)
+	}
+
+	uid, err := strconv.Atoi(userInfo.Uid)
+	if err != nil {
+		return fmt.Errorf("failed to convert uid to int: %w", err)
+	}
+	gid, err := strconv.Atoi(userInfo.Gid)
+	if err != nil {
+		return fmt.Errorf("failed to convert gid to int: %w", err)
+	}
+
+	cmd.Env = append(os.Environ(), "USER="+userInfo.Username, "HOME="+userInfo.HomeDir)
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Credential: &syscall.Credential{
+			Uid: uint32(uid),
+			Gid: uint32(gid),
+		},
+	}
+
 	return nil
 }
\ No newline at end of file